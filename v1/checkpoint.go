@@ -0,0 +1,356 @@
+// checkpoint.go - Checkpointing and resuming the training of the neural network.
+//
+// # Copyright 2024 Mark Oxley
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package jasper
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+const (
+	// checkpointPrefix starts the file name of each periodic checkpoint.
+	checkpointPrefix = "checkpoint-"
+	// checkpointSuffix ends the file name of each checkpoint.
+	checkpointSuffix = ".json"
+	// bestCheckpoint is the file name of the checkpoint written on improvement.
+	bestCheckpoint = "best" + checkpointSuffix
+)
+
+// Checkpoint writes snapshots of the training to a directory, so that an
+// interrupted training run can be continued with Resume.
+//
+// It is used by setting the Checkpoint field of the TrainingData.
+type Checkpoint struct {
+	// Dir is the directory the checkpoints are written to. It is created if
+	// it does not exist.
+	Dir string
+
+	// Every is the number of training iterations between checkpoints. If zero,
+	// no periodic checkpoints are written.
+	Every int
+
+	// OnImprovement writes a checkpoint to best.json whenever the testing
+	// error improves.
+	OnImprovement bool
+
+	// KeepLast is the number of periodic checkpoints to keep. Older ones are
+	// deleted. If zero, every checkpoint is kept.
+	KeepLast int
+
+	// best is the lowest validation error seen so far.
+	best float64
+
+	// started indicates if a validation error has been seen.
+	started bool
+}
+
+// NewCheckpoint creates a checkpoint policy writing to dir.
+//
+// Parameters:
+// - dir: The directory the checkpoints are written to.
+// - every: The number of training iterations between checkpoints.
+// - keepLast: The number of periodic checkpoints to keep, or zero to keep all.
+//
+// Returns:
+// - A pointer to the policy.
+func NewCheckpoint(dir string, every, keepLast int) *Checkpoint {
+	return &Checkpoint{
+		Dir:      dir,
+		Every:    every,
+		KeepLast: keepLast,
+	}
+}
+
+// checkpointData is the content of a checkpoint file.
+type checkpointData struct {
+	// Epoch is the number of completed training iterations.
+	Epoch int `json:"i"`
+
+	// Network holds the network, including its optimizer state.
+	Network *Network `json:"n"`
+
+	// Rows is the number of rows in the training data.
+	Rows int `json:"r"`
+
+	// Order holds the indices of the data rows, training rows first, in their
+	// current order.
+	Order []int `json:"o"`
+
+	// TrainCount is the number of rows used for training.
+	TrainCount int `json:"t"`
+
+	// TestCount is the number of rows held out for testing.
+	TestCount int `json:"tc,omitempty"`
+
+	// History holds the records of the completed training iterations.
+	History []EpochRecord `json:"h"`
+
+	// BestLoss is the lowest validation error seen by the checkpoint policy.
+	BestLoss float64 `json:"b"`
+
+	// RandomState holds the state of the training data's own random number
+	// generator, if it has one.
+	RandomState []byte `json:"rs,omitempty"`
+
+	// EarlyStopping holds the state of the early stopping policy, if used.
+	EarlyStopping *earlyStoppingState `json:"es,omitempty"`
+}
+
+// earlyStoppingState is the saved state of an early stopping policy.
+type earlyStoppingState struct {
+	Best      float64           `json:"b"`
+	BestEpoch int               `json:"e"`
+	Wait      int               `json:"w"`
+	Values    []*Matrix         `json:"v,omitempty"`
+	States    []*OptimizerState `json:"s,omitempty"`
+	Layers    []*Matrix         `json:"l,omitempty"`
+}
+
+// update writes any checkpoints due after a completed training iteration.
+//
+// Parameters:
+// - n: The network being trained.
+// - td: The training data.
+// - h: The training history so far.
+//
+// Returns:
+// - An error if a checkpoint cannot be written.
+func (c *Checkpoint) update(n *Network, td *TrainingData, h *TrainingHistory) error {
+	rec := h.Epochs[len(h.Epochs)-1]
+	improved := !c.started || rec.ValidationLoss < c.best
+	if improved {
+		c.best = rec.ValidationLoss
+		c.started = true
+	}
+	if c.OnImprovement && improved {
+		if err := c.write(bestCheckpoint, n, td, h); err != nil {
+			return err
+		}
+	}
+	if c.Every > 0 && (rec.Epoch+1)%c.Every == 0 {
+		if err := c.write(fmt.Sprintf("%v%08d%v", checkpointPrefix, rec.Epoch+1, checkpointSuffix), n, td, h); err != nil {
+			return err
+		}
+		return c.rotate()
+	}
+	return nil
+}
+
+// write writes a checkpoint file.
+//
+// The file is written under a temporary name and then renamed, so an
+// interruption never leaves a partly written checkpoint.
+//
+// Parameters:
+// - name: The file name of the checkpoint.
+// - n: The network being trained.
+// - td: The training data.
+// - h: The training history so far.
+//
+// Returns:
+// - An error if the checkpoint cannot be written.
+func (c *Checkpoint) write(name string, n *Network, td *TrainingData, h *TrainingHistory) error {
+	data := checkpointData{
+		Epoch:      h.Epochs[len(h.Epochs)-1].Epoch + 1,
+		Network:    n,
+		Rows:       len(td.Data),
+		Order:      td.order,
+		TrainCount: len(td.trainingData),
+		TestCount:  len(td.testData),
+		History:    h.Epochs,
+		BestLoss:   c.best,
+	}
+	if td.random != nil {
+		var err error
+		if data.RandomState, err = td.random.state(); err != nil {
+			return fmt.Errorf("checkpoint error: %v", err)
+		}
+	}
+	if es := td.EarlyStopping; es != nil {
+		data.EarlyStopping = &earlyStoppingState{
+			Best:      es.best,
+			BestEpoch: es.bestEpoch,
+			Wait:      es.wait,
+		}
+		if es.weights != nil {
+			data.EarlyStopping.Values = es.weights.values
+			data.EarlyStopping.States = es.weights.states
+			data.EarlyStopping.Layers = es.weights.layers
+		}
+	}
+	body, err := json.Marshal(&data)
+	if err != nil {
+		return fmt.Errorf("checkpoint error: %v", err)
+	}
+	if err := os.MkdirAll(c.Dir, 0o755); err != nil {
+		return fmt.Errorf("checkpoint error: %v", err)
+	}
+	path := filepath.Join(c.Dir, name)
+	if err := os.WriteFile(path+".tmp", body, 0o644); err != nil {
+		return fmt.Errorf("checkpoint error: %v", err)
+	}
+	if err := os.Rename(path+".tmp", path); err != nil {
+		return fmt.Errorf("checkpoint error: %v", err)
+	}
+	return nil
+}
+
+// rotate deletes the oldest periodic checkpoints, keeping KeepLast of them.
+//
+// Returns:
+// - An error if a checkpoint cannot be deleted.
+func (c *Checkpoint) rotate() error {
+	if c.KeepLast <= 0 {
+		return nil
+	}
+	names, err := checkpointFiles(c.Dir)
+	if err != nil {
+		return fmt.Errorf("checkpoint error: %v", err)
+	}
+	for len(names) > c.KeepLast {
+		if err := os.Remove(filepath.Join(c.Dir, names[0])); err != nil {
+			return fmt.Errorf("checkpoint error: %v", err)
+		}
+		names = names[1:]
+	}
+	return nil
+}
+
+// checkpointFiles returns the names of the periodic checkpoints in a
+// directory, oldest first.
+//
+// Parameters:
+// - dir: The directory to search.
+//
+// Returns:
+// - The file names of the checkpoints.
+// - An error if the directory cannot be read.
+func checkpointFiles(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, e := range entries {
+		if !e.IsDir() && strings.HasPrefix(e.Name(), checkpointPrefix) && strings.HasSuffix(e.Name(), checkpointSuffix) {
+			names = append(names, e.Name())
+		}
+	}
+	// The iteration numbers are zero padded, so the names sort in order.
+	sort.Strings(names)
+	return names, nil
+}
+
+// readCheckpoint reads a checkpoint file.
+//
+// Parameters:
+// - path: The path of the checkpoint file.
+//
+// Returns:
+// - The content of the checkpoint.
+// - An error if the checkpoint cannot be read.
+func readCheckpoint(path string) (*checkpointData, error) {
+	body, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	data := checkpointData{}
+	if err := json.Unmarshal(body, &data); err != nil {
+		return nil, err
+	}
+	return &data, nil
+}
+
+// Resume restores the network from the most recent checkpoint in a directory
+// and prepares the training data to continue from it.
+//
+// The training data must hold the same rows, in the same order, as when the
+// checkpoint was written. Its training, validation and test split is restored, and the
+// next call to Train on the returned network continues from the iteration
+// after the checkpoint, with the optimizer state, learning rate schedule,
+// training history and early stopping state as they were.
+//
+// Parameters:
+// - dir: The directory holding the checkpoints.
+// - td: The training data used when the checkpoints were written.
+//
+// Returns:
+// - A pointer to the restored network.
+// - An error if no checkpoint can be read or it does not match the training data.
+func Resume(dir string, td *TrainingData) (*Network, error) {
+	// Find the most recent of the periodic and best checkpoints
+	var latest *checkpointData
+	names, err := checkpointFiles(dir)
+	if err != nil {
+		return nil, fmt.Errorf("resume error: %v", err)
+	}
+	if len(names) > 0 {
+		if latest, err = readCheckpoint(filepath.Join(dir, names[len(names)-1])); err != nil {
+			return nil, fmt.Errorf("resume error: %v", err)
+		}
+	}
+	best, err := readCheckpoint(filepath.Join(dir, bestCheckpoint))
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("resume error: %v", err)
+	}
+	if best != nil && (latest == nil || best.Epoch > latest.Epoch) {
+		latest = best
+	}
+	if latest == nil {
+		return nil, errors.New("resume error: no checkpoint found")
+	}
+
+	// Check the training data matches, then restore the split
+	if latest.Rows != len(td.Data) || len(latest.Order) != len(td.Data) {
+		return nil, fmt.Errorf("resume error: checkpoint has %v rows of data, %v provided", latest.Rows, len(td.Data))
+	}
+	td.split(latest.Order, latest.TrainCount, latest.TestCount)
+	td.random = nil
+	if len(latest.RandomState) > 0 && td.Seed != 0 && td.Source == nil {
+		if td.random, err = restoreRandom(td.Seed, latest.RandomState); err != nil {
+			return nil, fmt.Errorf("resume error: %v", err)
+		}
+	}
+	td.resume = latest
+	return latest.Network, nil
+}
+
+// restore applies the state saved in a checkpoint to the training policies.
+//
+// Parameters:
+// - td: The training data the policies belong to.
+func (data *checkpointData) restore(td *TrainingData) {
+	if c := td.Checkpoint; c != nil {
+		c.best = data.BestLoss
+		c.started = len(data.History) > 0
+	}
+	if es := td.EarlyStopping; es != nil {
+		es.reset()
+		if s := data.EarlyStopping; s != nil {
+			es.best = s.Best
+			es.bestEpoch = s.BestEpoch
+			es.wait = s.Wait
+			if len(s.Values) > 0 {
+				es.weights = &snapshot{values: s.Values, states: s.States, layers: s.Layers}
+			}
+		}
+	}
+}
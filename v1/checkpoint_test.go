@@ -0,0 +1,123 @@
+package jasper
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"testing"
+)
+
+// checkpointTraining returns a network and training data using each of the
+// settings that are saved in a checkpoint.
+func checkpointTraining(t *testing.T, dir string) (*Network, *TrainingData) {
+	t.Helper()
+	c := NewConfig([]uint32{1, 6, 1})
+	c.Seed = 2
+	c.Quiet = true
+	c.Output = Linear
+	c.Optimizer = NewAdam()
+	c.LearningRate = 0.01
+	c.Schedule = NewLinearWarmup(5, NewReduceOnPlateau(0.5, 3))
+	n, err := New(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	td := NewTrainingData(30, 0.7, 0)
+	for i := range 40 {
+		x := float64(i) / 40
+		td.AddRow([]float64{x}, []float64{x * x})
+	}
+	td.BatchSize = 4
+	td.Shuffle = true
+	td.Seed = 3
+	td.EarlyStopping = NewEarlyStopping(1000, 0, true)
+	if dir != "" {
+		td.Checkpoint = NewCheckpoint(dir, 10, 2)
+	}
+	return n, td
+}
+
+// withoutDurations clears the durations of the iterations of a history, which
+// differ between runs.
+func withoutDurations(h *TrainingHistory) []EpochRecord {
+	res := slices.Clone(h.Epochs)
+	for i := range res {
+		res[i].Duration = 0
+	}
+	return res
+}
+
+func TestCheckpointResume(t *testing.T) {
+	// Train without interruption.
+	n, td := checkpointTraining(t, "")
+	want, err := n.Train(td)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(want.Epochs) != 30 {
+		t.Fatalf("trained for %v iterations, want 30", len(want.Epochs))
+	}
+	wantJSON, err := json.Marshal(n)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Train while writing checkpoints, keeping the last two.
+	dir := t.TempDir()
+	n, td = checkpointTraining(t, dir)
+	if _, err := n.Train(td); err != nil {
+		t.Fatal(err)
+	}
+	names, err := checkpointFiles(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(names, []string{"checkpoint-00000020.json", "checkpoint-00000030.json"}) {
+		t.Fatalf("checkpoints %v", names)
+	}
+
+	// Resume from the checkpoint written after twenty iterations, as though
+	// training had been interrupted before the last one.
+	if err := os.Remove(filepath.Join(dir, names[1])); err != nil {
+		t.Fatal(err)
+	}
+	_, td = checkpointTraining(t, "")
+	resumed, err := Resume(dir, td)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resumed.SetDebug(false)
+	got, err := resumed.Train(td)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(withoutDurations(got), withoutDurations(want)) || got.BestEpoch != want.BestEpoch || got.StopReason != want.StopReason {
+		t.Errorf("history %+v, want %+v", got, want)
+	}
+	gotJSON, err := json.Marshal(resumed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(gotJSON) != string(wantJSON) {
+		t.Error("the resumed network differs from the uninterrupted network")
+	}
+}
+
+func TestCheckpointResumeErrors(t *testing.T) {
+	dir := t.TempDir()
+	if _, err := Resume(dir, &TrainingData{}); err == nil {
+		t.Error("no checkpoint: expected an error")
+	}
+	n, td := checkpointTraining(t, dir)
+	td.Iterations = 10
+	if _, err := n.Train(td); err != nil {
+		t.Fatal(err)
+	}
+	_, td = checkpointTraining(t, "")
+	td.Data = td.Data[1:]
+	if _, err := Resume(dir, td); err == nil {
+		t.Error("different rows: expected an error")
+	}
+}
@@ -0,0 +1,328 @@
+// crossvalidation.go - K-fold cross validation of the neural network.
+//
+// # Copyright 2024 Mark Oxley
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package jasper
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+)
+
+// FoldMetrics holds the main metrics of the predictions for a held out fold.
+type FoldMetrics struct {
+	// Loss is the average error of the held out rows, each weighted by its
+	// sample weight and the weight of its class as it is when training.
+	Loss float64
+
+	// Accuracy, MacroF1, ROCAUC and LogLoss are the classification metrics of
+	// the held out rows, as reported by Evaluate.
+	Accuracy, MacroF1, ROCAUC, LogLoss float64
+
+	// RMSE, MAE and R2 are the regression metrics of the held out rows, as
+	// reported by EvaluateRegression.
+	RMSE, MAE, R2 float64
+}
+
+// values returns pointers to each of the metrics.
+//
+// Returns:
+// - The pointers to the metrics.
+func (m *FoldMetrics) values() []*float64 {
+	return []*float64{&m.Loss, &m.Accuracy, &m.MacroF1, &m.ROCAUC, &m.LogLoss, &m.RMSE, &m.MAE, &m.R2}
+}
+
+// FoldResult holds the results of training and scoring a single fold.
+type FoldResult struct {
+	// Fold is the zero based index of the fold held out for scoring.
+	Fold int
+
+	// TrainCount is the number of rows the network was trained with,
+	// including its validation rows.
+	TrainCount int
+
+	// TestCount is the number of rows in the held out fold.
+	TestCount int
+
+	// FoldMetrics holds the main metrics of the held out rows.
+	FoldMetrics
+
+	// Classification holds the full classification report of the held out
+	// rows. It is only meaningful if the outputs of the rows are classes.
+	Classification *Report
+
+	// Regression holds the full regression report of the held out rows.
+	Regression *RegressionReport
+
+	// History holds the training history of the fold's network.
+	History *TrainingHistory
+}
+
+// CrossValidationResult holds the results of a cross validation.
+type CrossValidationResult struct {
+	// Folds holds the results of each fold.
+	Folds []FoldResult
+
+	// Mean holds the mean of each metric of the folds. A metric that is NaN
+	// for a fold, such as the ROCAUC of a fold holding a single class, is left
+	// out of its mean, which is only NaN if the metric is NaN for every fold.
+	Mean FoldMetrics
+
+	// StdDev holds the standard deviation of each metric of the folds, leaving
+	// out the folds for which the metric is NaN as Mean does.
+	StdDev FoldMetrics
+}
+
+// CrossValidate performs k-fold cross validation.
+//
+// The rows of the training data are shuffled and divided into k folds, using
+// a new generator created from the Seed or Source of the training data, or
+// from those of the configuration if neither is set. The generator of the
+// training data is not used, so cross validation does not change the rows a
+// later call to Train chooses, although a Source is advanced.
+//
+// For each fold, a fresh network is created from the configuration with New,
+// trained on the rows of the other k-1 folds, and scored against the rows of
+// the held out fold with Evaluate and EvaluateRegression. The loss of the held
+// out rows is weighted by the sample and class weights used in training. The
+// Split, iterations, batch size, early stopping and
+// other training settings of the training data are used for each fold. The
+// held out fold takes the place of the test rows, so TestSplit is ignored,
+// and no checkpoints are written.
+//
+// Parameters:
+// - config: The configuration used to create the network for each fold.
+// - td: The training data holding the rows and training settings.
+// - k: The number of folds.
+//
+// Returns:
+// - A pointer to the results of each fold and their aggregate.
+// - An error if k is out of range or a fold cannot be trained or scored.
+func CrossValidate(config *NetworkConfiguration, td *TrainingData, k int) (*CrossValidationResult, error) {
+	return crossValidate(config, td, k, false)
+}
+
+// StratifiedCrossValidate performs k-fold cross validation, as CrossValidate,
+// with folds that each hold the same proportion of every class.
+//
+// The class of a row with a single output is 0 if the output is below 0.5 and
+// 1 otherwise. For several outputs it is the index of the largest output, so
+// the outputs are expected to be one hot encoded.
+//
+// Parameters:
+// - config: The configuration used to create the network for each fold.
+// - td: The training data holding the rows and training settings.
+// - k: The number of folds.
+//
+// Returns:
+// - A pointer to the results of each fold and their aggregate.
+// - An error if k is out of range or a fold cannot be trained or scored.
+func StratifiedCrossValidate(config *NetworkConfiguration, td *TrainingData, k int) (*CrossValidationResult, error) {
+	return crossValidate(config, td, k, true)
+}
+
+// crossValidate performs k-fold cross validation.
+//
+// Parameters:
+// - config: The configuration used to create the network for each fold.
+// - td: The training data holding the rows and training settings.
+// - k: The number of folds.
+// - stratified: Whether each fold should hold the same proportion of every class.
+//
+// Returns:
+// - A pointer to the results of each fold and their aggregate.
+// - An error if k is out of range or a fold cannot be trained or scored.
+func crossValidate(config *NetworkConfiguration, td *TrainingData, k int, stratified bool) (*CrossValidationResult, error) {
+	if k < 2 {
+		return nil, errors.New("cross validation error: at least two folds are required")
+	}
+	if td.TrainingSource != nil {
+		return nil, errors.New("cross validation error: training sources are not supported")
+	}
+	if k > len(td.Data) {
+		return nil, fmt.Errorf("cross validation error: %v folds requested for %v rows", k, len(td.Data))
+	}
+
+	// Shuffle the rows with a generator of their own, created from the data's
+	// seed or source if it has one, otherwise from the configuration's, so
+	// the data's generator and the split it gives Train are left untouched.
+	index := make([]int, len(td.Data))
+	for i := range index {
+		index[i] = i
+	}
+	rng := newRandom(config.Seed, config.Source)
+	if td.Seed != 0 || td.Source != nil {
+		rng = newRandom(td.Seed, td.Source)
+	}
+	shuffle(rng.rng, index)
+
+	// Group the rows by class, keeping the shuffled order within each class,
+	// so that dealing them out spreads every class evenly over the folds.
+	if stratified {
+		sort.SliceStable(index, func(i, j int) bool {
+			return class(td.Data[index[i]].Ouput) < class(td.Data[index[j]].Ouput)
+		})
+	}
+
+	// Deal the rows out to the folds in turn.
+	folds := make([][]*DataRow, k)
+	for i, idx := range index {
+		folds[i%k] = append(folds[i%k], td.Data[idx])
+	}
+
+	res := &CrossValidationResult{}
+	for f := range folds {
+		// Train a fresh network on the rows of the other folds.
+		n, err := New(config)
+		if err != nil {
+			return res, fmt.Errorf("cross validation error: fold %v: %v", f, err)
+		}
+		ftd := td.fold(folds, f)
+		h, err := n.Train(ftd)
+		if err != nil {
+			return res, fmt.Errorf("cross validation error: fold %v: %v", f, err)
+		}
+
+		// Score the network against the held out fold.
+		r := FoldResult{
+			Fold:       f,
+			TrainCount: len(ftd.Data),
+			TestCount:  len(folds[f]),
+			History:    h,
+		}
+		if r.Loss, err = n.weightedError(ftd, folds[f]); err != nil {
+			return res, fmt.Errorf("cross validation error: fold %v: %v", f, err)
+		}
+		if r.Classification, err = Evaluate(n, folds[f]); err != nil {
+			return res, fmt.Errorf("cross validation error: fold %v: %v", f, err)
+		}
+		if r.Regression, err = EvaluateRegression(n, folds[f]); err != nil {
+			return res, fmt.Errorf("cross validation error: fold %v: %v", f, err)
+		}
+		r.Accuracy = r.Classification.Accuracy
+		r.MacroF1 = r.Classification.MacroF1
+		r.ROCAUC = r.Classification.ROCAUC
+		r.LogLoss = r.Classification.LogLoss
+		r.RMSE = r.Regression.RMSE
+		r.MAE = r.Regression.MAE
+		r.R2 = r.Regression.R2
+		res.Folds = append(res.Folds, r)
+	}
+
+	// Calculate the mean and standard deviation of each metric, leaving out
+	// the folds for which it is NaN.
+	means, stdDevs := res.Mean.values(), res.StdDev.values()
+	for m := range means {
+		var vs []float64
+		for f := range res.Folds {
+			if v := *res.Folds[f].values()[m]; !math.IsNaN(v) {
+				vs = append(vs, v)
+			}
+		}
+		*means[m], *stdDevs[m] = meanStdDev(vs)
+	}
+	return res, nil
+}
+
+// weightedError calculates the average error of the network's predictions for
+// a set of rows, with each error weighted by the weight of its row in the
+// training data, as it is when validating.
+//
+// Parameters:
+// - td: The training data the network was trained with.
+// - rows: The rows to predict.
+//
+// Returns:
+// - The average weighted error of the rows.
+// - An error if a row cannot be predicted.
+func (n *Network) weightedError(td *TrainingData, rows []*DataRow) (float64, error) {
+	var sum float64
+	for _, row := range rows {
+		v, err := n.rowError(row)
+		if err != nil {
+			return 0, err
+		}
+		sum += v * td.weight(row)
+	}
+	return sum / float64(len(rows)), nil
+}
+
+// meanStdDev calculates the mean and standard deviation of a set of values.
+//
+// Parameters:
+// - vs: The values.
+//
+// Returns:
+// - The mean, or NaN if there are no values.
+// - The standard deviation, or NaN if there are no values.
+func meanStdDev(vs []float64) (float64, float64) {
+	if len(vs) == 0 {
+		return math.NaN(), math.NaN()
+	}
+	var mean, variance float64
+	for _, v := range vs {
+		mean += v
+	}
+	mean /= float64(len(vs))
+	for _, v := range vs {
+		variance += (v - mean) * (v - mean)
+	}
+	return mean, math.Sqrt(variance / float64(len(vs)))
+}
+
+// fold creates the training data for one fold of a cross validation, holding
+// the rows of every other fold and the training settings of d.
+//
+// folds holds the rows of each fold and held is the index of the held out fold.
+// Returns a pointer to the training data.
+func (d *TrainingData) fold(folds [][]*DataRow, held int) *TrainingData {
+	res := &TrainingData{
+		Split:          d.Split,
+		Iterations:     d.Iterations,
+		TargetError:    d.TargetError,
+		BatchSize:      d.BatchSize,
+		EarlyStopping:  d.EarlyStopping,
+		Seed:           d.Seed,
+		Source:         d.Source,
+		Shuffle:        d.Shuffle,
+		SplitName:      d.SplitName,
+		ClassWeights:   d.ClassWeights,
+		BalanceClasses: d.BalanceClasses,
+	}
+	for i, rows := range folds {
+		if i != held {
+			res.Data = append(res.Data, rows...)
+		}
+	}
+	return res
+}
+
+// argmax returns the index of the largest value.
+//
+// Parameters:
+// - vs: The values.
+//
+// Returns:
+// - The index of the first of the largest values, or -1 if there are no values.
+func argmax(vs []float64) int {
+	best := -1
+	for i, v := range vs {
+		if best < 0 || v > vs[best] {
+			best = i
+		}
+	}
+	return best
+}
@@ -0,0 +1,145 @@
+package jasper
+
+import (
+	"math"
+	"testing"
+)
+
+// crossValidationData returns training data holding rows in two classes,
+// every fourth row being in class 1.
+func crossValidationData(rows int) *TrainingData {
+	td := NewTrainingData(5, 0.8, 0)
+	for i := range rows {
+		cls := 0.0
+		if i%4 == 0 {
+			cls = 1
+		}
+		td.AddRow([]float64{cls + 0.1*float64(i%3), float64(i % 2)}, []float64{1 - cls, cls})
+	}
+	return td
+}
+
+// crossValidationConfig returns the configuration of a small classifier.
+func crossValidationConfig() *NetworkConfiguration {
+	c := NewConfig([]uint32{2, 4, 2})
+	c.Seed = 3
+	c.Quiet = true
+	c.SoftMax = true
+	c.Output = Linear
+	c.Error = CategoricalCrossEntropy
+	return c
+}
+
+func TestCrossValidateFolds(t *testing.T) {
+	td := crossValidationData(10)
+	r, err := CrossValidate(crossValidationConfig(), td, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []int{4, 3, 3}
+	if len(r.Folds) != len(want) {
+		t.Fatalf("got %v folds, want %v", len(r.Folds), len(want))
+	}
+	for i, f := range r.Folds {
+		if f.Fold != i || f.TestCount != want[i] || f.TrainCount != 10-want[i] {
+			t.Errorf("fold %v: fold %v, %v held out and %v trained, want %v and %v", i, f.Fold, f.TestCount, f.TrainCount, want[i], 10-want[i])
+		}
+		if f.Classification == nil || f.Regression == nil || f.History == nil {
+			t.Fatalf("fold %v: missing reports", i)
+		}
+		if f.Accuracy != f.Classification.Accuracy || f.RMSE != f.Regression.RMSE || f.Classification.Rows != f.TestCount {
+			t.Errorf("fold %v: metrics do not match the reports", i)
+		}
+	}
+	if len(td.Data) != 10 {
+		t.Errorf("training data holds %v rows, want 10", len(td.Data))
+	}
+}
+
+func TestStratifiedCrossValidate(t *testing.T) {
+	r, err := StratifiedCrossValidate(crossValidationConfig(), crossValidationData(12), 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range r.Folds {
+		if got := f.Classification.Support; got[0] != 3 || got[1] != 1 {
+			t.Errorf("fold %v: classes %v, want [3 1]", f.Fold, got)
+		}
+	}
+}
+
+func TestCrossValidateAggregate(t *testing.T) {
+	r, err := CrossValidate(crossValidationConfig(), crossValidationData(12), 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var vs []float64
+	for _, f := range r.Folds {
+		vs = append(vs, f.Loss)
+	}
+	mean, stdDev := meanStdDev(vs)
+	if !near(r.Mean.Loss, mean) || !near(r.StdDev.Loss, stdDev) {
+		t.Errorf("loss = %v ± %v, want %v ± %v", r.Mean.Loss, r.StdDev.Loss, mean, stdDev)
+	}
+}
+
+func TestCrossValidateNaN(t *testing.T) {
+	// The first fold holds only class 0, so its ROCAUC is NaN.
+	r, err := StratifiedCrossValidate(crossValidationConfig(), crossValidationData(6), 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !math.IsNaN(r.Folds[0].ROCAUC) {
+		t.Fatalf("ROCAUC of the first fold = %v, want NaN", r.Folds[0].ROCAUC)
+	}
+	mean, stdDev := meanStdDev([]float64{r.Folds[1].ROCAUC, r.Folds[2].ROCAUC})
+	if !near(r.Mean.ROCAUC, mean) || !near(r.StdDev.ROCAUC, stdDev) {
+		t.Errorf("ROCAUC = %v ± %v, want %v ± %v", r.Mean.ROCAUC, r.StdDev.ROCAUC, mean, stdDev)
+	}
+	if mean, _ := meanStdDev(nil); !math.IsNaN(mean) {
+		t.Errorf("mean of no values = %v, want NaN", mean)
+	}
+}
+
+func TestCrossValidateWeights(t *testing.T) {
+	// Without learning the weights only change the score of each fold.
+	c := crossValidationConfig()
+	c.LearningRate = 0
+	td := crossValidationData(12)
+	td.Iterations = 1
+	plain, err := CrossValidate(c, td, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	td.ClassWeights = []float64{2, 2}
+	weighted, err := CrossValidate(c, td, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range plain.Folds {
+		if !near(weighted.Folds[i].Loss, 2*plain.Folds[i].Loss) {
+			t.Errorf("fold %v: weighted loss %v, want %v", i, weighted.Folds[i].Loss, 2*plain.Folds[i].Loss)
+		}
+		if weighted.Folds[i].Accuracy != plain.Folds[i].Accuracy {
+			t.Errorf("fold %v: the weights changed the predictions", i)
+		}
+	}
+}
+
+func TestCrossValidateErrors(t *testing.T) {
+	c := crossValidationConfig()
+	tests := []struct {
+		name string
+		td   *TrainingData
+		k    int
+	}{
+		{"one fold", crossValidationData(10), 1},
+		{"too many folds", crossValidationData(3), 4},
+		{"training source", &TrainingData{TrainingSource: NewSliceSource(xorRows())}, 2},
+	}
+	for _, tt := range tests {
+		if _, err := CrossValidate(c, tt.td, tt.k); err == nil {
+			t.Errorf("%v: expected an error", tt.name)
+		}
+	}
+}
@@ -0,0 +1,589 @@
+// csv.go - Loading training data from CSV files.
+//
+// # Copyright 2024 Mark Oxley
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package jasper
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// MissingValue is an enumeration of the ways a missing value in a CSV column
+// can be handled.
+type MissingValue int
+
+const (
+	// DropMissing drops any row with a missing value in the column.
+	DropMissing MissingValue = iota
+	// MeanMissing replaces a missing value with the mean of the column over
+	// the rows that are not dropped. For a categorical column, each element of
+	// the one hot vector is replaced with the proportion of those rows in that
+	// category.
+	MeanMissing
+	// ConstantMissing replaces a missing value with the column's Fill value.
+	// For a categorical column, every element of the one hot vector is set to
+	// the Fill value.
+	ConstantMissing
+)
+
+// CSVHeader is an enumeration of the ways the first record of a CSV file can
+// be treated.
+type CSVHeader int
+
+const (
+	// DetectHeader treats the first record as a header if it looks like one.
+	// It does if every field is text, or if a field is text where the field
+	// below it is a number.
+	DetectHeader CSVHeader = iota
+	// HasHeader treats the first record as a header.
+	HasHeader
+	// NoHeader treats the first record as data.
+	NoHeader
+)
+
+// CSVColumn maps a column of a CSV file to values of the inputs or outputs.
+type CSVColumn struct {
+	// Name is the header of the column. If the file has no header, the
+	// columns are named by their zero based position, "0", "1" and so on.
+	Name string
+
+	// Categorical indicates that the column holds categories, which are
+	// converted to a one hot vector with an element for each category.
+	Categorical bool
+
+	// Categories holds the categories of a categorical column, in the order of
+	// the elements of the one hot vector. If nil, the categories found in the
+	// data are used in sorted order.
+	Categories []string
+
+	// Missing sets how missing values in the column are handled.
+	Missing MissingValue
+
+	// Fill is the value used by ConstantMissing.
+	Fill float64
+}
+
+// CSVOptions configures how a CSV file is read.
+type CSVOptions struct {
+	// Delimiter is the field delimiter. If zero, a comma is used.
+	Delimiter rune
+
+	// Comment, if not zero, starts lines that are ignored.
+	Comment rune
+
+	// Header sets how the first record is treated.
+	Header CSVHeader
+
+	// Inputs holds the columns used as the inputs, in order. If nil, every
+	// column that is not an output is used as a numeric input.
+	Inputs []CSVColumn
+
+	// Outputs holds the columns used as the outputs, in order.
+	Outputs []CSVColumn
+
+	// MissingValues holds the field values that are treated as missing, in
+	// addition to empty fields. If nil, "NA", "N/A", "NaN", "null" and "?" are
+	// used. Values are matched ignoring case and surrounding spaces.
+	MissingValues []string
+}
+
+// csvColumn is a column of a CSV file resolved against its header.
+type csvColumn struct {
+	CSVColumn
+
+	// index is the position of the column in each record.
+	index int
+
+	// learn indicates that the categories of a categorical column are found
+	// from the data.
+	learn bool
+
+	// known holds the categories of a categorical column.
+	known map[string]bool
+
+	// counts holds the number of fields in each category of a categorical column.
+	counts map[string]int
+
+	// sum is the sum of the values of a numeric column.
+	sum float64
+
+	// count is the number of fields that are not missing.
+	count int
+
+	// fill holds the values used in place of a missing value.
+	fill []float64
+}
+
+// csvConverter converts the records of a CSV to rows of training data.
+//
+// Every record is first observed, so that the categories and means of the
+// columns can be found, before any record is converted.
+type csvConverter struct {
+	// header indicates that the first record is a header.
+	header bool
+
+	// missing holds the field values treated as missing.
+	missing map[string]bool
+
+	// inputs holds the columns used as the inputs.
+	inputs []*csvColumn
+
+	// outputs holds the columns used as the outputs.
+	outputs []*csvColumn
+}
+
+// ReadCSV reads rows of training data from CSV.
+//
+// Fields may be quoted, and quoted fields may contain delimiters, quotes and
+// line breaks. Numeric columns are parsed as floats, and categorical columns
+// are converted to one hot vectors. The values of each row's inputs and
+// outputs are in the order of the columns in the options.
+//
+// Parameters:
+// - r: The reader to read the CSV from.
+// - opts: The options used to read the CSV.
+//
+// Returns:
+// - A slice of the rows read.
+// - An error if the CSV cannot be read or does not match the options.
+func ReadCSV(r io.Reader, opts CSVOptions) ([]*DataRow, error) {
+	// Read every record, so the categories and means of the columns can be found.
+	cr := newCSVReader(r, opts)
+	var records [][]string
+	var lines []int
+	for {
+		record, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("csv error: %v", err)
+		}
+		line, _ := cr.FieldPos(0)
+		records = append(records, record)
+		lines = append(lines, line)
+	}
+	if len(records) == 0 {
+		return nil, errors.New("csv error: no records")
+	}
+	var second []string
+	if len(records) > 1 {
+		second = records[1]
+	}
+	c, err := newCSVConverter(opts, records[0], second)
+	if err != nil {
+		return nil, err
+	}
+	if c.header {
+		records, lines = records[1:], lines[1:]
+	}
+
+	// Find the categories and means of the columns from the records that are
+	// kept, dropping those with missing values.
+	var kept []int
+	for r, record := range records {
+		keep, err := c.observe(record, lines[r])
+		if err != nil {
+			return nil, err
+		}
+		if keep {
+			kept = append(kept, r)
+		}
+	}
+	c.finish()
+
+	// Build a row from each record that is kept, filling missing values.
+	rows := make([]*DataRow, 0, len(kept))
+	for _, r := range kept {
+		row, err := c.row(records[r], lines[r])
+		if err != nil {
+			return nil, err
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+// newCSVReader creates a reader of the records of a CSV.
+//
+// Parameters:
+// - r: The reader to read the CSV from.
+// - opts: The options used to read the CSV.
+//
+// Returns:
+// - A pointer to the reader.
+func newCSVReader(r io.Reader, opts CSVOptions) *csv.Reader {
+	cr := csv.NewReader(r)
+	cr.Comma = ','
+	if opts.Delimiter != 0 {
+		cr.Comma = opts.Delimiter
+	}
+	cr.Comment = opts.Comment
+	cr.TrimLeadingSpace = true
+	return cr
+}
+
+// newCSVConverter creates a converter for the records of a CSV, naming the
+// columns from the header, or by their positions, and resolving the columns
+// of the options against them.
+//
+// Parameters:
+// - opts: The options used to read the CSV.
+// - first: The first record of the CSV.
+// - second: The second record of the CSV, or nil if there is only one.
+//
+// Returns:
+// - A pointer to the converter.
+// - An error if there are no output columns or a column cannot be found.
+func newCSVConverter(opts CSVOptions, first, second []string) (*csvConverter, error) {
+	if len(opts.Outputs) == 0 {
+		return nil, errors.New("csv error: no output columns")
+	}
+	c := &csvConverter{missing: opts.missingValues()}
+	c.header = opts.Header == HasHeader || (opts.Header == DetectHeader && isHeader(first, second, c.missing))
+	names := make([]string, len(first))
+	for i := range names {
+		names[i] = strconv.Itoa(i)
+		if c.header {
+			names[i] = strings.TrimSpace(first[i])
+		}
+	}
+
+	// Resolve the columns, using every other column as an input if none are given.
+	var err error
+	if c.outputs, err = resolveColumns(opts.Outputs, names); err != nil {
+		return nil, err
+	}
+	in := opts.Inputs
+	if in == nil {
+		used := make(map[int]bool)
+		for _, col := range c.outputs {
+			used[col.index] = true
+		}
+		for i, name := range names {
+			if !used[i] {
+				in = append(in, CSVColumn{Name: name})
+			}
+		}
+	}
+	if c.inputs, err = resolveColumns(in, names); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// columns returns every column of the converter, inputs first.
+//
+// Returns:
+// - A slice of the columns.
+func (c *csvConverter) columns() []*csvColumn {
+	return append(append([]*csvColumn{}, c.inputs...), c.outputs...)
+}
+
+// keep returns true if the row of a record is kept, which it is unless a
+// value is missing from a column that drops rows with missing values.
+//
+// Parameters:
+// - record: The record.
+//
+// Returns:
+// - True if the row is kept.
+func (c *csvConverter) keep(record []string) bool {
+	for _, col := range c.columns() {
+		if col.Missing == DropMissing && c.missing[strings.ToLower(strings.TrimSpace(record[col.index]))] {
+			return false
+		}
+	}
+	return true
+}
+
+// observe adds the fields of a record to the categories and means of the
+// columns, unless the row of the record is dropped, so that missing values
+// are filled from the rows that are kept.
+//
+// Parameters:
+// - record: The record.
+// - line: The line of the record, used in errors.
+//
+// Returns:
+// - False if the row of the record is dropped because a value is missing.
+// - An error if a numeric field cannot be parsed or a category is unknown.
+func (c *csvConverter) observe(record []string, line int) (bool, error) {
+	if !c.keep(record) {
+		return false, nil
+	}
+	for _, col := range c.columns() {
+		if _, err := col.observe(record[col.index], c.missing); err != nil {
+			return false, fmt.Errorf("csv error: line %v, column %q: %v", line, col.Name, err)
+		}
+	}
+	return true, nil
+}
+
+// finish prepares the values used in place of missing values once every
+// record has been observed.
+func (c *csvConverter) finish() {
+	for _, col := range c.columns() {
+		col.finish()
+	}
+}
+
+// row converts a record to a row of training data.
+//
+// Parameters:
+// - record: The record.
+// - line: The line of the record, used in errors.
+//
+// Returns:
+// - A pointer to the row, or nil if a value is missing and the row is dropped.
+// - An error if a numeric field cannot be parsed or a category is unknown.
+func (c *csvConverter) row(record []string, line int) (*DataRow, error) {
+	if !c.keep(record) {
+		return nil, nil
+	}
+	values := func(columns []*csvColumn) ([]float64, bool, error) {
+		var res []float64
+		for _, col := range columns {
+			vs, ok, err := col.value(record[col.index], c.missing)
+			if err != nil {
+				return nil, false, fmt.Errorf("csv error: line %v, column %q: %v", line, col.Name, err)
+			}
+			if !ok {
+				return nil, false, nil
+			}
+			res = append(res, vs...)
+		}
+		return res, true, nil
+	}
+	input, ok, err := values(c.inputs)
+	if err != nil || !ok {
+		return nil, err
+	}
+	output, ok, err := values(c.outputs)
+	if err != nil || !ok {
+		return nil, err
+	}
+	return &DataRow{Input: input, Ouput: output}, nil
+}
+
+// LoadCSV reads rows of training data from a CSV file and adds them to the
+// training data.
+//
+// Parameters:
+// - path: The path of the CSV file.
+// - opts: The options used to read the CSV.
+//
+// Returns:
+// - An error if the file cannot be read or does not match the options.
+func (d *TrainingData) LoadCSV(path string, opts CSVOptions) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("csv error: %v", err)
+	}
+	defer f.Close()
+	rows, err := ReadCSV(f, opts)
+	if err != nil {
+		return err
+	}
+	d.Data = append(d.Data, rows...)
+	return nil
+}
+
+// missingValues returns the field values treated as missing, in lower case.
+//
+// Returns:
+// - A set of the missing values.
+func (o *CSVOptions) missingValues() map[string]bool {
+	values := o.MissingValues
+	if values == nil {
+		values = []string{"NA", "N/A", "NaN", "null", "?"}
+	}
+	res := map[string]bool{"": true}
+	for _, v := range values {
+		res[strings.ToLower(strings.TrimSpace(v))] = true
+	}
+	return res
+}
+
+// isHeader returns true if the first record looks like a header.
+//
+// Parameters:
+// - first: The first record of the CSV.
+// - second: The second record of the CSV, or nil if there is only one.
+// - missing: The set of missing values.
+//
+// Returns:
+// - True if the first record is a header.
+func isHeader(first, second []string, missing map[string]bool) bool {
+	text := func(f string) bool {
+		f = strings.TrimSpace(f)
+		if missing[strings.ToLower(f)] {
+			return false
+		}
+		_, err := strconv.ParseFloat(f, 64)
+		return err != nil
+	}
+	all := true
+	for i, f := range first {
+		if !text(f) {
+			all = false
+			continue
+		}
+		if i < len(second) {
+			next := strings.TrimSpace(second[i])
+			if !missing[strings.ToLower(next)] && !text(next) {
+				return true
+			}
+		}
+	}
+	return all
+}
+
+// resolveColumns finds the position of each column from its name.
+//
+// Parameters:
+// - columns: The columns to resolve.
+// - names: The names of the columns of the CSV, in order.
+//
+// Returns:
+// - A slice of the resolved columns.
+// - An error if a column cannot be found.
+func resolveColumns(columns []CSVColumn, names []string) ([]*csvColumn, error) {
+	res := make([]*csvColumn, len(columns))
+	for i, c := range columns {
+		res[i] = &csvColumn{CSVColumn: c, index: -1}
+		for j, name := range names {
+			if name == c.Name {
+				res[i].index = j
+				break
+			}
+		}
+		if res[i].index < 0 {
+			return nil, fmt.Errorf("csv error: column %q not found", c.Name)
+		}
+	}
+	return res, nil
+}
+
+// observe adds a field of the column to its categories and mean.
+//
+// Parameters:
+// - field: The field.
+// - missing: The set of missing values.
+//
+// Returns:
+// - False if the field is missing.
+// - An error if a numeric field cannot be parsed or a category is unknown.
+func (c *csvColumn) observe(field string, missing map[string]bool) (bool, error) {
+	f := strings.TrimSpace(field)
+	if missing[strings.ToLower(f)] {
+		return false, nil
+	}
+	c.count++
+	if !c.Categorical {
+		v, err := strconv.ParseFloat(f, 64)
+		if err != nil {
+			return false, err
+		}
+		c.sum += v
+		return true, nil
+	}
+
+	// Find the categories if they are not given.
+	if c.known == nil {
+		c.learn = c.Categories == nil
+		c.known = make(map[string]bool)
+		c.counts = make(map[string]int)
+		for _, cat := range c.Categories {
+			c.known[cat] = true
+		}
+	}
+	if !c.known[f] {
+		if !c.learn {
+			return false, fmt.Errorf("unknown category %q", f)
+		}
+		c.known[f] = true
+		c.Categories = append(c.Categories, f)
+	}
+	c.counts[f]++
+	return true, nil
+}
+
+// finish prepares the values used in place of a missing value once every
+// field has been observed.
+func (c *csvColumn) finish() {
+	if !c.Categorical {
+		c.fill = []float64{c.Fill}
+		if c.Missing == MeanMissing && c.count > 0 {
+			c.fill[0] = c.sum / float64(c.count)
+		}
+		return
+	}
+	if c.learn {
+		sort.Strings(c.Categories)
+	}
+
+	// Use the proportion of each category as its mean.
+	c.fill = make([]float64, len(c.Categories))
+	for i, cat := range c.Categories {
+		if c.Missing == ConstantMissing {
+			c.fill[i] = c.Fill
+		} else if c.count > 0 {
+			c.fill[i] = float64(c.counts[cat]) / float64(c.count)
+		}
+	}
+}
+
+// value returns the values of the column for a field.
+//
+// Parameters:
+// - field: The field.
+// - missing: The set of missing values.
+//
+// Returns:
+// - The values of the column, a single value or a one hot vector.
+// - False if the value is missing and the row should be dropped.
+// - An error if a numeric field cannot be parsed or a category is unknown.
+func (c *csvColumn) value(field string, missing map[string]bool) ([]float64, bool, error) {
+	f := strings.TrimSpace(field)
+	if missing[strings.ToLower(f)] {
+		return c.fill, c.Missing != DropMissing, nil
+	}
+	if !c.Categorical {
+		v, err := strconv.ParseFloat(f, 64)
+		if err != nil {
+			return nil, false, err
+		}
+		return []float64{v}, true, nil
+	}
+	res := make([]float64, len(c.Categories))
+	found := false
+	for i, cat := range c.Categories {
+		if cat == f {
+			res[i] = 1
+			found = true
+		}
+	}
+	if !found {
+		return nil, false, fmt.Errorf("unknown category %q", f)
+	}
+	return res, true, nil
+}
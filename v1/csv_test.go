@@ -0,0 +1,232 @@
+package jasper
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// checkRows compares the inputs and outputs of rows with those expected.
+func checkRows(t *testing.T, rows []*DataRow, inputs, outputs [][]float64) {
+	t.Helper()
+	if len(rows) != len(inputs) {
+		t.Fatalf("got %v rows, want %v", len(rows), len(inputs))
+	}
+	for i, row := range rows {
+		if !reflect.DeepEqual(row.Input, inputs[i]) || !reflect.DeepEqual(row.Ouput, outputs[i]) {
+			t.Errorf("row %v = %v %v, want %v %v", i, row.Input, row.Ouput, inputs[i], outputs[i])
+		}
+	}
+}
+
+func TestReadCSVQuoting(t *testing.T) {
+	data := `"size";"name";"weight; kg"
+1.5;"a ""quoted"" name";10
+2;"a name
+over two lines";20
+`
+	rows, err := ReadCSV(strings.NewReader(data), CSVOptions{
+		Delimiter: ';',
+		Inputs:    []CSVColumn{{Name: "size"}, {Name: "name", Categorical: true}},
+		Outputs:   []CSVColumn{{Name: "weight; kg"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkRows(t, rows,
+		[][]float64{{1.5, 1, 0}, {2, 0, 1}},
+		[][]float64{{10}, {20}})
+}
+
+func TestReadCSVComments(t *testing.T) {
+	data := "# readings\nx,y\n1,2\n# skipped\n3,4\n"
+	rows, err := ReadCSV(strings.NewReader(data), CSVOptions{Comment: '#', Outputs: []CSVColumn{{Name: "y"}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkRows(t, rows, [][]float64{{1}, {3}}, [][]float64{{2}, {4}})
+}
+
+func TestReadCSVHeader(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		header  CSVHeader
+		output  string
+		inputs  [][]float64
+		outputs [][]float64
+	}{
+		{"detect text", "a,b\n1,2\n3,4\n", DetectHeader, "b",
+			[][]float64{{1}, {3}}, [][]float64{{2}, {4}}},
+		{"detect numbers", "1,2\n3,4\n", DetectHeader, "1",
+			[][]float64{{1}, {3}}, [][]float64{{2}, {4}}},
+		{"detect text over number", "x,1\n5,6\n", DetectHeader, "1",
+			[][]float64{{5}}, [][]float64{{6}}},
+		{"detect missing", "a,b\n?,2\n3,4\n", DetectHeader, "b",
+			[][]float64{{3}}, [][]float64{{4}}},
+		{"has header", "1,2\n3,4\n", HasHeader, "2",
+			[][]float64{{3}}, [][]float64{{4}}},
+		{"no header", "1,2\n3,4\n", NoHeader, "1",
+			[][]float64{{1}, {3}}, [][]float64{{2}, {4}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rows, err := ReadCSV(strings.NewReader(tt.data), CSVOptions{
+				Header:  tt.header,
+				Outputs: []CSVColumn{{Name: tt.output}},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			checkRows(t, rows, tt.inputs, tt.outputs)
+		})
+	}
+}
+
+func TestReadCSVMissing(t *testing.T) {
+	data := `x,colour,y
+1,red,1
+NA,blue,2
+3,,3
+5,red,?
+`
+	tests := []struct {
+		name    string
+		missing MissingValue
+		fill    float64
+		inputs  [][]float64
+		outputs [][]float64
+	}{
+		{"drop", DropMissing, 0,
+			[][]float64{{1, 1}},
+			[][]float64{{1}}},
+		{"mean", MeanMissing, 0,
+			[][]float64{{1, 0, 1}, {3, 1, 0}, {3, 1.0 / 3, 2.0 / 3}, {5, 0, 1}},
+			[][]float64{{1}, {2}, {3}, {2}}},
+		{"constant", ConstantMissing, -1,
+			[][]float64{{1, 0, 1}, {-1, 1, 0}, {3, -1, -1}, {5, 0, 1}},
+			[][]float64{{1}, {2}, {3}, {-1}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rows, err := ReadCSV(strings.NewReader(data), CSVOptions{
+				Inputs: []CSVColumn{
+					{Name: "x", Missing: tt.missing, Fill: tt.fill},
+					{Name: "colour", Categorical: true, Missing: tt.missing, Fill: tt.fill},
+				},
+				Outputs: []CSVColumn{{Name: "y", Missing: tt.missing, Fill: tt.fill}},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			checkRows(t, rows, tt.inputs, tt.outputs)
+		})
+	}
+}
+
+func TestReadCSVMissingDropped(t *testing.T) {
+	// The last record is dropped, so its value of x and its colour are left
+	// out of the mean and the categories.
+	data := `x,colour,y
+1,red,1
+NA,blue,2
+9,green,NA
+`
+	opts := CSVOptions{
+		Inputs: []CSVColumn{
+			{Name: "x", Missing: MeanMissing},
+			{Name: "colour", Categorical: true},
+		},
+		Outputs: []CSVColumn{{Name: "y"}},
+	}
+	rows, err := ReadCSV(strings.NewReader(data), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkRows(t, rows,
+		[][]float64{{1, 0, 1}, {1, 1, 0}},
+		[][]float64{{1}, {2}})
+
+	// A CSV source fills and drops values in the same way.
+	path := filepath.Join(t.TempDir(), "data.csv")
+	if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	src, err := NewCSVSource(path, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer src.Close()
+	if src.Len() != 2 {
+		t.Errorf("source length = %v, want 2", src.Len())
+	}
+	var streamed []*DataRow
+	for {
+		row, err := src.Next()
+		if err != nil {
+			break
+		}
+		streamed = append(streamed, row)
+	}
+	checkRows(t, streamed,
+		[][]float64{{1, 0, 1}, {1, 1, 0}},
+		[][]float64{{1}, {2}})
+}
+
+func TestReadCSVMissingValues(t *testing.T) {
+	data := "x,y\n1,2\n-999,4\nNA,6\n"
+	_, err := ReadCSV(strings.NewReader(data), CSVOptions{
+		MissingValues: []string{"-999"},
+		Outputs:       []CSVColumn{{Name: "y"}},
+	})
+	if err == nil {
+		t.Error("NA is not missing when MissingValues is set: expected an error")
+	}
+	rows, err := ReadCSV(strings.NewReader(data), CSVOptions{
+		MissingValues: []string{"-999", "na"},
+		Outputs:       []CSVColumn{{Name: "y"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkRows(t, rows, [][]float64{{1}}, [][]float64{{2}})
+}
+
+func TestReadCSVErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		opts CSVOptions
+	}{
+		{"no records", "", CSVOptions{Outputs: []CSVColumn{{Name: "0"}}}},
+		{"no outputs", "1,2\n", CSVOptions{}},
+		{"unknown column", "a,b\n1,2\n", CSVOptions{Outputs: []CSVColumn{{Name: "c"}}}},
+		{"not a number", "a,b\n1,x\n2,3\n", CSVOptions{Outputs: []CSVColumn{{Name: "a"}}}},
+		{"unknown category", "a,b\n1,red\n2,blue\n", CSVOptions{Outputs: []CSVColumn{{Name: "b", Categorical: true, Categories: []string{"red"}}}}},
+		{"ragged", "a,b\n1,2\n3\n", CSVOptions{Outputs: []CSVColumn{{Name: "b"}}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ReadCSV(strings.NewReader(tt.data), tt.opts); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
+
+func TestLoadCSV(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "data.csv")
+	if err := os.WriteFile(path, []byte("x,y\n1,2\n3,4\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	td := &TrainingData{}
+	if err := td.LoadCSV(path, CSVOptions{Outputs: []CSVColumn{{Name: "y"}}}); err != nil {
+		t.Fatal(err)
+	}
+	checkRows(t, td.Data, [][]float64{{1}, {3}}, [][]float64{{2}, {4}})
+	if err := td.LoadCSV(filepath.Join(t.TempDir(), "missing.csv"), CSVOptions{}); err == nil {
+		t.Error("missing file: expected an error")
+	}
+}
@@ -1,167 +1,592 @@
-// data.go - Training and testing data used in the neural network.
-//
-// # Copyright 2024 Mark Oxley
-//
-// Licensed under the Apache License, Version 2.0 (the "License");
-// you may not use this file except in compliance with the License.
-// You may obtain a copy of the License at
-//
-//	http://www.apache.org/licenses/LICENSE-2.0
-//
-// Unless required by applicable law or agreed to in writing, software
-// distributed under the License is distributed on an "AS IS" BASIS,
-// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
-// See the License for the specific language governing permissions and
-// limitations under the License.
-package jasper
-
-import (
-	"math"
-	"math/rand"
-)
-
-type DataRow struct {
-	Input []float64
-	Ouput []float64
-}
-
-type TrainingData struct {
-	trainingData []*DataRow
-	testingData  []*DataRow
-	Data         []*DataRow
-	Split        float64
-	Iterations   uint32
-	TargetError  float64
-	position     int
-}
-
-// NewTrainingData creates a new instance of the TrainingData type.
-//
-// iterations specifies the number of iterations for the training data.
-// split specifies the proportion of the data to be used for training.
-// errMargin specifies the target error margin for the training data.
-// Returns a pointer to the newly created TrainingData instance.
-func NewTrainingData(iterations uint32, split float64, errMargin float64) *TrainingData {
-	return &TrainingData{
-		Split:       split,
-		Iterations:  iterations,
-		TargetError: errMargin,
-	}
-}
-
-// AddRow adds a new data row to the training data set.
-//
-// inputs is the input data for the row, and output is the corresponding output data.
-// No return value.
-func (d *TrainingData) AddRow(inputs, output []float64) {
-	d.Data = append(d.Data, &DataRow{
-		Input: inputs,
-		Ouput: output,
-	})
-}
-
-// prepare prepares the training data by splitting it into training and testing
-// data sets based on the specified split value.
-//
-// The training data set will contain approximately split * len(d.Data) rows.
-// The testing data set will contain the remaining rows.
-//
-// No return value.
-func (d *TrainingData) prepare() {
-	// Determine the number of rows to be used for training
-	trainCount := int(math.Round(float64(len(d.Data)) * d.Split))
-	// Determine the number of rows to be used for testing
-	testCount := len(d.Data) - trainCount
-
-	// Create slices with the appropriate capacities to hold the training and testing data
-	d.trainingData = make([]*DataRow, 0, trainCount)
-	d.testingData = make([]*DataRow, 0, testCount)
-
-	// Create a slice to hold the indices of the data rows
-	index := make([]int, len(d.Data))
-	for i := range index {
-		index[i] = i
-	}
-
-	// Shuffle the indices to randomize the order of the data rows
-	for i := 0; i < len(d.Data); i++ {
-		p1 := rand.Intn(len(d.Data))
-		p2 := rand.Intn(len(d.Data))
-		tmp := index[p1]
-		index[p1] = index[p2]
-		index[p2] = tmp
-	}
-
-	// Append the data rows to the appropriate slice based on their index
-	for i, idx := range index {
-		if i < trainCount {
-			// Append the row to the training data slice
-			d.trainingData = append(d.trainingData, d.Data[idx])
-		} else {
-			// Append the row to the testing data slice
-			d.testingData = append(d.testingData, d.Data[idx])
-		}
-	}
-
-	// Reset the position counter
-	d.position = 0
-}
-
-// nextRow returns the next training data row from the training data slice.
-//
-// If the current position is greater than or equal to the length of the training
-// data slice, it resets the position to 0 and returns nil. Otherwise, it returns
-// the data row at the current position and increments the position for the next
-// call to nextRow.
-//
-// Returns a pointer to a DataRow struct.
-func (d *TrainingData) nextRow() *DataRow {
-	// If the current position is beyond the length of the training data slice,
-	// reset the position to 0 and return nil.
-	if d.position >= len(d.trainingData) {
-		d.position = 0
-		return nil
-	}
-
-	// Defer incrementing the position for the next call to NextRow.
-	defer func() {
-		d.position++
-	}()
-
-	// Return the data row at the current position.
-	return d.trainingData[d.position]
-}
-
-// TestData returns the testing data slice.
-//
-// It contains the data rows that are not used for training.
-//
-// Returns a slice of pointers to DataRow structs.
-func (d *TrainingData) TestData() []*DataRow {
-	// Return the testing data slice.
-	return d.testingData
-}
-
-// TrainingCount returns the number of training rows in the TrainingData struct.
-//
-// This function returns the length of the trainingData slice, which contains
-// the rows of data used for training.
-//
-// Returns an integer representing the number of training rows.
-func (d *TrainingData) TrainingCount() int {
-	// Return the length of the trainingData slice, which contains the rows of
-	// data used for training.
-	return len(d.trainingData)
-}
-
-// TestCount returns the number of testing rows in the TrainingData struct.
-//
-// This function returns the length of the testingData slice, which contains
-// the rows of data not used for training.
-//
-// Returns an integer representing the number of testing rows.
-func (d *TrainingData) TestCount() int {
-	// Return the length of the testingData slice, which contains the rows of
-	// data not used for training.
-	return len(d.testingData)
-}
+// data.go - Training, validation and test data used in the neural network.
+//
+// # Copyright 2024 Mark Oxley
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package jasper
+
+import (
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"math"
+	"math/rand/v2"
+)
+
+type DataRow struct {
+	Input []float64
+	Ouput []float64
+	// Weight scales the row's contribution to the gradient and to the errors
+	// reported by Train, so important or rare rows can count for more. If
+	// zero, the row has a weight of one.
+	Weight float64
+}
+
+// FullBatch can be used as the BatchSize of a TrainingData instance to update
+// the weights once per iteration, using every training row.
+const FullBatch = math.MaxInt
+
+// sourceFitRows is the number of rows read from the start of a training
+// source to fit the network's preprocessors and balance the class weights.
+const sourceFitRows = 10000
+
+type TrainingData struct {
+	trainingData   []*DataRow
+	validationData []*DataRow
+	testData       []*DataRow
+	Data           []*DataRow
+	// Split is the proportion of the rows used for training. The rows that
+	// are not used for training or testing are used for validation, which
+	// drives the tolerance check, early stopping and learning rate schedules.
+	Split float64
+	// TestSplit is the proportion of the rows held out for testing. The test
+	// rows are never used by Train, and are scored once training is finished
+	// by TestLoss, which returns an error if called again. If zero, no rows are held out, and TestData returns the
+	// validation rows as earlier versions did.
+	TestSplit   float64
+	Iterations  uint32
+	TargetError float64
+	// BatchSize is the number of rows fed through the network before the
+	// weights are updated. Zero or one updates the weights after every row.
+	BatchSize int
+	// EarlyStopping, if set, stops training once the monitored value, the
+	// validation error by default, has stopped improving.
+	EarlyStopping *EarlyStopping
+	// Checkpoint, if set, writes snapshots of the training to a directory so
+	// that it can be resumed.
+	Checkpoint *Checkpoint
+	// Seed seeds the random number generator used to shuffle the data. If
+	// zero, and Source is nil, the network's random number generator is used.
+	Seed uint64
+	// Source, if set, is used as the random number generator in place of Seed.
+	Source rand.Source
+	// Shuffle reshuffles the training rows at the start of every iteration,
+	// so they are not presented in the same order each time. It has no effect
+	// on a TrainingSource.
+	Shuffle bool
+	// SplitName, if set, fixes the split into training and validation rows. The
+	// split is chosen using a random number generator seeded from the name
+	// alone, so every run with the same data and name validates and tests
+	// against the same rows, whatever the seeds of the network and data.
+	SplitName string
+	// TrainingSource, if set, provides the training rows in place of Data,
+	// which is then ignored along with Split and TestSplit. The source is read
+	// once per iteration, in batches, so the rows never all need to be held in
+	// memory. Any preprocessors that have not been fitted are fitted, and any
+	// balanced class weights are calculated, from the first 10,000 rows of the
+	// source.
+	TrainingSource DataSource
+	// ValidationSource, if set, provides the validation rows in place of
+	// those split from Data. If nil when training from a TrainingSource, the
+	// training error of each iteration is used in place of the validation
+	// error and a warning is logged, and Train returns an error if
+	// EarlyStopping or the network's learning rate schedule watches the
+	// validation error.
+	ValidationSource DataSource
+	// ClassWeights, if set, scales the contribution of the rows of each class
+	// to the gradient and to the errors reported by Train, on top of each
+	// row's own Weight. The class of a row with a single output is 0 if the
+	// output is below 0.5 and 1 otherwise; for several outputs it is the index
+	// of the largest output.
+	ClassWeights []float64
+	// BalanceClasses, if set and ClassWeights is nil, calculates the class
+	// weights from the frequency of each class in the training rows, so every
+	// class contributes equally however rare it is. The weight of a class is
+	// the number of rows divided by the number of classes times the number of
+	// rows in the class.
+	BalanceClasses bool
+	position       int
+	// order holds the indices of the rows of Data in the order they were
+	// split into the training, validation and test data.
+	order []int
+	// resume holds the checkpoint the next call to Train continues from.
+	resume *checkpointData
+	// random is the random number generator created from Seed or Source.
+	random *random
+	// classWeights holds the class weights used in training.
+	classWeights []float64
+	// pending holds the rows read from the start of the TrainingSource to fit
+	// the network, which begin the next pass over the source.
+	pending []*DataRow
+	// tested is set once TestLoss has scored the held out test rows.
+	tested bool
+}
+
+// NewTrainingData creates a new instance of the TrainingData type.
+//
+// iterations specifies the number of iterations for the training data.
+// split specifies the proportion of the data to be used for training.
+// errMargin specifies the target error margin for the training data.
+// Returns a pointer to the newly created TrainingData instance.
+func NewTrainingData(iterations uint32, split float64, errMargin float64) *TrainingData {
+	return &TrainingData{
+		Split:       split,
+		Iterations:  iterations,
+		TargetError: errMargin,
+	}
+}
+
+// AddRow adds a new data row to the training data set.
+//
+// inputs is the input data for the row, and output is the corresponding output data.
+// No return value.
+func (d *TrainingData) AddRow(inputs, output []float64) {
+	d.Data = append(d.Data, &DataRow{
+		Input: inputs,
+		Ouput: output,
+	})
+}
+
+// prepare prepares the training data by splitting it into training, validation
+// and test data sets based on the specified split values.
+//
+// The test data set will contain approximately TestSplit * len(d.Data) rows.
+// The training data set will contain approximately Split * len(d.Data) rows,
+// or the rows not used for testing if there are fewer.
+// The validation data set will contain the remaining rows.
+//
+// The rows are shuffled with a Fisher–Yates shuffle, using a generator seeded
+// from the SplitName if it is set, otherwise the data's own random number
+// generator if it has a Seed or Source, otherwise rng.
+//
+// If the data has a TrainingSource, there is nothing to split and the
+// in-memory data sets are left empty.
+//
+// Returns an error if there would be no training rows, no validation rows
+// without a ValidationSource, or no test rows when TestSplit is set.
+func (d *TrainingData) prepare(rng *rand.Rand) error {
+	if d.TrainingSource != nil {
+		d.split(nil, 0, 0)
+		return nil
+	}
+
+	// Determine the number of rows to be used for testing and training
+	testCount := min(int(math.Round(float64(len(d.Data))*d.TestSplit)), len(d.Data))
+	trainCount := min(int(math.Round(float64(len(d.Data))*d.Split)), len(d.Data)-testCount)
+	switch {
+	case trainCount == 0:
+		return fmt.Errorf("split error: no training rows from %v rows", len(d.Data))
+	case trainCount+testCount == len(d.Data) && d.ValidationSource == nil:
+		return fmt.Errorf("split error: no validation rows from %v rows", len(d.Data))
+	case testCount == 0 && d.TestSplit > 0:
+		return fmt.Errorf("split error: no test rows from %v rows", len(d.Data))
+	}
+
+	// Create a slice to hold the indices of the data rows
+	index := make([]int, len(d.Data))
+	for i := range index {
+		index[i] = i
+	}
+
+	// Shuffle the indices to randomize the order of the data rows
+	if d.SplitName != "" {
+		h := fnv.New64a()
+		h.Write([]byte(d.SplitName))
+		rng = newRandom(h.Sum64(), nil).rng
+	} else {
+		rng = d.rng(rng)
+	}
+	shuffle(rng, index)
+
+	// Split the data rows in the shuffled order
+	d.split(index, trainCount, testCount)
+	return nil
+}
+
+// shuffle reorders the training rows with a Fisher–Yates shuffle.
+//
+// The rows are shuffled using the data's own random number generator if it
+// has a Seed or Source, otherwise using rng.
+//
+// No return value.
+func (d *TrainingData) shuffle(rng *rand.Rand) {
+	// Shuffle the indices of the training rows, keeping the order so the
+	// shuffled rows can be saved in a checkpoint.
+	train := d.order[:len(d.trainingData)]
+	shuffle(d.rng(rng), train)
+	for i, idx := range train {
+		d.trainingData[i] = d.Data[idx]
+	}
+}
+
+// shuffle randomly reorders a slice of indices with a Fisher–Yates shuffle,
+// giving every order the same chance.
+//
+// rng is the random number generator and index holds the indices to shuffle.
+// No return value.
+func shuffle(rng *rand.Rand, index []int) {
+	for i := len(index) - 1; i > 0; i-- {
+		j := rng.IntN(i + 1)
+		index[i], index[j] = index[j], index[i]
+	}
+}
+
+// rng returns the random number generator used with the data.
+//
+// fallback is returned if the data has neither a Seed nor a Source.
+// Returns the random number generator.
+func (d *TrainingData) rng(fallback *rand.Rand) *rand.Rand {
+	if d.random == nil && (d.Seed != 0 || d.Source != nil) {
+		d.random = newRandom(d.Seed, d.Source)
+	}
+	if d.random == nil {
+		return fallback
+	}
+	return d.random.rng
+}
+
+// split divides the data rows into the training, validation and test data sets.
+//
+// index holds the indices of the data rows in the order they are to be used.
+// The first trainCount of them are used for training, the last testCount for
+// testing, and the rest for validation.
+//
+// No return value.
+func (d *TrainingData) split(index []int, trainCount, testCount int) {
+	// Create slices with the appropriate capacities to hold the training, validation and test data
+	validationEnd := len(index) - testCount
+	d.trainingData = make([]*DataRow, 0, trainCount)
+	d.validationData = make([]*DataRow, 0, validationEnd-trainCount)
+	d.testData = make([]*DataRow, 0, testCount)
+
+	// Append the data rows to the appropriate slice based on their index
+	for i, idx := range index {
+		if i < trainCount {
+			// Append the row to the training data slice
+			d.trainingData = append(d.trainingData, d.Data[idx])
+		} else if i >= validationEnd {
+			// Append the row to the test data slice
+			d.testData = append(d.testData, d.Data[idx])
+		} else {
+			// Append the row to the validation data slice
+			d.validationData = append(d.validationData, d.Data[idx])
+		}
+	}
+
+	// Keep the order so that the split can be saved
+	d.order = index
+
+	// Reset the position counter
+	d.position = 0
+}
+
+// nextBatch returns the next batch of training data rows from the training
+// data slice, or from the TrainingSource if there is one.
+//
+// The batch holds up to BatchSize rows, starting at the current position. If
+// the current position is greater than or equal to the length of the training
+// data slice, it resets the position to 0 and returns nil. Otherwise, it
+// returns the rows of the batch and advances the position past them for the
+// next call to nextBatch.
+//
+// Returns a slice of pointers to DataRow structs, and an error if a row cannot
+// be read from the TrainingSource.
+func (d *TrainingData) nextBatch() ([]*DataRow, error) {
+	// Treat a batch size of zero as one.
+	size := max(d.BatchSize, 1)
+
+	// Read the batch from the source, after any rows already read from the
+	// start of the pass, stopping at the end of the pass.
+	if d.TrainingSource != nil {
+		n := min(size, len(d.pending))
+		batch := d.pending[:n:n]
+		d.pending = d.pending[n:]
+		if len(d.pending) == 0 {
+			d.pending = nil
+		}
+		for len(batch) < size {
+			row, err := d.TrainingSource.Next()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return nil, err
+			}
+			batch = append(batch, row)
+		}
+		return batch, nil
+	}
+
+	// If the current position is beyond the length of the training data slice,
+	// reset the position to 0 and return nil.
+	if d.position >= len(d.trainingData) {
+		d.position = 0
+		return nil, nil
+	}
+
+	// Determine the end of the batch, stopping at the end of the training data.
+	end := len(d.trainingData)
+	if size < end-d.position {
+		end = d.position + size
+	}
+
+	// Defer moving the position for the next call to nextBatch.
+	defer func() {
+		d.position = end
+	}()
+
+	// Return the data rows of the batch.
+	return d.trainingData[d.position:end], nil
+}
+
+// rewind starts a new pass over the TrainingSource, if there is one. A pass
+// begun by sourceRows is continued instead, so that its rows are trained on.
+//
+// Returns an error, ErrSourceExhausted if the source has no more data.
+func (d *TrainingData) rewind() error {
+	if d.TrainingSource == nil || d.pending != nil {
+		return nil
+	}
+	return d.TrainingSource.Reset()
+}
+
+// sourceRows reads rows from the start of a new pass over the TrainingSource.
+// The source is not reset after they are read, as a source such as a
+// ChannelSource cannot provide them again, so the rows are kept to begin the
+// pass trained on by the next iteration.
+//
+// count is the largest number of rows to read.
+// Returns a slice of the rows read, and an error if the source cannot be read.
+func (d *TrainingData) sourceRows(count int) ([]*DataRow, error) {
+	d.pending = nil
+	if err := d.TrainingSource.Reset(); err != nil {
+		return nil, err
+	}
+	var rows []*DataRow
+	for len(rows) < count {
+		row, err := d.TrainingSource.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		rows = append(rows, row)
+	}
+	d.pending = rows
+	return rows, nil
+}
+
+// weighClasses sets the class weights used in training, from ClassWeights or
+// from the frequency of each class in the rows if BalanceClasses is set.
+//
+// rows holds the training rows.
+// No return value.
+func (d *TrainingData) weighClasses(rows []*DataRow) {
+	d.classWeights = d.ClassWeights
+	if d.classWeights != nil || !d.BalanceClasses || len(rows) == 0 {
+		return
+	}
+
+	// Count the rows of each class.
+	var counts []int
+	for _, row := range rows {
+		c := class(row.Ouput)
+		for len(counts) <= c {
+			counts = append(counts, 0)
+		}
+		counts[c]++
+	}
+	classes := 0
+	for _, count := range counts {
+		if count > 0 {
+			classes++
+		}
+	}
+
+	// Weigh each class inversely to its frequency.
+	d.classWeights = make([]float64, len(counts))
+	for c, count := range counts {
+		if count > 0 {
+			d.classWeights[c] = float64(len(rows)) / float64(classes*count)
+		}
+	}
+}
+
+// weights returns the weight of each row of a batch, combining the weight of
+// the row with the weight of its class.
+//
+// rows is the batch of data rows.
+// Returns a slice of the weights, or nil if every row has a weight of one.
+func (d *TrainingData) weights(rows []*DataRow) []float64 {
+	var res []float64
+	for i, row := range rows {
+		w := d.weight(row)
+		if w != 1 && res == nil {
+			res = make([]float64, len(rows))
+			for j := range i {
+				res[j] = 1
+			}
+		}
+		if res != nil {
+			res[i] = w
+		}
+	}
+	return res
+}
+
+// weight returns the weight of a row, combining the weight of the row with the
+// weight of its class.
+//
+// row is the data row.
+// Returns the weight.
+func (d *TrainingData) weight(row *DataRow) float64 {
+	w := 1.0
+	if row.Weight != 0 {
+		w = row.Weight
+	}
+	if c := class(row.Ouput); c >= 0 && c < len(d.classWeights) {
+		w *= d.classWeights[c]
+	}
+	return w
+}
+
+// class returns the class of a row from its outputs.
+//
+// For a single output the class is 0 if the output is below 0.5 and 1
+// otherwise. For several outputs it is the index of the largest output.
+//
+// outputs holds the output values of the row.
+// Returns the class, or -1 if there are no outputs.
+func class(outputs []float64) int {
+	if len(outputs) == 1 {
+		if outputs[0] < 0.5 {
+			return 0
+		}
+		return 1
+	}
+	return argmax(outputs)
+}
+
+// rowMatrices creates the input and output matrices for a batch of rows.
+//
+// Each row of the returned matrices holds the values of one data row.
+//
+// rows is the batch of data rows.
+// Returns the input matrix, the output matrix and an error if the rows are not
+// all the same size.
+func rowMatrices(rows []*DataRow) (*Matrix, *Matrix, error) {
+	// Size the matrices from the first row.
+	inputs := NewMatrix(uint32(len(rows[0].Input)), uint32(len(rows)))
+	outputs := NewMatrix(uint32(len(rows[0].Ouput)), uint32(len(rows)))
+
+	// Copy the values of each row into the matrices.
+	for i, row := range rows {
+		if len(row.Input) != int(inputs.cols) {
+			return nil, nil, errors.New("incorrect input size")
+		}
+		if len(row.Ouput) != int(outputs.cols) {
+			return nil, nil, errors.New("output is incorrect size")
+		}
+		copy(inputs.values[i*int(inputs.cols):], row.Input)
+		copy(outputs.values[i*int(outputs.cols):], row.Ouput)
+	}
+	return inputs, outputs, nil
+}
+
+// ValidationData returns the validation data slice.
+//
+// It contains the data rows that are not used for training or testing, which
+// are used to check the progress of the training.
+//
+// Returns a slice of pointers to DataRow structs.
+func (d *TrainingData) ValidationData() []*DataRow {
+	// Return the validation data slice.
+	return d.validationData
+}
+
+// validationRows returns the validation rows, reading every row of the
+// ValidationSource if there is one.
+//
+// Returns a slice of the rows, and an error if the source cannot be read.
+func (d *TrainingData) validationRows() ([]*DataRow, error) {
+	if d.ValidationSource == nil {
+		return d.validationData, nil
+	}
+	if err := d.ValidationSource.Reset(); err != nil {
+		return nil, err
+	}
+	var rows []*DataRow
+	for {
+		row, err := d.ValidationSource.Next()
+		if err == io.EOF {
+			return rows, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		rows = append(rows, row)
+	}
+}
+
+// TrainingCount returns the number of training rows in the TrainingData struct.
+//
+// This function returns the length of the trainingData slice, which contains
+// the rows of data used for training.
+//
+// Returns an integer representing the number of training rows. If the data
+// has a TrainingSource, the length of the source is returned, or -1 if it is
+// not a SizedDataSource.
+func (d *TrainingData) TrainingCount() int {
+	if d.TrainingSource != nil {
+		return sourceLen(d.TrainingSource)
+	}
+
+	// Return the length of the trainingData slice, which contains the rows of
+	// data used for training.
+	return len(d.trainingData)
+}
+
+// ValidationCount returns the number of validation rows in the TrainingData struct.
+//
+// This function returns the length of the validationData slice, which contains
+// the rows of data not used for training or testing.
+//
+// Returns an integer representing the number of validation rows. If the data
+// has a ValidationSource, the length of the source is returned, or -1 if it is
+// not a SizedDataSource.
+func (d *TrainingData) ValidationCount() int {
+	if d.ValidationSource != nil {
+		return sourceLen(d.ValidationSource)
+	}
+
+	// Return the length of the validationData slice, which contains the rows of
+	// data not used for training or testing.
+	return len(d.validationData)
+}
+
+// TestData returns the test data slice.
+//
+// It contains the data rows held out from training and validation by
+// TestSplit, which are scored by TestLoss. If TestSplit is zero, no rows are
+// held out and it contains every row not used for training, which are the
+// validation rows.
+//
+// Returns a slice of pointers to DataRow structs.
+func (d *TrainingData) TestData() []*DataRow {
+	// Return the rows not used for training if none are held out for testing.
+	if d.TestSplit == 0 {
+		return d.validationData
+	}
+	return d.testData
+}
+
+// TestCount returns the number of test rows in the TrainingData struct.
+//
+// This function returns the length of the slice returned by TestData.
+//
+// Returns an integer representing the number of test rows.
+func (d *TrainingData) TestCount() int {
+	return len(d.TestData())
+}
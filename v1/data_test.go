@@ -0,0 +1,395 @@
+package jasper
+
+import (
+	"cmp"
+	"reflect"
+	"slices"
+	"testing"
+)
+
+// splitData returns training data holding the given number of rows, each
+// with a distinct input.
+func splitData(rows int, split, testSplit float64) *TrainingData {
+	td := NewTrainingData(5, split, 0)
+	td.TestSplit = testSplit
+	td.Seed = 1
+	for i := range rows {
+		td.AddRow([]float64{float64(i)}, []float64{float64(i % 2)})
+	}
+	return td
+}
+
+func TestTrainingDataSplit(t *testing.T) {
+	tests := []struct {
+		name                   string
+		split, testSplit       float64
+		train, validate, tests int
+	}{
+		{"three way", 0.6, 0.25, 12, 3, 5},
+		{"small test", 0.7, 0.2, 14, 2, 4},
+		{"no test rows", 0.75, 0, 15, 5, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			td := splitData(20, tt.split, tt.testSplit)
+			if err := td.prepare(nil); err != nil {
+				t.Fatal(err)
+			}
+			if len(td.trainingData) != tt.train || len(td.validationData) != tt.validate || len(td.testData) != tt.tests {
+				t.Fatalf("split %v/%v/%v, want %v/%v/%v", len(td.trainingData), len(td.validationData), len(td.testData), tt.train, tt.validate, tt.tests)
+			}
+
+			// Every row is in exactly one of the sets.
+			seen := map[*DataRow]int{}
+			for _, rows := range [][]*DataRow{td.trainingData, td.validationData, td.testData} {
+				for _, row := range rows {
+					seen[row]++
+				}
+			}
+			for _, row := range td.Data {
+				if seen[row] != 1 {
+					t.Fatalf("row %v is in %v sets", row.Input, seen[row])
+				}
+			}
+		})
+	}
+}
+
+func TestTrainingDataSplitErrors(t *testing.T) {
+	tests := []struct {
+		name             string
+		rows             int
+		split, testSplit float64
+	}{
+		{"no training rows", 4, 0.1, 0},
+		{"no validation rows", 4, 1, 0},
+		{"no validation rows after testing", 4, 0.75, 0.25},
+		{"no test rows", 4, 0.5, 0.1},
+	}
+	for _, tt := range tests {
+		if err := splitData(tt.rows, tt.split, tt.testSplit).prepare(nil); err == nil {
+			t.Errorf("%v: expected an error", tt.name)
+		}
+	}
+}
+
+func TestTestLoss(t *testing.T) {
+	c := NewConfig([]uint32{1, 2, 1})
+	c.Seed = 1
+	c.Quiet = true
+	n, err := New(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	td := splitData(20, 0.6, 0.25)
+	if _, err := n.TestLoss(td); err == nil {
+		t.Error("before training: expected an error")
+	}
+	if _, err := n.Train(td); err != nil {
+		t.Fatal(err)
+	}
+	want, err := n.averageError(td.testData)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := n.TestLoss(td)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != want {
+		t.Errorf("test loss = %v, want %v", got, want)
+	}
+	if _, err := n.TestLoss(td); err == nil {
+		t.Error("second call: expected an error")
+	}
+
+	// Without test rows the validation rows are scored, as often as needed.
+	td = splitData(20, 0.75, 0)
+	if _, err := n.Train(td); err != nil {
+		t.Fatal(err)
+	}
+	want, err = n.averageError(td.ValidationData())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for range 2 {
+		if got, err := n.TestLoss(td); err != nil || got != want {
+			t.Errorf("validation loss = %v, %v, want %v", got, err, want)
+		}
+	}
+}
+
+// batchSizes reads the batches of one pass over the training rows, checking
+// that every row is read exactly once.
+func batchSizes(t *testing.T, td *TrainingData, rows []*DataRow) []int {
+	t.Helper()
+	if err := td.rewind(); err != nil {
+		t.Fatal(err)
+	}
+	var sizes []int
+	seen := map[*DataRow]int{}
+	for {
+		batch, err := td.nextBatch()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(batch) == 0 {
+			break
+		}
+		sizes = append(sizes, len(batch))
+		for _, row := range batch {
+			seen[row]++
+		}
+	}
+	for _, row := range rows {
+		if seen[row] != 1 {
+			t.Fatalf("row %v read %v times", row.Input, seen[row])
+		}
+	}
+	return sizes
+}
+
+func TestNextBatch(t *testing.T) {
+	tests := []struct {
+		name      string
+		rows      int
+		batchSize int
+		want      []int
+	}{
+		{"short last batch", 10, 4, []int{4, 4, 2}},
+		{"exact batches", 8, 4, []int{4, 4}},
+		{"one batch", 3, 4, []int{3}},
+		{"zero is one", 3, 0, []int{1, 1, 1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rows := splitData(tt.rows, 1, 0).Data
+			td := &TrainingData{BatchSize: tt.batchSize, trainingData: rows}
+			source := &TrainingData{BatchSize: tt.batchSize, TrainingSource: NewSliceSource(rows)}
+
+			// Every pass is batched in the same way, including a pass over
+			// the source that begins with rows read ahead to fit the network.
+			for pass := range 2 {
+				if got := batchSizes(t, td, rows); !reflect.DeepEqual(got, tt.want) {
+					t.Errorf("pass %v: batches %v, want %v", pass, got, tt.want)
+				}
+				if _, err := source.sourceRows(2); err != nil {
+					t.Fatal(err)
+				}
+				if got := batchSizes(t, source, rows); !reflect.DeepEqual(got, tt.want) {
+					t.Errorf("source pass %v: batches %v, want %v", pass, got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestShuffle(t *testing.T) {
+	// Every order of three indices is equally likely.
+	rng := newRandom(1, nil).rng
+	counts := map[[3]int]int{}
+	const trials = 6000
+	for range trials {
+		index := []int{0, 1, 2}
+		shuffle(rng, index)
+		counts[[3]int(index)]++
+	}
+	if len(counts) != 6 {
+		t.Fatalf("%v orders, want 6: %v", len(counts), counts)
+	}
+	for order, count := range counts {
+		if count < trials/6*9/10 || count > trials/6*11/10 {
+			t.Errorf("order %v shuffled %v times, want about %v", order, count, trials/6)
+		}
+	}
+
+	// Reshuffling the training rows only changes their order.
+	td := splitData(20, 0.5, 0.25)
+	if err := td.prepare(rng); err != nil {
+		t.Fatal(err)
+	}
+	before := slices.Clone(td.trainingData)
+	validation := slices.Clone(td.validationData)
+	td.shuffle(rng)
+	if slices.Equal(td.trainingData, before) {
+		t.Error("the training rows were not reordered")
+	}
+	for i, idx := range td.order[:len(td.trainingData)] {
+		if td.trainingData[i] != td.Data[idx] {
+			t.Fatalf("training row %v does not match its index %v", i, idx)
+		}
+	}
+	slices.SortFunc(before, compareRows)
+	after := slices.Clone(td.trainingData)
+	slices.SortFunc(after, compareRows)
+	if !slices.Equal(after, before) || !slices.Equal(td.validationData, validation) {
+		t.Error("the rows of the sets changed")
+	}
+}
+
+// compareRows orders rows by their first input.
+func compareRows(a, b *DataRow) int {
+	return cmp.Compare(a.Input[0], b.Input[0])
+}
+
+func TestSplitName(t *testing.T) {
+	// held returns the inputs of the rows held out from training.
+	held := func(name string, seed uint64) []float64 {
+		td := splitData(20, 0.5, 0.25)
+		td.SplitName = name
+		td.Seed = seed
+		if err := td.prepare(newRandom(seed, nil).rng); err != nil {
+			t.Fatal(err)
+		}
+		var res []float64
+		for _, row := range append(td.validationData, td.testData...) {
+			res = append(res, row.Input[0])
+		}
+		return res
+	}
+	want := held("run", 1)
+	if got := held("run", 2); !slices.Equal(got, want) {
+		t.Errorf("held out %v, want %v", got, want)
+	}
+	if got := held("other", 1); slices.Equal(got, want) {
+		t.Error("a different name held out the same rows")
+	}
+	if slices.Equal(held("", 1), held("", 2)) {
+		t.Error("unnamed splits with different seeds held out the same rows")
+	}
+}
+
+func TestWeighClasses(t *testing.T) {
+	binary := func(outputs ...float64) []*DataRow {
+		var rows []*DataRow
+		for _, o := range outputs {
+			rows = append(rows, &DataRow{Input: []float64{0}, Ouput: []float64{o}})
+		}
+		return rows
+	}
+	tests := []struct {
+		name string
+		td   *TrainingData
+		rows []*DataRow
+		want []float64
+	}{
+		{"unweighted", &TrainingData{}, binary(0, 0, 1), nil},
+		{"balanced", &TrainingData{BalanceClasses: true}, binary(0, 0, 0, 0, 0, 0, 1, 1), []float64{8.0 / 12, 2}},
+		{"given", &TrainingData{ClassWeights: []float64{1, 5}, BalanceClasses: true}, binary(0, 1), []float64{1, 5}},
+		{"one hot", &TrainingData{BalanceClasses: true}, []*DataRow{
+			{Ouput: []float64{1, 0, 0}},
+			{Ouput: []float64{1, 0, 0}},
+			{Ouput: []float64{0, 0, 1}},
+		}, []float64{0.75, 0, 1.5}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.td.weighClasses(tt.rows)
+			if len(tt.td.classWeights) != len(tt.want) {
+				t.Fatalf("got %v, want %v", tt.td.classWeights, tt.want)
+			}
+			for c := range tt.want {
+				if !near(tt.td.classWeights[c], tt.want[c]) {
+					t.Fatalf("got %v, want %v", tt.td.classWeights, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestRowWeights(t *testing.T) {
+	td := &TrainingData{classWeights: []float64{1, 3}}
+	rows := []*DataRow{
+		{Ouput: []float64{0}},
+		{Ouput: []float64{0}, Weight: 2},
+		{Ouput: []float64{1}},
+		{Ouput: []float64{1}, Weight: 0.5},
+	}
+	if got, want := td.weights(rows), []float64{1, 2, 3, 1.5}; !reflect.DeepEqual(got, want) {
+		t.Errorf("weights %v, want %v", got, want)
+	}
+	if got := (&TrainingData{}).weights(rows[:1]); got != nil {
+		t.Errorf("weights %v, want nil for rows of weight one", got)
+	}
+}
+
+// weightedTraining trains a network with plain gradient descent for a single
+// iteration over one batch, with every row given the same weight.
+func weightedTraining(t *testing.T, rate, weight float64, classWeights []float64) (*Network, *TrainingHistory) {
+	t.Helper()
+	c := NewConfig([]uint32{2, 3, 1})
+	c.Seed = 5
+	c.Quiet = true
+	c.LearningRate = rate
+	n, err := New(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rows := xorRows()
+	for _, row := range rows {
+		row.Weight = weight
+	}
+	td := &TrainingData{
+		TrainingSource:   NewSliceSource(rows),
+		ValidationSource: NewSliceSource(rows),
+		Iterations:       1,
+		BatchSize:        len(rows),
+		ClassWeights:     classWeights,
+	}
+	h, err := n.Train(td)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return n, h
+}
+
+func TestWeightsScaleLoss(t *testing.T) {
+	// Without learning only the reported errors change.
+	_, plain := weightedTraining(t, 0, 0, nil)
+	tests := []struct {
+		name         string
+		weight       float64
+		classWeights []float64
+		scale        float64
+	}{
+		{"sample weights", 2, nil, 2},
+		{"class weights", 0, []float64{3, 3}, 3},
+		{"both", 2, []float64{3, 3}, 6},
+	}
+	for _, tt := range tests {
+		_, h := weightedTraining(t, 0, tt.weight, tt.classWeights)
+		got, want := h.Epochs[0], plain.Epochs[0]
+		if !near(got.TrainLoss, tt.scale*want.TrainLoss) || !near(got.ValidationLoss, tt.scale*want.ValidationLoss) {
+			t.Errorf("%v: losses %v and %v, want %v and %v", tt.name, got.TrainLoss, got.ValidationLoss, tt.scale*want.TrainLoss, tt.scale*want.ValidationLoss)
+		}
+	}
+}
+
+func TestWeightsScaleGradient(t *testing.T) {
+	// Doubling the weight of every row takes the same step as doubling the
+	// learning rate.
+	weighted, _ := weightedTraining(t, 0.5, 2, nil)
+	doubled, _ := weightedTraining(t, 1, 0, nil)
+	for _, row := range xorRows() {
+		got, err := weighted.Predict(row.Input)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want, err := doubled.Predict(row.Input)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !near(got[0], want[0]) {
+			t.Errorf("prediction of %v = %v, want %v", row.Input, got, want)
+		}
+	}
+
+	// The weight of a class only scales the rows of the class.
+	plain, _ := weightedTraining(t, 0.5, 0, nil)
+	weighted, _ = weightedTraining(t, 0.5, 0, []float64{1, 4})
+	p, _ := plain.Predict([]float64{0, 1})
+	w, _ := weighted.Predict([]float64{0, 1})
+	if w[0] <= p[0] {
+		t.Errorf("prediction for class 1 = %v, want more than %v", w[0], p[0])
+	}
+}
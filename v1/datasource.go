@@ -0,0 +1,340 @@
+// datasource.go - Sources of training data that are read a row at a time.
+//
+// # Copyright 2024 Mark Oxley
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package jasper
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+)
+
+// ErrSourceExhausted is returned by the Reset function of a DataSource that
+// has no more data to provide. Train stops when its training source returns
+// it, with a stop reason of SourceExhausted.
+var ErrSourceExhausted = errors.New("data source exhausted")
+
+// DataSource provides rows of data one at a time, so that data too large to
+// hold in memory can be used for training.
+//
+// Train resets its training source at the start of every iteration and reads
+// rows from it until it returns io.EOF. The rows Train reads first to fit the
+// network's preprocessors and class weights are trained on in the first
+// iteration, so a source need not be able to provide the same rows again.
+type DataSource interface {
+	// Next returns the next row of data, or io.EOF once every row of the
+	// current pass has been returned.
+	Next() (*DataRow, error)
+
+	// Reset starts a new pass over the data. It returns ErrSourceExhausted if
+	// there is no more data.
+	Reset() error
+}
+
+// SizedDataSource is a DataSource that knows how many rows it provides in
+// each pass.
+type SizedDataSource interface {
+	DataSource
+
+	// Len returns the number of rows in each pass.
+	Len() int
+}
+
+// sourceLen returns the number of rows a source provides in each pass.
+//
+// Parameters:
+// - s: The source.
+//
+// Returns:
+// - The number of rows, or -1 if the source does not know.
+func sourceLen(s DataSource) int {
+	if sized, ok := s.(SizedDataSource); ok {
+		return sized.Len()
+	}
+	return -1
+}
+
+// SliceSource is a DataSource that provides the rows of a slice held in memory.
+type SliceSource struct {
+	rows     []*DataRow
+	position int
+}
+
+// NewSliceSource creates a source that provides the rows of a slice.
+//
+// Parameters:
+// - rows: The rows to provide.
+//
+// Returns:
+// - A pointer to the source.
+func NewSliceSource(rows []*DataRow) *SliceSource {
+	return &SliceSource{rows: rows}
+}
+
+// Next returns the next row of the slice.
+//
+// Returns:
+// - A pointer to the row.
+// - io.EOF once every row has been returned.
+func (s *SliceSource) Next() (*DataRow, error) {
+	if s.position >= len(s.rows) {
+		return nil, io.EOF
+	}
+	s.position++
+	return s.rows[s.position-1], nil
+}
+
+// Reset starts again from the first row of the slice.
+//
+// Returns:
+// - A nil error.
+func (s *SliceSource) Reset() error {
+	s.position = 0
+	return nil
+}
+
+// Len returns the number of rows in the slice.
+func (s *SliceSource) Len() int {
+	return len(s.rows)
+}
+
+// CSVSource is a DataSource that reads rows from a CSV file as they are
+// needed, rather than holding the whole file in memory.
+//
+// The file is read once when the source is created, to find the categories and
+// means of the columns, and again on every pass. The source should be closed
+// once it is no longer needed.
+type CSVSource struct {
+	opts      CSVOptions
+	file      *os.File
+	reader    *csv.Reader
+	converter *csvConverter
+	count     int
+}
+
+// NewCSVSource creates a source that reads rows from a CSV file.
+//
+// The file is read as ReadCSV reads it, but only a record at a time.
+//
+// Parameters:
+// - path: The path of the CSV file.
+// - opts: The options used to read the CSV.
+//
+// Returns:
+// - A pointer to the source.
+// - An error if the file cannot be read or does not match the options.
+func NewCSVSource(path string, opts CSVOptions) (*CSVSource, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("csv error: %v", err)
+	}
+	s := &CSVSource{opts: opts, file: f}
+	if err := s.scan(); err != nil {
+		f.Close()
+		return nil, err
+	}
+	if err := s.Reset(); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// scan reads the file once, resolving the columns and finding their
+// categories and means, and counts the rows it provides.
+//
+// Returns:
+// - An error if the file cannot be read or does not match the options.
+func (s *CSVSource) scan() error {
+	cr := newCSVReader(s.file, s.opts)
+	read := func() ([]string, int, error) {
+		record, err := cr.Read()
+		if err == io.EOF {
+			return nil, 0, nil
+		}
+		if err != nil {
+			return nil, 0, fmt.Errorf("csv error: %v", err)
+		}
+		line, _ := cr.FieldPos(0)
+		return record, line, nil
+	}
+
+	// Read the first two records to find the header.
+	first, firstLine, err := read()
+	if err != nil {
+		return err
+	}
+	if first == nil {
+		return errors.New("csv error: no records")
+	}
+	second, secondLine, err := read()
+	if err != nil {
+		return err
+	}
+	if s.converter, err = newCSVConverter(s.opts, first, second); err != nil {
+		return err
+	}
+
+	// Observe every data record, starting with those already read.
+	pending := [][]string{first, second}
+	lines := []int{firstLine, secondLine}
+	if s.converter.header {
+		pending, lines = pending[1:], lines[1:]
+	}
+	for {
+		var record []string
+		var line int
+		if len(pending) > 0 {
+			record, line = pending[0], lines[0]
+			pending, lines = pending[1:], lines[1:]
+		} else if record, line, err = read(); err != nil {
+			return err
+		}
+		if record == nil {
+			break
+		}
+		keep, err := s.converter.observe(record, line)
+		if err != nil {
+			return err
+		}
+		if keep {
+			s.count++
+		}
+	}
+	s.converter.finish()
+	return nil
+}
+
+// Next reads the next row from the file, skipping rows that are dropped
+// because of missing values.
+//
+// Returns:
+// - A pointer to the row.
+// - io.EOF once the end of the file is reached.
+// - An error if a record cannot be read or converted.
+func (s *CSVSource) Next() (*DataRow, error) {
+	for {
+		record, err := s.reader.Read()
+		if err == io.EOF {
+			return nil, io.EOF
+		}
+		if err != nil {
+			return nil, fmt.Errorf("csv error: %v", err)
+		}
+		line, _ := s.reader.FieldPos(0)
+		row, err := s.converter.row(record, line)
+		if err != nil {
+			return nil, err
+		}
+		if row != nil {
+			return row, nil
+		}
+	}
+}
+
+// Reset starts again from the first row of the file.
+//
+// Returns:
+// - An error if the file cannot be read.
+func (s *CSVSource) Reset() error {
+	if _, err := s.file.Seek(0, io.SeekStart); err != nil {
+		return fmt.Errorf("csv error: %v", err)
+	}
+	s.reader = newCSVReader(s.file, s.opts)
+	if s.converter.header {
+		if _, err := s.reader.Read(); err != nil {
+			return fmt.Errorf("csv error: %v", err)
+		}
+	}
+	return nil
+}
+
+// Len returns the number of rows in the file, not counting dropped rows.
+func (s *CSVSource) Len() int {
+	return s.count
+}
+
+// Close closes the file.
+//
+// Returns:
+// - An error if the file cannot be closed.
+func (s *CSVSource) Close() error {
+	return s.file.Close()
+}
+
+// ChannelSource is a DataSource that provides rows sent on a channel, such as
+// rows produced by another goroutine.
+//
+// A channel cannot be read again, so each pass provides the next rows sent.
+// A pass ends after a fixed number of rows, or when a nil row is sent. Once
+// the channel is closed, the current pass ends and Reset returns
+// ErrSourceExhausted.
+type ChannelSource struct {
+	ch     <-chan *DataRow
+	size   int
+	count  int
+	ended  bool
+	closed bool
+}
+
+// NewChannelSource creates a source that provides rows sent on a channel.
+//
+// Parameters:
+// - ch: The channel the rows are sent on.
+// - size: The number of rows in each pass, or zero to end each pass when a nil
+// row is sent.
+//
+// Returns:
+// - A pointer to the source.
+func NewChannelSource(ch <-chan *DataRow, size int) *ChannelSource {
+	return &ChannelSource{ch: ch, size: size}
+}
+
+// Next waits for the next row sent on the channel.
+//
+// Returns:
+// - A pointer to the row.
+// - io.EOF at the end of the pass, or once the channel is closed.
+func (s *ChannelSource) Next() (*DataRow, error) {
+	if s.closed || s.ended || (s.size > 0 && s.count >= s.size) {
+		return nil, io.EOF
+	}
+	row, ok := <-s.ch
+	if !ok {
+		s.closed = true
+		return nil, io.EOF
+	}
+	if row == nil {
+		s.ended = true
+		return nil, io.EOF
+	}
+	s.count++
+	return row, nil
+}
+
+// Reset starts the next pass.
+//
+// Returns:
+// - ErrSourceExhausted if the channel has been closed.
+func (s *ChannelSource) Reset() error {
+	if s.closed {
+		return ErrSourceExhausted
+	}
+	s.count = 0
+	s.ended = false
+	return nil
+}
@@ -0,0 +1,239 @@
+package jasper
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// streamRows returns the rows of a small classification problem.
+func streamRows() []*DataRow {
+	var rows []*DataRow
+	for i := range 12 {
+		x, y := float64(i%4)/4, float64(i%3)/3
+		z := 0.0
+		if x+y > 0.6 {
+			z = 1
+		}
+		rows = append(rows, &DataRow{Input: []float64{x, y}, Ouput: []float64{z}})
+	}
+	return rows
+}
+
+// rowValues returns the inputs and outputs of each row.
+func rowValues(rows []*DataRow) [][]float64 {
+	var res [][]float64
+	for _, row := range rows {
+		res = append(res, append(append([]float64{}, row.Input...), row.Ouput...))
+	}
+	return res
+}
+
+// writeSourceCSV writes rows to a CSV file with the columns x, y and z.
+func writeSourceCSV(t *testing.T, rows []*DataRow) string {
+	t.Helper()
+	var b strings.Builder
+	b.WriteString("x,y,z\n")
+	for _, vs := range rowValues(rows) {
+		for i, v := range vs {
+			if i > 0 {
+				b.WriteByte(',')
+			}
+			b.WriteString(strconv.FormatFloat(v, 'g', -1, 64))
+		}
+		b.WriteByte('\n')
+	}
+	path := filepath.Join(t.TempDir(), "rows.csv")
+	if err := os.WriteFile(path, []byte(b.String()), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// channelSource returns a source fed with the given number of passes over
+// the rows, each ended by a nil row.
+func channelSource(rows []*DataRow, passes int) *ChannelSource {
+	ch := make(chan *DataRow)
+	go func() {
+		for range passes {
+			for _, row := range rows {
+				ch <- row
+			}
+			ch <- nil
+		}
+		close(ch)
+	}()
+	return NewChannelSource(ch, 0)
+}
+
+// sourceTraining trains a fresh network from training data, in a single
+// batch so the order of the rows does not matter, returning the history.
+func sourceTraining(t *testing.T, td *TrainingData) *TrainingHistory {
+	t.Helper()
+	c := NewConfig([]uint32{2, 4, 1})
+	c.Seed = 3
+	c.Quiet = true
+	n, err := New(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	td.Iterations = 20
+	td.BatchSize = len(streamRows())
+	h, err := n.Train(td)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return h
+}
+
+func TestDataSourceTraining(t *testing.T) {
+	rows := streamRows()
+	validation := streamRows()[:4]
+	want := sourceTraining(t, &TrainingData{Data: rows, Split: 1, ValidationSource: NewSliceSource(validation)})
+
+	path := writeSourceCSV(t, rows)
+	csv, err := NewCSVSource(path, CSVOptions{
+		Inputs:  []CSVColumn{{Name: "x"}, {Name: "y"}},
+		Outputs: []CSVColumn{{Name: "z"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer csv.Close()
+	tests := []struct {
+		name   string
+		source DataSource
+	}{
+		{"slice", NewSliceSource(rows)},
+		{"csv", csv},
+		{"channel", channelSource(rows, 20)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := sourceTraining(t, &TrainingData{TrainingSource: tt.source, ValidationSource: NewSliceSource(validation)})
+			if len(got.Epochs) != len(want.Epochs) || got.StopReason != want.StopReason {
+				t.Fatalf("%v iterations, stopped by %v, want %v, stopped by %v", len(got.Epochs), got.StopReason, len(want.Epochs), want.StopReason)
+			}
+			for i := range got.Epochs {
+				if !near(got.Epochs[i].TrainLoss, want.Epochs[i].TrainLoss) || !near(got.Epochs[i].ValidationLoss, want.Epochs[i].ValidationLoss) {
+					t.Fatalf("iteration %v: %+v, want %+v", i, got.Epochs[i], want.Epochs[i])
+				}
+			}
+		})
+	}
+}
+
+func TestDataSourceRows(t *testing.T) {
+	rows := streamRows()
+	csv, err := NewCSVSource(writeSourceCSV(t, rows), CSVOptions{
+		Inputs:  []CSVColumn{{Name: "x"}, {Name: "y"}},
+		Outputs: []CSVColumn{{Name: "z"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer csv.Close()
+	sized := make(chan *DataRow, 2*len(rows))
+	for range 2 {
+		for _, row := range rows {
+			sized <- row
+		}
+	}
+	tests := []struct {
+		name   string
+		source DataSource
+	}{
+		{"slice", NewSliceSource(rows)},
+		{"csv", csv},
+		{"channel", channelSource(rows, 2)},
+		{"sized channel", NewChannelSource(sized, len(rows))},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Every pass provides the rows in order.
+			for pass := range 2 {
+				if err := tt.source.Reset(); err != nil {
+					t.Fatal(err)
+				}
+				var got []*DataRow
+				for {
+					row, err := tt.source.Next()
+					if err == io.EOF {
+						break
+					}
+					if err != nil {
+						t.Fatal(err)
+					}
+					got = append(got, row)
+				}
+				if !reflect.DeepEqual(got, rows) {
+					t.Fatalf("pass %v: got %v, want %v", pass, rowValues(got), rowValues(rows))
+				}
+			}
+			if n := sourceLen(tt.source); n != -1 && n != len(rows) {
+				t.Errorf("length %v, want %v", n, len(rows))
+			}
+		})
+	}
+}
+
+func TestDataSourceExhausted(t *testing.T) {
+	h := sourceTraining(t, &TrainingData{TrainingSource: channelSource(streamRows(), 3), ValidationSource: NewSliceSource(streamRows())})
+	if h.StopReason != SourceExhausted || len(h.Epochs) != 3 {
+		t.Errorf("stopped by %v after %v iterations, want source exhausted after 3", h.StopReason, len(h.Epochs))
+	}
+}
+
+func TestObservesLoss(t *testing.T) {
+	tests := []struct {
+		name     string
+		schedule LearningRateSchedule
+		want     bool
+	}{
+		{"none", nil, false},
+		{"step", NewStepDecay(0.5, 10), false},
+		{"plateau", NewReduceOnPlateau(0.5, 1), true},
+		{"warmup", NewLinearWarmup(5, NewStepDecay(0.5, 10)), false},
+		{"warmup plateau", NewLinearWarmup(5, NewReduceOnPlateau(0.5, 1)), true},
+	}
+	for _, tt := range tests {
+		if got := observesLoss(tt.schedule); got != tt.want {
+			t.Errorf("%v: got %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestDataSourceWithoutValidation(t *testing.T) {
+	// The training error stands in for the validation error, so nothing may
+	// watch the validation error.
+	tests := []struct {
+		name     string
+		stopping *EarlyStopping
+		schedule LearningRateSchedule
+		fails    bool
+	}{
+		{"plain", nil, nil, false},
+		{"watching training", &EarlyStopping{Monitor: MonitorTrainLoss, Patience: 2}, nil, false},
+		{"watching validation", NewEarlyStopping(2, 0, false), nil, true},
+		{"plateau", nil, NewReduceOnPlateau(0.5, 1), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := NewConfig([]uint32{2, 4, 1})
+			c.Quiet = true
+			c.Schedule = tt.schedule
+			n, err := New(c)
+			if err != nil {
+				t.Fatal(err)
+			}
+			td := &TrainingData{TrainingSource: NewSliceSource(streamRows()), Iterations: 3, EarlyStopping: tt.stopping}
+			if _, err := n.Train(td); (err != nil) != tt.fails {
+				t.Errorf("error %v, want an error %v", err, tt.fails)
+			}
+		})
+	}
+}
@@ -0,0 +1,342 @@
+// dense.go - Fully connected layer used in the neural network.
+//
+// # Copyright 2024 Mark Oxley
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package jasper
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+)
+
+// Dense is a fully connected layer. Each output is the activation function
+// applied to a weighted sum of all of the inputs plus a bias.
+type Dense struct {
+	// units is the number of neurons in the layer.
+	units uint32
+
+	// activation is the activation function of the layer.
+	activation ActivationFunction
+
+	// solver is the solver for the activation function.
+	solver activationSolver
+
+	// weights holds the weight matrix, with a row for each input and a column
+	// for each neuron.
+	weights *Parameter
+
+	// bias holds the single row bias matrix, with a column for each neuron.
+	bias *Parameter
+
+	// weightInit is the initializer used to create the weights.
+	weightInit Initializer
+
+	// biasInit is the initializer used to create the biases.
+	biasInit Initializer
+
+	// initValue is the value used by the Constant initializer.
+	initValue float64
+
+	// regularization is the regularization of the layer, or nil to use the
+	// network's regularization.
+	regularization *Regularization
+
+	// rng is the random number generator used by the initializers, or nil to
+	// use the shared generator.
+	rng *rand.Rand
+
+	// input holds the input of the most recent call to Forward.
+	input *Matrix
+
+	// sum holds the weighted sums of the most recent call to Forward, before
+	// the activation function is applied. The derivative of the activation
+	// function is evaluated at these values.
+	sum *Matrix
+}
+
+// NewDense creates a fully connected layer.
+//
+// Parameters:
+// - units: The number of neurons in the layer.
+// - activation: The activation function of the layer.
+//
+// Returns:
+// - A pointer to the layer.
+func NewDense(units uint32, activation ActivationFunction) *Dense {
+	return &Dense{
+		units:      units,
+		activation: activation,
+		solver:     getActivationFunctions(activation),
+	}
+}
+
+// Activation returns the activation function of the layer.
+//
+// Returns:
+// - The activation function.
+func (d *Dense) Activation() ActivationFunction {
+	return d.activation
+}
+
+// SetInitializers sets how the weights and biases are given their starting
+// values when the layer is built. It has no effect once the layer has been
+// built.
+//
+// Parameters:
+// - weights: The initializer for the weights.
+// - bias: The initializer for the biases.
+// - value: The value used by the Constant initializer.
+func (d *Dense) SetInitializers(weights, bias Initializer, value float64) {
+	d.weightInit = weights
+	d.biasInit = bias
+	d.initValue = value
+}
+
+// SetRegularization sets the regularization of the layer's weights and biases,
+// in place of the network's regularization.
+//
+// Parameters:
+// - r: The regularization, or nil to use the network's regularization.
+func (d *Dense) SetRegularization(r *Regularization) {
+	d.regularization = r
+	d.tagParameters()
+}
+
+// tagParameters marks the biases and sets the regularization of the layer's
+// parameters.
+func (d *Dense) tagParameters() {
+	if d.weights == nil {
+		return
+	}
+	d.weights.Regularization = d.regularization
+	d.bias.Regularization = d.regularization
+	d.bias.Bias = true
+}
+
+// Build prepares the layer to receive inputs of the given width, creating
+// initialised weights and biases if they do not already exist with the right
+// dimensions.
+//
+// Parameters:
+// - inputs: The number of values in each input row.
+//
+// Returns:
+// - The number of neurons in the layer.
+// - An error if the layer has no neurons, or an unknown activation function or initializer.
+func (d *Dense) Build(inputs uint32) (uint32, error) {
+	if d.units == 0 {
+		return 0, errors.New("dense layer has no neurons")
+	}
+	if d.solver == nil {
+		return 0, fmt.Errorf("unknown activation function: %v", d.activation)
+	}
+	if d.weights != nil && d.weights.Value.rows == inputs && d.weights.Value.cols == d.units {
+		return d.units, d.regularization.validate()
+	}
+	if err := d.regularization.validate(); err != nil {
+		return 0, err
+	}
+	if !d.weightInit.valid() {
+		return 0, fmt.Errorf("unknown weight initializer: %d", d.weightInit)
+	}
+	if !d.biasInit.valid() {
+		return 0, fmt.Errorf("unknown bias initializer: %d", d.biasInit)
+	}
+
+	// Choose the initializers to suit the activation function if required.
+	weightInit := d.weightInit
+	if weightInit == DefaultInitializer {
+		weightInit = defaultWeightInitializer(d.activation)
+	}
+	biasInit := d.biasInit
+	if biasInit == DefaultInitializer {
+		biasInit = Zeros
+	}
+	d.weights = newParameter(weightInit.initialize(d.units, inputs, int(inputs), int(d.units), d.initValue, d.rng))
+	d.bias = newParameter(biasInit.initialize(d.units, 1, int(inputs), int(d.units), d.initValue, d.rng))
+	d.tagParameters()
+	return d.units, nil
+}
+
+// setRandom sets the random number generator used by the initializers.
+//
+// Parameters:
+// - rng: The random number generator.
+func (d *Dense) setRandom(rng *rand.Rand) {
+	d.rng = rng
+}
+
+// Forward feeds a batch of inputs through the layer.
+//
+// Parameters:
+// - input: A matrix holding one row of input values for each sample.
+//
+// Returns:
+// - A matrix holding one row of output values for each sample.
+// - An error if the input is the wrong size.
+func (d *Dense) Forward(input *Matrix) (*Matrix, error) {
+	if d.weights == nil {
+		return nil, errors.New("dense layer has not been built")
+	}
+	if input.cols != d.weights.Value.rows {
+		return nil, errors.New("incorrect input size")
+	}
+	d.input = input
+
+	// Multiply the input values with the weight matrix.
+	values, err := input.Multiply(d.weights.Value)
+	if err != nil {
+		return nil, fmt.Errorf("feed forward error: %v", err)
+	}
+
+	// Add the bias values to each row, keeping the weighted sums for the back propagation.
+	d.sum, err = values.AddToRows(d.bias.Value)
+	if err != nil {
+		return nil, fmt.Errorf("feed forward error: %v", err)
+	}
+
+	// Apply the activation function.
+	return d.sum.ApplyFunction(d.solver.f), nil
+}
+
+// Backward propagates the gradient of the error back through the layer,
+// storing the gradients of the weights and biases.
+//
+// Parameters:
+// - gradient: The gradient of the error with respect to the output of the layer.
+//
+// Returns:
+// - The gradient of the error with respect to the input of the layer.
+// - An error if the gradient is the wrong size.
+func (d *Dense) Backward(gradient *Matrix) (*Matrix, error) {
+	// Apply the derivative of the activation function to the weighted sums.
+	gradients, err := gradient.MultiplyElements(d.sum.ApplyFunction(d.solver.df))
+	if err != nil {
+		return nil, fmt.Errorf("back propagation error: %v", err)
+	}
+
+	// Calculate the weight gradients, summed over the rows of the batch.
+	d.weights.Gradient, err = d.input.Transpose().Multiply(gradients)
+	if err != nil {
+		return nil, fmt.Errorf("back propagation error: %v", err)
+	}
+	d.bias.Gradient = gradients.SumRows()
+
+	// Calculate the error at the previous layer.
+	res, err := gradients.Multiply(d.weights.Value.Transpose())
+	if err != nil {
+		return nil, fmt.Errorf("back propagation error: %v", err)
+	}
+	return res, nil
+}
+
+// Parameters returns the weights and biases of the layer.
+//
+// Returns:
+// - A slice holding the weights and the biases.
+func (d *Dense) Parameters() []*Parameter {
+	if d.weights == nil {
+		return nil
+	}
+	return []*Parameter{d.weights, d.bias}
+}
+
+// OutputSize returns the number of neurons in the layer.
+//
+// Returns:
+// - The number of output values.
+func (d *Dense) OutputSize() uint32 {
+	return d.units
+}
+
+// denseJSON is the saved form of a Dense layer.
+type denseJSON struct {
+	Units          uint32          `json:"u"`
+	Activation     int             `json:"a"`
+	Weights        *Matrix         `json:"w,omitempty"`
+	Bias           *Matrix         `json:"b,omitempty"`
+	WeightsState   *OptimizerState `json:"ws,omitempty"`
+	BiasState      *OptimizerState `json:"bs,omitempty"`
+	WeightInit     int             `json:"wi,omitempty"`
+	BiasInit       int             `json:"bi,omitempty"`
+	InitValue      float64         `json:"iv,omitempty"`
+	Regularization *Regularization `json:"rg,omitempty"`
+}
+
+// MarshalJSON marshals the layer, its parameters and their optimizer state
+// into JSON.
+//
+// Returns:
+// - A JSON byte slice representing the layer.
+// - An error if there is an error during the marshaling process.
+func (d *Dense) MarshalJSON() ([]byte, error) {
+	data := denseJSON{
+		Units:          d.units,
+		Activation:     int(d.activation),
+		WeightInit:     int(d.weightInit),
+		BiasInit:       int(d.biasInit),
+		InitValue:      d.initValue,
+		Regularization: d.regularization,
+	}
+	if d.weights != nil {
+		data.Weights = d.weights.Value
+		data.Bias = d.bias.Value
+		data.WeightsState = d.weights.State
+		data.BiasState = d.bias.State
+	}
+	return json.Marshal(&data)
+}
+
+// UnmarshalJSON unmarshals the layer from JSON.
+//
+// Parameters:
+// - body: The JSON byte slice to unmarshal.
+//
+// Returns:
+// - An error if there is an error during the unmarshaling process.
+func (d *Dense) UnmarshalJSON(body []byte) error {
+	data := denseJSON{}
+	if err := json.Unmarshal(body, &data); err != nil {
+		return err
+	}
+	*d = *NewDense(data.Units, ActivationFunction(data.Activation))
+	d.SetInitializers(Initializer(data.WeightInit), Initializer(data.BiasInit), data.InitValue)
+	if data.Weights != nil && data.Bias != nil {
+		d.setParameters(data.Weights, data.Bias, data.WeightsState, data.BiasState)
+	}
+	d.SetRegularization(data.Regularization)
+	return nil
+}
+
+// setParameters sets the weights and biases of the layer, with their
+// optimizer state.
+//
+// Parameters:
+// - weights: The weight matrix.
+// - bias: The bias matrix.
+// - weightsState: The optimizer state of the weights, or nil.
+// - biasState: The optimizer state of the biases, or nil.
+func (d *Dense) setParameters(weights, bias *Matrix, weightsState, biasState *OptimizerState) {
+	d.weights = newParameter(weights)
+	d.bias = newParameter(bias)
+	if weightsState != nil {
+		d.weights.State = weightsState
+	}
+	if biasState != nil {
+		d.bias.State = biasState
+	}
+	d.tagParameters()
+}
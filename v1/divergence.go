@@ -0,0 +1,219 @@
+// divergence.go - Gradient clipping and checks for divergence during training.
+//
+// # Copyright 2024 Mark Oxley
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package jasper
+
+import (
+	"errors"
+	"fmt"
+	"math"
+)
+
+// Divergence describes where a non-finite value was found while training.
+type Divergence int
+
+const (
+	// ActivationDiverged indicates that the output of a layer was not finite.
+	ActivationDiverged Divergence = iota
+	// LossDiverged indicates that the error of a row was not finite.
+	LossDiverged
+	// GradientDiverged indicates that the gradient of a parameter was not finite.
+	GradientDiverged
+	// ParameterDiverged indicates that a parameter was not finite after it was
+	// updated.
+	ParameterDiverged
+)
+
+// String returns a description of where the non-finite value was found.
+func (d Divergence) String() string {
+	switch d {
+	case ActivationDiverged:
+		return "activation"
+	case LossDiverged:
+		return "loss"
+	case GradientDiverged:
+		return "gradient"
+	case ParameterDiverged:
+		return "parameter"
+	}
+	return "unknown"
+}
+
+// DivergenceError is returned by Train when a NaN or infinite value is found
+// in the activations, error, gradients or parameters of the network, which
+// happens when training diverges. The network is left as it was when the
+// value was found.
+//
+// Divergence can often be avoided by lowering the learning rate or by
+// clipping the gradients with ClipValue or ClipNorm.
+type DivergenceError struct {
+	// Where describes where the value was found.
+	Where Divergence
+
+	// Epoch is the zero based training iteration.
+	Epoch int
+
+	// Batch is the zero based batch of the iteration.
+	Batch int
+
+	// Layer is the index of the layer in the network's model, or -1 for the
+	// error of a row.
+	Layer int
+
+	// Row is the index of the row in the iteration's pass over the training
+	// rows, or -1 for gradients and parameters, which are shared by the rows
+	// of the batch.
+	Row int
+
+	// Value is the non-finite value.
+	Value float64
+}
+
+// Error returns a description of the divergence.
+func (e *DivergenceError) Error() string {
+	res := fmt.Sprintf("training diverged: %v %v at epoch %v, batch %v", e.Where, e.Value, e.Epoch, e.Batch)
+	if e.Layer >= 0 {
+		res += fmt.Sprintf(", layer %v", e.Layer)
+	}
+	if e.Row >= 0 {
+		res += fmt.Sprintf(", row %v", e.Row)
+	}
+	return res
+}
+
+// trainingError prepares an error found while training a batch to be
+// returned by Train.
+//
+// Parameters:
+// - err: The error.
+// - epoch: The zero based training iteration.
+// - batch: The zero based batch of the iteration.
+// - first: The index of the first row of the batch in the iteration's pass.
+//
+// Returns:
+// - The DivergenceError with its position set if err is one, otherwise err
+// described as a training error.
+func trainingError(err error, epoch, batch, first int) error {
+	var de *DivergenceError
+	if !errors.As(err, &de) {
+		return fmt.Errorf("training error: %v", err)
+	}
+	de.Epoch = epoch
+	de.Batch = batch
+	if de.Row >= 0 {
+		de.Row += first
+	}
+	return de
+}
+
+// firstNonFinite returns the index of the first value that is NaN or infinite.
+//
+// Parameters:
+// - values: The values to check.
+//
+// Returns:
+// - The index of the value, or -1 if every value is finite.
+func firstNonFinite(values []float64) int {
+	for k, v := range values {
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			return k
+		}
+	}
+	return -1
+}
+
+// checkForward checks the outputs of each layer, and the error of each row,
+// of the batch most recently fed forward.
+//
+// Parameters:
+// - targets: A matrix holding one row of target output values for each sample.
+//
+// Returns:
+// - A DivergenceError if a value is not finite, with the row within the batch.
+func (n *Network) checkForward(targets *Matrix) error {
+	for l, output := range n.model.outputs {
+		if k := firstNonFinite(output.values); k >= 0 {
+			return &DivergenceError{Where: ActivationDiverged, Layer: l, Row: k / int(output.cols), Value: output.values[k]}
+		}
+	}
+	size := int(targets.cols)
+	for r := 0; r < int(targets.rows); r++ {
+		e := n.errorSolver.e(n.prediction.values[r*size:(r+1)*size], targets.values[r*size:(r+1)*size])
+		if firstNonFinite([]float64{e}) >= 0 {
+			return &DivergenceError{Where: LossDiverged, Layer: -1, Row: r, Value: e}
+		}
+	}
+	return nil
+}
+
+// checkParameters checks the parameters of each layer, or their gradients.
+//
+// Parameters:
+// - gradients: True to check the gradients rather than the values.
+//
+// Returns:
+// - A DivergenceError if a value is not finite.
+func (n *Network) checkParameters(gradients bool) error {
+	for l, layer := range n.model.layers {
+		for _, p := range layer.Parameters() {
+			m, where := p.Value, ParameterDiverged
+			if gradients {
+				m, where = p.Gradient, GradientDiverged
+			}
+			if m == nil {
+				continue
+			}
+			if k := firstNonFinite(m.values); k >= 0 {
+				return &DivergenceError{Where: where, Layer: l, Row: -1, Value: m.values[k]}
+			}
+		}
+	}
+	return nil
+}
+
+// clipGradients limits the gradients of the parameters. Each value is first
+// limited to ClipValue, and then every gradient is scaled down so that their
+// combined length is no more than ClipNorm.
+//
+// Parameters:
+// - params: The parameters.
+func (n *Network) clipGradients(params []*Parameter) {
+	if n.clipValue > 0 {
+		for _, p := range params {
+			for k, v := range p.Gradient.values {
+				p.Gradient.values[k] = min(max(v, -n.clipValue), n.clipValue)
+			}
+		}
+	}
+	if n.clipNorm <= 0 {
+		return
+	}
+	var norm float64
+	for _, p := range params {
+		for _, v := range p.Gradient.values {
+			norm += v * v
+		}
+	}
+	norm = math.Sqrt(norm)
+	if norm <= n.clipNorm {
+		return
+	}
+	scale := n.clipNorm / norm
+	for _, p := range params {
+		for k := range p.Gradient.values {
+			p.Gradient.values[k] *= scale
+		}
+	}
+}
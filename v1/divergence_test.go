@@ -0,0 +1,159 @@
+package jasper
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"testing"
+)
+
+func TestDivergenceActivation(t *testing.T) {
+	c := NewConfig([]uint32{2, 4, 1})
+	c.Seed = 1
+	c.Quiet = true
+	n, err := New(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The second pass over the rows holds an input that cannot be fed forward.
+	ch := make(chan *DataRow)
+	go func() {
+		for pass := range 2 {
+			for k, row := range xorRows() {
+				if pass == 1 && k == 2 {
+					ch <- &DataRow{Input: []float64{math.NaN(), 0}, Ouput: []float64{1}}
+				}
+				ch <- row
+			}
+			ch <- nil
+		}
+		close(ch)
+	}()
+	td := &TrainingData{TrainingSource: NewChannelSource(ch, 0), ValidationSource: NewSliceSource(xorRows()), Iterations: 2, BatchSize: 2}
+	_, err = n.Train(td)
+	var de *DivergenceError
+	if !errors.As(err, &de) {
+		t.Fatalf("error %v, want a divergence error", err)
+	}
+	if de.Where != ActivationDiverged || de.Epoch != 1 || de.Batch != 1 || de.Layer != 0 || de.Row != 2 || !math.IsNaN(de.Value) {
+		t.Errorf("%+v, want a NaN activation at epoch 1, batch 1, layer 0, row 2", *de)
+	}
+}
+
+// divergingRows returns the rows of a regression problem with targets too
+// large to be learnt without clipping the gradients.
+func divergingRows() []*DataRow {
+	var rows []*DataRow
+	for i := range 200 {
+		x := float64(i%100) + 0.5
+		rows = append(rows, &DataRow{Input: []float64{x, x * 2}, Ouput: []float64{x * 50}})
+	}
+	return rows
+}
+
+func TestDivergenceLoss(t *testing.T) {
+	tests := []struct {
+		name     string
+		clipNorm float64
+		diverges bool
+	}{
+		{"unclipped", 0, true},
+		{"clipped", 1, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := NewConfig([]uint32{2, 8, 8, 1})
+			c.Seed = 1
+			c.Quiet = true
+			c.Activation = LeakyRelu
+			c.Output = Linear
+			c.LearningRate = 0.01
+			c.ClipNorm = tt.clipNorm
+			n, err := New(c)
+			if err != nil {
+				t.Fatal(err)
+			}
+			h, err := n.Train(&TrainingData{Data: divergingRows(), Split: 0.8, Iterations: 20})
+			if !tt.diverges {
+				if err != nil || len(h.Epochs) != 20 {
+					t.Errorf("error %v after %v iterations, want 20 iterations", err, len(h.Epochs))
+				}
+				return
+			}
+			var de *DivergenceError
+			if !errors.As(err, &de) {
+				t.Fatalf("error %v, want a divergence error", err)
+			}
+			if de.Layer != -1 || de.Row < 0 || de.Row >= 160 || !math.IsInf(de.Value, 0) && !math.IsNaN(de.Value) {
+				t.Errorf("%+v, want the error of a training row", *de)
+			}
+		})
+	}
+}
+
+func TestClipGradients(t *testing.T) {
+	// The gradients of two parameters, {3, 0.5} and {-4, 0}, have a combined
+	// length of √25.25.
+	scale := 2.5 / math.Sqrt(25.25)
+	tests := []struct {
+		name      string
+		clipValue float64
+		clipNorm  float64
+		want      []float64
+	}{
+		{"none", 0, 0, []float64{3, 0.5, -4, 0}},
+		{"value", 1, 0, []float64{1, 0.5, -1, 0}},
+		{"norm", 0, 2.5, []float64{3 * scale, 0.5 * scale, -4 * scale, 0}},
+		{"norm not reached", 0, 10, []float64{3, 0.5, -4, 0}},
+		{"value then norm", 1, 0.75, []float64{0.5, 0.25, -0.5, 0}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var params []*Parameter
+			for _, g := range [][]float64{{3, 0.5}, {-4, 0}} {
+				p := newParameter(NewMatrix(2, 1))
+				p.Gradient = NewMatrix(2, 1)
+				copy(p.Gradient.values, g)
+				params = append(params, p)
+			}
+			n := &Network{clipValue: tt.clipValue, clipNorm: tt.clipNorm}
+			n.clipGradients(params)
+			got := append(append([]float64{}, params[0].Gradient.values...), params[1].Gradient.values...)
+			for k := range tt.want {
+				if !near(got[k], tt.want[k]) {
+					t.Fatalf("gradients %v, want %v", got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestClipConfig(t *testing.T) {
+	for _, clip := range [][2]float64{{-1, 0}, {0, -1}} {
+		c := NewConfig([]uint32{2, 3, 1})
+		c.ClipValue, c.ClipNorm = clip[0], clip[1]
+		if _, err := New(c); err == nil {
+			t.Errorf("clip value %v and norm %v: expected an error", clip[0], clip[1])
+		}
+	}
+
+	// The limits are kept when the network is saved.
+	c := NewConfig([]uint32{2, 3, 1})
+	c.ClipValue, c.ClipNorm = 0.1, 0.05
+	n, err := New(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, err := json.Marshal(n)
+	if err != nil {
+		t.Fatal(err)
+	}
+	loaded := &Network{}
+	if err := json.Unmarshal(body, loaded); err != nil {
+		t.Fatal(err)
+	}
+	if loaded.clipValue != 0.1 || loaded.clipNorm != 0.05 {
+		t.Errorf("loaded clip value %v and norm %v, want 0.1 and 0.05", loaded.clipValue, loaded.clipNorm)
+	}
+}
@@ -0,0 +1,386 @@
+// dropout.go - Dropout layers used to regularize the neural network.
+//
+// # Copyright 2024 Mark Oxley
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package jasper
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand/v2"
+)
+
+// dropoutJSON is the saved form of a dropout layer.
+type dropoutJSON struct {
+	Rate float64 `json:"r"`
+	Size uint32  `json:"s,omitempty"`
+}
+
+// Dropout is a layer that randomly sets a proportion of its inputs to zero
+// while training, so the network cannot rely on any single neuron.
+//
+// The inputs that are kept are scaled up to make up for those dropped, so the
+// layer passes its inputs through unchanged in inference mode. This is known
+// as inverted dropout.
+type Dropout struct {
+	// rate is the proportion of the inputs dropped.
+	rate float64
+
+	// size is the number of values in each input row, set by Build.
+	size uint32
+
+	// mode is the mode of the layer.
+	mode Mode
+
+	// rng is the random number generator used to choose the inputs to drop,
+	// or nil to use the shared generator.
+	rng *rand.Rand
+
+	// mask holds the scale applied to each input by the most recent call to
+	// Forward, or nil if the inputs were passed through unchanged.
+	mask *Matrix
+}
+
+// NewDropout creates a dropout layer.
+//
+// Parameters:
+// - rate: The proportion of the inputs dropped while training, from 0 up to but not including 1.
+//
+// Returns:
+// - A pointer to the layer.
+func NewDropout(rate float64) *Dropout {
+	return &Dropout{rate: rate}
+}
+
+// Rate returns the proportion of the inputs dropped while training.
+//
+// Returns:
+// - The rate.
+func (d *Dropout) Rate() float64 {
+	return d.rate
+}
+
+// Build prepares the layer to receive inputs of the given width.
+//
+// Parameters:
+// - inputs: The number of values in each input row.
+//
+// Returns:
+// - The number of values in each output row, which is the same as the inputs.
+// - An error if the rate is out of range.
+func (d *Dropout) Build(inputs uint32) (uint32, error) {
+	if err := validateRate(d.rate); err != nil {
+		return 0, err
+	}
+	d.size = inputs
+	return inputs, nil
+}
+
+// SetMode sets whether the layer drops inputs, which it does only in training mode.
+//
+// Parameters:
+// - m: The mode.
+func (d *Dropout) SetMode(m Mode) {
+	d.mode = m
+}
+
+// setRandom sets the random number generator used to choose the inputs to drop.
+//
+// Parameters:
+// - rng: The random number generator.
+func (d *Dropout) setRandom(rng *rand.Rand) {
+	d.rng = rng
+}
+
+// Forward feeds a batch of inputs through the layer, dropping inputs at
+// random in training mode.
+//
+// Parameters:
+// - input: A matrix holding one row of input values for each sample.
+//
+// Returns:
+// - A matrix holding one row of output values for each sample.
+// - An error if the input is the wrong size.
+func (d *Dropout) Forward(input *Matrix) (*Matrix, error) {
+	if input.cols != d.size {
+		return nil, errors.New("incorrect input size")
+	}
+	d.mask = nil
+	if d.mode != Training || d.rate == 0 {
+		return input, nil
+	}
+
+	// Keep each input with a probability of 1 - rate, scaling the kept inputs
+	// so the expected value of each output matches its input.
+	d.mask = NewMatrix(input.cols, input.rows)
+	scale := 1 / (1 - d.rate)
+	for k := range d.mask.values {
+		if randomFloat(d.rng) >= d.rate {
+			d.mask.values[k] = scale
+		}
+	}
+	return input.MultiplyElements(d.mask)
+}
+
+// Backward propagates the gradient of the error back through the layer,
+// passing it only to the inputs that were kept.
+//
+// Parameters:
+// - gradient: The gradient of the error with respect to the output of the layer.
+//
+// Returns:
+// - The gradient of the error with respect to the input of the layer.
+// - An error if the gradient is the wrong size.
+func (d *Dropout) Backward(gradient *Matrix) (*Matrix, error) {
+	if d.mask == nil {
+		return gradient, nil
+	}
+	res, err := gradient.MultiplyElements(d.mask)
+	if err != nil {
+		return nil, fmt.Errorf("back propagation error: %v", err)
+	}
+	return res, nil
+}
+
+// Parameters returns the trainable parameters of the layer, of which there are none.
+//
+// Returns:
+// - A nil slice.
+func (d *Dropout) Parameters() []*Parameter {
+	return nil
+}
+
+// OutputSize returns the number of values in each output row.
+//
+// Returns:
+// - The number of output values.
+func (d *Dropout) OutputSize() uint32 {
+	return d.size
+}
+
+// MarshalJSON marshals the layer into JSON.
+//
+// Returns:
+// - A JSON byte slice representing the layer.
+// - An error if there is an error during the marshaling process.
+func (d *Dropout) MarshalJSON() ([]byte, error) {
+	return json.Marshal(&dropoutJSON{Rate: d.rate, Size: d.size})
+}
+
+// UnmarshalJSON unmarshals the layer from JSON.
+//
+// Parameters:
+// - body: The JSON byte slice to unmarshal.
+//
+// Returns:
+// - An error if there is an error during the unmarshaling process.
+func (d *Dropout) UnmarshalJSON(body []byte) error {
+	data := dropoutJSON{}
+	if err := json.Unmarshal(body, &data); err != nil {
+		return err
+	}
+	*d = Dropout{rate: data.Rate, size: data.Size}
+	return nil
+}
+
+// AlphaDropout is a dropout layer for networks using the SELU activation
+// function. It keeps the mean and variance of its inputs while training, so
+// that the self-normalising property of SELU is preserved.
+//
+// Dropped inputs are set to the value SELU gives for large negative inputs,
+// and the result is scaled and shifted back to the mean and variance of the
+// inputs. The layer passes its inputs through unchanged in inference mode.
+type AlphaDropout struct {
+	// rate is the proportion of the inputs dropped.
+	rate float64
+
+	// size is the number of values in each input row, set by Build.
+	size uint32
+
+	// mode is the mode of the layer.
+	mode Mode
+
+	// rng is the random number generator used to choose the inputs to drop,
+	// or nil to use the shared generator.
+	rng *rand.Rand
+
+	// mask holds the scale applied to each input by the most recent call to
+	// Forward, which is zero for dropped inputs, or nil if the inputs were
+	// passed through unchanged.
+	mask *Matrix
+}
+
+// NewAlphaDropout creates an alpha dropout layer.
+//
+// Parameters:
+// - rate: The proportion of the inputs dropped while training, from 0 up to but not including 1.
+//
+// Returns:
+// - A pointer to the layer.
+func NewAlphaDropout(rate float64) *AlphaDropout {
+	return &AlphaDropout{rate: rate}
+}
+
+// Rate returns the proportion of the inputs dropped while training.
+//
+// Returns:
+// - The rate.
+func (d *AlphaDropout) Rate() float64 {
+	return d.rate
+}
+
+// Build prepares the layer to receive inputs of the given width.
+//
+// Parameters:
+// - inputs: The number of values in each input row.
+//
+// Returns:
+// - The number of values in each output row, which is the same as the inputs.
+// - An error if the rate is out of range.
+func (d *AlphaDropout) Build(inputs uint32) (uint32, error) {
+	if err := validateRate(d.rate); err != nil {
+		return 0, err
+	}
+	d.size = inputs
+	return inputs, nil
+}
+
+// SetMode sets whether the layer drops inputs, which it does only in training mode.
+//
+// Parameters:
+// - m: The mode.
+func (d *AlphaDropout) SetMode(m Mode) {
+	d.mode = m
+}
+
+// setRandom sets the random number generator used to choose the inputs to drop.
+//
+// Parameters:
+// - rng: The random number generator.
+func (d *AlphaDropout) setRandom(rng *rand.Rand) {
+	d.rng = rng
+}
+
+// Forward feeds a batch of inputs through the layer, dropping inputs at
+// random in training mode.
+//
+// Parameters:
+// - input: A matrix holding one row of input values for each sample.
+//
+// Returns:
+// - A matrix holding one row of output values for each sample.
+// - An error if the input is the wrong size.
+func (d *AlphaDropout) Forward(input *Matrix) (*Matrix, error) {
+	if input.cols != d.size {
+		return nil, errors.New("incorrect input size")
+	}
+	d.mask = nil
+	if d.mode != Training || d.rate == 0 {
+		return input, nil
+	}
+
+	// Set the dropped inputs to the negative saturation value of SELU, then
+	// scale and shift the result to restore the mean and variance.
+	saturation := -seluScale * seluAlpha
+	a := 1 / math.Sqrt((1-d.rate)*(1+d.rate*saturation*saturation))
+	b := -a * saturation * d.rate
+	d.mask = NewMatrix(input.cols, input.rows)
+	res := NewMatrix(input.cols, input.rows)
+	for k, v := range input.values {
+		if randomFloat(d.rng) >= d.rate {
+			d.mask.values[k] = a
+			res.values[k] = a*v + b
+		} else {
+			res.values[k] = a*saturation + b
+		}
+	}
+	return res, nil
+}
+
+// Backward propagates the gradient of the error back through the layer,
+// passing it only to the inputs that were kept.
+//
+// Parameters:
+// - gradient: The gradient of the error with respect to the output of the layer.
+//
+// Returns:
+// - The gradient of the error with respect to the input of the layer.
+// - An error if the gradient is the wrong size.
+func (d *AlphaDropout) Backward(gradient *Matrix) (*Matrix, error) {
+	if d.mask == nil {
+		return gradient, nil
+	}
+	res, err := gradient.MultiplyElements(d.mask)
+	if err != nil {
+		return nil, fmt.Errorf("back propagation error: %v", err)
+	}
+	return res, nil
+}
+
+// Parameters returns the trainable parameters of the layer, of which there are none.
+//
+// Returns:
+// - A nil slice.
+func (d *AlphaDropout) Parameters() []*Parameter {
+	return nil
+}
+
+// OutputSize returns the number of values in each output row.
+//
+// Returns:
+// - The number of output values.
+func (d *AlphaDropout) OutputSize() uint32 {
+	return d.size
+}
+
+// MarshalJSON marshals the layer into JSON.
+//
+// Returns:
+// - A JSON byte slice representing the layer.
+// - An error if there is an error during the marshaling process.
+func (d *AlphaDropout) MarshalJSON() ([]byte, error) {
+	return json.Marshal(&dropoutJSON{Rate: d.rate, Size: d.size})
+}
+
+// UnmarshalJSON unmarshals the layer from JSON.
+//
+// Parameters:
+// - body: The JSON byte slice to unmarshal.
+//
+// Returns:
+// - An error if there is an error during the unmarshaling process.
+func (d *AlphaDropout) UnmarshalJSON(body []byte) error {
+	data := dropoutJSON{}
+	if err := json.Unmarshal(body, &data); err != nil {
+		return err
+	}
+	*d = AlphaDropout{rate: data.Rate, size: data.Size}
+	return nil
+}
+
+// validateRate checks the rate of a dropout layer.
+//
+// Parameters:
+// - rate: The proportion of the inputs dropped.
+//
+// Returns:
+// - An error if the rate is not from 0 up to but not including 1.
+func validateRate(rate float64) error {
+	if rate < 0 || rate >= 1 || math.IsNaN(rate) {
+		return fmt.Errorf("dropout rate out of range: %v", rate)
+	}
+	return nil
+}
@@ -0,0 +1,166 @@
+package jasper
+
+import (
+	"math"
+	"testing"
+)
+
+// dropoutInput returns a single row of values from the standard normal
+// distribution.
+func dropoutInput(size uint32) *Matrix {
+	rng := newRandom(1, nil).rng
+	m := NewMatrix(size, 1)
+	for k := range m.values {
+		m.values[k] = rng.NormFloat64()
+	}
+	return m
+}
+
+func TestDropoutInference(t *testing.T) {
+	for _, d := range []ModeLayer{NewDropout(0.5), NewAlphaDropout(0.5)} {
+		if _, err := d.Build(100); err != nil {
+			t.Fatal(err)
+		}
+		input := dropoutInput(100)
+		d.SetMode(Inference)
+		out, err := d.Forward(input)
+		if err != nil {
+			t.Fatal(err)
+		}
+		gradient, err := d.Backward(input)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for k, v := range input.values {
+			if out.values[k] != v || gradient.values[k] != v {
+				t.Fatalf("%T changed value %v to %v and its gradient to %v", d, v, out.values[k], gradient.values[k])
+			}
+		}
+	}
+}
+
+func TestDropoutTraining(t *testing.T) {
+	const size, rate = 10000, 0.3
+	d := NewDropout(rate)
+	if _, err := d.Build(size); err != nil {
+		t.Fatal(err)
+	}
+	d.setRandom(newRandom(2, nil).rng)
+	d.SetMode(Training)
+	input := dropoutInput(size)
+	out, err := d.Forward(input)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ones := NewMatrix(size, 1)
+	for k := range ones.values {
+		ones.values[k] = 1
+	}
+	gradient, err := d.Backward(ones)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Each value is dropped, or kept and scaled, and its gradient with it.
+	dropped := 0
+	for k, v := range input.values {
+		switch {
+		case out.values[k] == 0 && gradient.values[k] == 0:
+			dropped++
+		case near(out.values[k], v/(1-rate)) && near(gradient.values[k], 1/(1-rate)):
+		default:
+			t.Fatalf("value %v gave %v with a gradient of %v", v, out.values[k], gradient.values[k])
+		}
+	}
+	if got := float64(dropped) / size; math.Abs(got-rate) > 0.02 {
+		t.Errorf("dropped %v of the values, want %v", got, rate)
+	}
+}
+
+func TestAlphaDropoutTraining(t *testing.T) {
+	const size = 10000
+	d := NewAlphaDropout(0.2)
+	if _, err := d.Build(size); err != nil {
+		t.Fatal(err)
+	}
+	d.setRandom(newRandom(2, nil).rng)
+	d.SetMode(Training)
+	out, err := d.Forward(dropoutInput(size))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The mean and variance of the standard normal inputs are kept.
+	var sum, squares float64
+	for _, v := range out.values {
+		sum += v
+		squares += v * v
+	}
+	mean := sum / size
+	variance := squares/size - mean*mean
+	if math.Abs(mean) > 0.05 || math.Abs(variance-1) > 0.05 {
+		t.Errorf("mean %v and variance %v, want 0 and 1", mean, variance)
+	}
+
+	// The gradient of a dropped value is zero.
+	gradient, err := d.Backward(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for k := range d.mask.values {
+		if d.mask.values[k] == 0 && gradient.values[k] != 0 {
+			t.Fatalf("gradient of dropped value %v = %v", k, gradient.values[k])
+		}
+	}
+}
+
+func TestDropoutRate(t *testing.T) {
+	for _, rate := range []float64{-0.1, 1} {
+		if _, err := NewDropout(rate).Build(2); err == nil {
+			t.Errorf("dropout rate %v: expected an error", rate)
+		}
+		if _, err := NewAlphaDropout(rate).Build(2); err == nil {
+			t.Errorf("alpha dropout rate %v: expected an error", rate)
+		}
+	}
+}
+
+func TestDropoutMode(t *testing.T) {
+	c := NewConfig([]uint32{2})
+	c.Seed = 1
+	c.Quiet = true
+	c.Model = NewSequential(NewDense(8, Tanh), NewDropout(0.5), NewDense(1, Sigmoid))
+	n, err := New(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d := n.model.layers[1].(*Dropout)
+
+	// Inputs are only dropped while training.
+	batches := 0
+	watch := Callback{
+		OnBatchEnd: func(n *Network, epoch, batch int, loss float64) error {
+			batches++
+			if d.mode != Training || d.mask == nil {
+				t.Errorf("batch %v was not trained with dropout", batch)
+			}
+			return nil
+		},
+	}
+	td := &TrainingData{TrainingSource: NewSliceSource(xorRows()), ValidationSource: NewSliceSource(xorRows()), Iterations: 2}
+	if _, err := n.Train(td, watch); err != nil {
+		t.Fatal(err)
+	}
+	if batches == 0 || d.mode != Inference {
+		t.Errorf("%v batches, left in mode %v", batches, d.mode)
+	}
+	first, err := n.Predict([]float64{1, 0})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for range 5 {
+		if p, _ := n.Predict([]float64{1, 0}); p[0] != first[0] {
+			t.Fatalf("prediction %v, want %v every time", p[0], first[0])
+		}
+	}
+}
@@ -0,0 +1,239 @@
+// earlystopping.go - Early stopping policy used when training the neural network.
+//
+// # Copyright 2024 Mark Oxley
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package jasper
+
+import (
+	"fmt"
+	"math"
+)
+
+// Monitor selects the value watched by an EarlyStopping policy.
+type Monitor int
+
+const (
+	// MonitorValidationLoss watches the average error of the validation data.
+	MonitorValidationLoss Monitor = iota
+	// MonitorTrainLoss watches the average error of the training data.
+	MonitorTrainLoss
+	// MonitorValidationRMSE watches the root mean squared error of the
+	// predictions of the validation data.
+	MonitorValidationRMSE
+	// MonitorValidationMAE watches the mean absolute error of the predictions
+	// of the validation data.
+	MonitorValidationMAE
+	// MonitorValidationMAPE watches the mean absolute percentage error of the
+	// predictions of the validation data. It cannot be used if every output
+	// of the validation data is zero, as the error is then NaN.
+	MonitorValidationMAPE
+	// MonitorValidationR2 watches the coefficient of determination of the
+	// predictions of the validation data. Higher values are better.
+	MonitorValidationR2
+)
+
+// regression returns true if the monitor watches a regression metric of the
+// validation data.
+func (m Monitor) regression() bool {
+	return m >= MonitorValidationRMSE && m <= MonitorValidationR2
+}
+
+// EarlyStopping stops training once the monitored value has stopped
+// improving, and can restore the weights and biases from the best iteration.
+//
+// It is used by setting the EarlyStopping field of the TrainingData.
+type EarlyStopping struct {
+	// Monitor selects the value to watch. Lower values are better, apart from
+	// MonitorValidationR2. The regression metrics are calculated by
+	// EvaluateRegression from the validation data at the end of every
+	// iteration. Training stops with an error if the monitored value is NaN,
+	// as no value could be counted as an improvement on it.
+	Monitor Monitor
+
+	// Value, if set, calculates the value to watch in place of Monitor. This
+	// allows any metric to be monitored.
+	Value func(n *Network, rec EpochRecord) float64
+
+	// Maximize indicates that higher values of Value are better.
+	Maximize bool
+
+	// MinDelta is the smallest change in the monitored value counted as an
+	// improvement.
+	MinDelta float64
+
+	// Patience is the number of iterations without improvement after which
+	// training is stopped.
+	Patience int
+
+	// RestoreBest restores the weights and biases from the iteration with the
+	// best monitored value when training finishes, together with their
+	// optimizer state and the running averages of any batch normalization
+	// layers.
+	RestoreBest bool
+
+	// best is the best monitored value seen so far.
+	best float64
+
+	// bestEpoch is the iteration with the best monitored value, or -1.
+	bestEpoch int
+
+	// wait is the number of iterations since the value last improved.
+	wait int
+
+	// weights holds a copy of the weights, biases and other state of the
+	// network from the best iteration.
+	weights *snapshot
+}
+
+// NewEarlyStopping creates an early stopping policy that watches the validation
+// error.
+//
+// Parameters:
+// - patience: The number of iterations without improvement after which training is stopped.
+// - minDelta: The smallest decrease in error counted as an improvement.
+// - restoreBest: Whether to restore the weights and biases from the best iteration.
+//
+// Returns:
+// - A pointer to the policy.
+func NewEarlyStopping(patience int, minDelta float64, restoreBest bool) *EarlyStopping {
+	return &EarlyStopping{
+		Monitor:     MonitorValidationLoss,
+		MinDelta:    minDelta,
+		Patience:    patience,
+		RestoreBest: restoreBest,
+	}
+}
+
+// reset clears the state of the policy before training starts.
+func (e *EarlyStopping) reset() {
+	e.bestEpoch = -1
+	e.wait = 0
+	e.weights = nil
+}
+
+// value returns the monitored value for a training iteration.
+//
+// Parameters:
+// - n: The network being trained.
+// - td: The training data.
+// - rec: The results of the training iteration.
+//
+// Returns:
+// - The monitored value.
+// - An error if a regression metric cannot be calculated.
+func (e *EarlyStopping) value(n *Network, td *TrainingData, rec EpochRecord) (float64, error) {
+	if e.Value != nil {
+		return e.Value(n, rec), nil
+	}
+	if e.Monitor.regression() {
+		rows, err := td.validationRows()
+		if err != nil {
+			return 0, err
+		}
+		r, err := EvaluateRegression(n, rows)
+		if err != nil {
+			return 0, err
+		}
+		switch e.Monitor {
+		case MonitorValidationMAE:
+			return r.MAE, nil
+		case MonitorValidationMAPE:
+			return r.MAPE, nil
+		case MonitorValidationR2:
+			return r.R2, nil
+		}
+		return r.RMSE, nil
+	}
+	switch e.Monitor {
+	case MonitorTrainLoss:
+		return rec.TrainLoss, nil
+	}
+	return rec.ValidationLoss, nil
+}
+
+// update records the results of a training iteration.
+//
+// Parameters:
+// - n: The network being trained.
+// - td: The training data.
+// - rec: The results of the training iteration.
+//
+// Returns:
+// - True if the training should stop.
+// - An error if the monitored value cannot be calculated or is NaN.
+func (e *EarlyStopping) update(n *Network, td *TrainingData, rec EpochRecord) (bool, error) {
+	v, err := e.value(n, td, rec)
+	if err != nil {
+		return false, fmt.Errorf("early stopping error: %v", err)
+	}
+	if math.IsNaN(v) {
+		return false, fmt.Errorf("early stopping error: monitored value is NaN at iteration %v", rec.Epoch)
+	}
+	improved := e.bestEpoch < 0 || v < e.best-e.MinDelta
+	if e.Maximize || (e.Value == nil && e.Monitor == MonitorValidationR2) {
+		improved = e.bestEpoch < 0 || v > e.best+e.MinDelta
+	}
+	if improved {
+		e.best = v
+		e.bestEpoch = rec.Epoch
+		e.wait = 0
+		if e.RestoreBest {
+			e.weights = n.snapshot()
+		}
+		return false, nil
+	}
+	e.wait++
+	return e.wait >= e.Patience, nil
+}
+
+// snapshot holds a copy of the parameters of a network, their optimizer
+// state, and the state its layers keep outside of their parameters.
+type snapshot struct {
+	values []*Matrix
+	states []*OptimizerState
+	layers []*Matrix
+}
+
+// snapshot copies the parameters of the network, their optimizer state and
+// the state of its layers.
+//
+// Returns:
+// - A pointer to the copy.
+func (n *Network) snapshot() *snapshot {
+	s := &snapshot{layers: n.model.state()}
+	for _, p := range n.model.Parameters() {
+		s.values = append(s.values, p.Value.Copy())
+		s.states = append(s.states, p.State.copy())
+	}
+	return s
+}
+
+// restore replaces the parameters of the network, their optimizer state and
+// the state of its layers with a copy.
+//
+// Parameters:
+// - s: The copy to restore.
+func (n *Network) restore(s *snapshot) {
+	for i, p := range n.model.Parameters() {
+		if i < len(s.values) {
+			p.Value = s.values[i].Copy()
+		}
+		if i < len(s.states) {
+			p.State = s.states[i].copy()
+		}
+	}
+	if len(s.layers) > 0 {
+		n.model.setState(s.layers)
+	}
+}
@@ -0,0 +1,158 @@
+package jasper
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestEarlyStoppingNaN(t *testing.T) {
+	c := NewConfig([]uint32{1, 2, 1})
+	c.Output = Linear
+	c.Seed = 1
+	c.Quiet = true
+	n, err := New(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Every target is zero, so the MAPE of the validation data is NaN.
+	var rows []*DataRow
+	for i := range 4 {
+		rows = append(rows, &DataRow{Input: []float64{float64(i)}, Ouput: []float64{0}})
+	}
+	es := NewEarlyStopping(2, 0, false)
+	es.Monitor = MonitorValidationMAPE
+	td := &TrainingData{
+		TrainingSource:   NewSliceSource(rows),
+		ValidationSource: NewSliceSource(rows),
+		Iterations:       10,
+		EarlyStopping:    es,
+	}
+	if _, err := n.Train(td); err == nil {
+		t.Error("expected an error")
+	}
+}
+
+func TestEarlyStoppingPatience(t *testing.T) {
+	tests := []struct {
+		name     string
+		values   []float64
+		minDelta float64
+		maximize bool
+		patience int
+		epochs   int
+		best     int
+	}{
+		{"stops after patience", []float64{5, 4, 3, 3.5, 3.2, 1}, 0, false, 2, 5, 2},
+		{"improvement resets patience", []float64{5, 4, 4.5, 3, 3.5, 3.2, 1}, 0, false, 2, 6, 3},
+		{"small changes are not improvements", []float64{5, 4.95, 4.92, 4.91, 1}, 0.1, false, 3, 4, 0},
+		{"maximize", []float64{1, 2, 3, 2.5, 2, 9}, 0, true, 2, 5, 2},
+		{"never runs out", []float64{5, 4, 3, 2}, 0, false, 2, 4, 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := NewConfig([]uint32{1, 2, 1})
+			c.Seed = 1
+			c.Quiet = true
+			n, err := New(c)
+			if err != nil {
+				t.Fatal(err)
+			}
+			td := splitData(20, 0.75, 0)
+			td.Iterations = 4
+			if len(tt.values) > 4 {
+				td.Iterations = uint32(len(tt.values))
+			}
+			td.EarlyStopping = &EarlyStopping{
+				Value: func(n *Network, rec EpochRecord) float64 {
+					return tt.values[rec.Epoch]
+				},
+				Maximize:    tt.maximize,
+				MinDelta:    tt.minDelta,
+				Patience:    tt.patience,
+				RestoreBest: true,
+			}
+
+			// Record the predictions of the network after each iteration.
+			var predictions []float64
+			record := Callback{
+				OnEpochEnd: func(n *Network, rec EpochRecord) error {
+					p, err := n.Predict([]float64{1})
+					predictions = append(predictions, p[0])
+					return err
+				},
+			}
+			h, err := n.Train(td, record)
+			if err != nil {
+				t.Fatal(err)
+			}
+			stopped := len(h.Epochs) < len(tt.values)
+			if len(h.Epochs) != tt.epochs || h.BestEpoch != tt.best || stopped != (h.StopReason == EarlyStopped) {
+				t.Errorf("%v iterations with the best at %v, stopped by %v, want %v with the best at %v", len(h.Epochs), h.BestEpoch, h.StopReason, tt.epochs, tt.best)
+			}
+
+			// The network predicts as it did after the best iteration.
+			p, err := n.Predict([]float64{1})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !h.Restored || p[0] != predictions[tt.best] {
+				t.Errorf("prediction %v, want %v from iteration %v", p[0], predictions[tt.best], tt.best)
+			}
+			if h.Loss() != h.Epochs[tt.best].ValidationLoss {
+				t.Errorf("loss = %v, want %v", h.Loss(), h.Epochs[tt.best].ValidationLoss)
+			}
+		})
+	}
+}
+
+func TestEarlyStoppingWithoutRestore(t *testing.T) {
+	c := NewConfig([]uint32{1, 2, 1})
+	c.Seed = 1
+	c.Quiet = true
+	n, err := New(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	td := splitData(20, 0.75, 0)
+	td.Iterations = 10
+	td.EarlyStopping = &EarlyStopping{
+		Value:    func(n *Network, rec EpochRecord) float64 { return 1 },
+		Patience: 2,
+	}
+	var last float64
+	record := Callback{
+		OnEpochEnd: func(n *Network, rec EpochRecord) error {
+			p, err := n.Predict([]float64{1})
+			last = p[0]
+			return err
+		},
+	}
+	h, err := n.Train(td, record)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(h.Epochs) != 3 || h.Restored {
+		t.Fatalf("%v iterations, restored %v, want 3 and not restored", len(h.Epochs), h.Restored)
+	}
+	if p, _ := n.Predict([]float64{1}); p[0] != last {
+		t.Errorf("prediction %v, want %v from the last iteration", p[0], last)
+	}
+}
+
+func TestOptimizerStateCopy(t *testing.T) {
+	param := NewMatrix(2, 2)
+	gradient := NewMatrix(2, 2)
+	copy(gradient.values, []float64{0.1, -0.2, 0.3, -0.4})
+	state := &OptimizerState{}
+	o := NewAdam()
+	o.Update(param, gradient, state, 0.1)
+	c := state.copy()
+	if !reflect.DeepEqual(c, state) {
+		t.Fatalf("copy %+v, want %+v", c, state)
+	}
+	o.Update(param, gradient, state, 0.1)
+	if reflect.DeepEqual(c, state) {
+		t.Error("the copy shares the state")
+	}
+}
@@ -1,125 +1,237 @@
-// errorfunctions.go - Error functions used in the neural network.
-//
-// # Copyright 2024 Mark Oxley
-//
-// Licensed under the Apache License, Version 2.0 (the "License");
-// you may not use this file except in compliance with the License.
-// You may obtain a copy of the License at
-//
-//	http://www.apache.org/licenses/LICENSE-2.0
-//
-// Unless required by applicable law or agreed to in writing, software
-// distributed under the License is distributed on an "AS IS" BASIS,
-// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
-// See the License for the specific language governing permissions and
-// limitations under the License.
-package jasper
-
-import "math"
-
-// ErrorFunction represents the type of error function used in the neural network.
-type ErrorFunction int
-
-const (
-	// MeanSquaredError represents the mean squared error function.
-	MeanSquaredError ErrorFunction = iota
-	// MeanAbsoluteError represents the mean absolute error function.
-	MeanAbsoluteError
-	// BinaryCrossEntropy represents the binary cross entropy function.
-	BinaryCrossEntropy
-	// CategoricalCrossEntropy represents the categorical cross entropy function.
-	CategoricalCrossEntropy
-)
-
-// errorSolver represents the interface for error calculation functions.
-type errorSolver interface {
-	// e calculates the error between the predicted values and the target values.
-	//
-	// vs: the predicted values.
-	// tgts: the target values.
-	// Returns the calculated error.
-	e(vs, tgts []float64) float64
-}
-
-// getErrorFunction returns the error function corresponding to the given name.
-//
-// name: the name of the error function.
-// Returns the error function corresponding to the given name.
-func getErrorFunction(name ErrorFunction) errorSolver {
-	switch name {
-	case MeanSquaredError:
-		return emse{}
-	case MeanAbsoluteError:
-		return emae{}
-	case BinaryCrossEntropy:
-		return ebce{}
-	case CategoricalCrossEntropy:
-		return ecce{}
-	}
-	return nil
-}
-
-// emse represents the mean squared error function.
-type emse struct{}
-
-// e calculates the mean squared error between the predicted values and the target values.
-//
-// vs: the predicted values.
-// tgts: the target values.
-// Returns the calculated mean squared error.
-func (emse) e(vs, tgts []float64) float64 {
-	var sum float64 // Initialize the sum to 0
-
-	for i, v := range vs {
-		sum += math.Pow(v-tgts[i], 2)
-	} // Return the square root of the sum
-	return sum / float64(len(vs))
-}
-
-// emae represents the mean absolute error function.
-type emae struct{}
-
-// e calculates the mean absolute error between the predicted values and the target values.
-//
-// vs: the predicted values.
-// tgts: the target values.
-// Returns the calculated mean absolute error.
-func (emae) e(vs, tgts []float64) float64 {
-	var sum float64 // Initialize the sum to 0
-	for i, v := range vs {
-		sum += math.Abs(v - tgts[i])
-	} // Return the sum
-	return sum / float64(len(vs))
-}
-
-// ebce represents the binary cross entropy function.
-type ebce struct{}
-
-// e calculates the binary cross entropy between the predicted values and the target values.
-//
-// vs: the predicted values.
-// tgts: the target values.
-// Returns the calculated binary cross entropy.
-func (ebce) e(vs, tgts []float64) float64 {
-	var sum float64 // Initialize the sum to 0
-	for i, v := range vs {
-		sum += -(tgts[i]*math.Log(v) + (1-tgts[i])*math.Log(1-v))
-	} // Return the sum
-	return sum / float64(len(vs))
-}
-
-// ecce represents the categorical cross entropy function.
-type ecce struct{}
-
-// e calculates the categorical cross entropy between the predicted values and the target values.
-//
-// vs: the predicted values.
-// tgts: the target values.
-// Returns the calculated categorical cross entropy.
-func (ecce) e(vs, tgts []float64) float64 {
-	var sum float64 // Initialize the sum to 0
-	for i, v := range vs {
-		sum += -(tgts[i] * math.Log(v))
-	} // Return the sum
-	return sum / float64(len(vs))
-}
+// errorfunctions.go - Error functions used in the neural network.
+//
+// # Copyright 2024 Mark Oxley
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package jasper
+
+import "math"
+
+// ErrorFunction represents the type of error function used in the neural network.
+type ErrorFunction int
+
+const (
+	// MeanSquaredError represents the mean squared error function.
+	MeanSquaredError ErrorFunction = iota
+	// MeanAbsoluteError represents the mean absolute error function.
+	MeanAbsoluteError
+	// BinaryCrossEntropy represents the binary cross entropy function.
+	BinaryCrossEntropy
+	// CategoricalCrossEntropy represents the categorical cross entropy function.
+	CategoricalCrossEntropy
+)
+
+// errorSolver represents the interface for error calculation functions.
+type errorSolver interface {
+	// e calculates the error between the predicted values and the target values.
+	//
+	// vs: the predicted values.
+	// tgts: the target values.
+	// Returns the calculated error.
+	e(vs, tgts []float64) float64
+
+	// g calculates the gradient of the error with respect to each predicted value.
+	//
+	// vs: the predicted values.
+	// tgts: the target values.
+	// Returns a slice holding the partial derivative of e for each predicted value.
+	g(vs, tgts []float64) []float64
+}
+
+// softMaxSolver is implemented by error functions that provide a fused
+// gradient for a softmax output layer.
+type softMaxSolver interface {
+	// softMaxGradient calculates the gradient of the error with respect to the
+	// inputs of the softmax function.
+	//
+	// vs: the softmax outputs.
+	// tgts: the target values.
+	// Returns the gradient for each softmax input.
+	softMaxGradient(vs, tgts []float64) []float64
+}
+
+// epsilon is the smallest probability used by the cross entropy functions.
+// Predicted values are clamped to [epsilon, 1-epsilon] so that the logarithms
+// and their derivatives remain finite.
+const epsilon = 1e-12
+
+// clamp limits v to the range [epsilon, 1-epsilon].
+//
+// v: the value to clamp.
+// Returns the clamped value.
+func clamp(v float64) float64 {
+	return math.Min(math.Max(v, epsilon), 1-epsilon)
+}
+
+// getErrorFunction returns the error function corresponding to the given name.
+//
+// name: the name of the error function.
+// Returns the error function corresponding to the given name.
+func getErrorFunction(name ErrorFunction) errorSolver {
+	switch name {
+	case MeanSquaredError:
+		return emse{}
+	case MeanAbsoluteError:
+		return emae{}
+	case BinaryCrossEntropy:
+		return ebce{}
+	case CategoricalCrossEntropy:
+		return ecce{}
+	}
+	return nil
+}
+
+// emse represents the mean squared error function.
+type emse struct{}
+
+// e calculates the mean squared error between the predicted values and the target values.
+//
+// vs: the predicted values.
+// tgts: the target values.
+// Returns the calculated mean squared error.
+func (emse) e(vs, tgts []float64) float64 {
+	var sum float64 // Initialize the sum to 0
+
+	for i, v := range vs {
+		sum += math.Pow(v-tgts[i], 2)
+	} // Return the square root of the sum
+	return sum / float64(len(vs))
+}
+
+// g calculates the gradient of the mean squared error.
+//
+// vs: the predicted values.
+// tgts: the target values.
+// Returns the gradient 2(v-t)/n for each predicted value.
+func (emse) g(vs, tgts []float64) []float64 {
+	res := make([]float64, len(vs))
+	for i, v := range vs {
+		res[i] = 2 * (v - tgts[i]) / float64(len(vs))
+	}
+	return res
+}
+
+// emae represents the mean absolute error function.
+type emae struct{}
+
+// e calculates the mean absolute error between the predicted values and the target values.
+//
+// vs: the predicted values.
+// tgts: the target values.
+// Returns the calculated mean absolute error.
+func (emae) e(vs, tgts []float64) float64 {
+	var sum float64 // Initialize the sum to 0
+	for i, v := range vs {
+		sum += math.Abs(v - tgts[i])
+	} // Return the sum
+	return sum / float64(len(vs))
+}
+
+// g calculates the gradient of the mean absolute error.
+//
+// vs: the predicted values.
+// tgts: the target values.
+// Returns the gradient sign(v-t)/n for each predicted value.
+func (emae) g(vs, tgts []float64) []float64 {
+	res := make([]float64, len(vs))
+	for i, v := range vs {
+		switch {
+		case v > tgts[i]:
+			res[i] = 1 / float64(len(vs))
+		case v < tgts[i]:
+			res[i] = -1 / float64(len(vs))
+		}
+	}
+	return res
+}
+
+// ebce represents the binary cross entropy function.
+type ebce struct{}
+
+// e calculates the binary cross entropy between the predicted values and the target values.
+//
+// vs: the predicted values.
+// tgts: the target values.
+// Returns the calculated binary cross entropy.
+func (ebce) e(vs, tgts []float64) float64 {
+	var sum float64 // Initialize the sum to 0
+	for i, v := range vs {
+		v = clamp(v)
+		sum += -(tgts[i]*math.Log(v) + (1-tgts[i])*math.Log(1-v))
+	} // Return the sum
+	return sum / float64(len(vs))
+}
+
+// g calculates the gradient of the binary cross entropy.
+//
+// vs: the predicted values.
+// tgts: the target values.
+// Returns the gradient (v-t)/(v(1-v)n) for each predicted value.
+func (ebce) g(vs, tgts []float64) []float64 {
+	res := make([]float64, len(vs))
+	for i, v := range vs {
+		v = clamp(v)
+		res[i] = (v - tgts[i]) / (v * (1 - v) * float64(len(vs)))
+	}
+	return res
+}
+
+// ecce represents the categorical cross entropy function.
+type ecce struct{}
+
+// e calculates the categorical cross entropy between the predicted values and the target values.
+//
+// vs: the predicted values.
+// tgts: the target values.
+// Returns the calculated categorical cross entropy.
+func (ecce) e(vs, tgts []float64) float64 {
+	var sum float64 // Initialize the sum to 0
+	for i, v := range vs {
+		sum += -(tgts[i] * math.Log(clamp(v)))
+	} // Return the sum
+	return sum / float64(len(vs))
+}
+
+// g calculates the gradient of the categorical cross entropy.
+//
+// vs: the predicted values.
+// tgts: the target values.
+// Returns the gradient -t/(vn) for each predicted value.
+func (ecce) g(vs, tgts []float64) []float64 {
+	res := make([]float64, len(vs))
+	for i, v := range vs {
+		res[i] = -tgts[i] / (clamp(v) * float64(len(vs)))
+	}
+	return res
+}
+
+// softMaxGradient calculates the gradient of the categorical cross entropy
+// with respect to the inputs of the softmax function.
+//
+// Combining the softmax and categorical cross entropy derivatives avoids the
+// division by small probabilities in g and gives the well known (v-t)/n form.
+//
+// vs: the softmax outputs.
+// tgts: the target values.
+// Returns the gradient for each softmax input.
+func (ecce) softMaxGradient(vs, tgts []float64) []float64 {
+	var total float64
+	for _, t := range tgts {
+		total += t
+	}
+	res := make([]float64, len(vs))
+	for i, v := range vs {
+		res[i] = (v*total - tgts[i]) / float64(len(vs))
+	}
+	return res
+}
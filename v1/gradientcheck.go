@@ -0,0 +1,216 @@
+// gradientcheck.go - Numerical checking of the gradients calculated by back propagation.
+//
+// # Copyright 2024 Mark Oxley
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package jasper
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"text/tabwriter"
+)
+
+const (
+	// defaultGradientStep is the step used by GradientCheck when it is given
+	// a step of zero.
+	defaultGradientStep = 1e-6
+
+	// gradientFloor is the smallest denominator used to calculate the
+	// relative error of a gradient, so that gradients that are both close to
+	// zero do not report a large relative error.
+	gradientFloor = 1e-7
+)
+
+// LayerGradientCheck holds the result of checking the gradients of the
+// parameters of one layer.
+type LayerGradientCheck struct {
+	// Layer is the index of the layer in the network's model.
+	Layer int
+
+	// Name is the name of the layer.
+	Name string
+
+	// Values is the number of parameter values checked.
+	Values int
+
+	// MaxRelativeError is the largest relative error between the gradient
+	// calculated by back propagation and the numerical gradient of a value.
+	MaxRelativeError float64
+
+	// MaxAbsoluteError is the largest absolute difference between the
+	// gradient calculated by back propagation and the numerical gradient of a
+	// value.
+	MaxAbsoluteError float64
+}
+
+// GradientCheckReport holds the result of checking the gradients of a
+// network.
+type GradientCheckReport struct {
+	// Layers holds the result of each layer that has parameters.
+	Layers []LayerGradientCheck
+
+	// MaxRelativeError is the largest relative error of any layer.
+	MaxRelativeError float64
+}
+
+// GradientCheck compares the gradients calculated by back propagation with
+// numerical gradients found by finite differences, to verify the derivatives
+// of the activation and error functions and the backward pass of each layer.
+//
+// Each parameter value in turn is moved by eps either side of its current
+// value, and the change in the error of the rows, including any penalty,
+// gives its numerical gradient. The relative error of a value is the
+// difference between the two gradients divided by the larger of them. The
+// relative errors are usually below 1e-6 for correct gradients, and close to
+// one for wrong ones. Kinks, such as that of ReLU at zero, give larger errors
+// for values whose step crosses them.
+//
+// The network is checked in the given mode. In inference mode dropout layers
+// pass their inputs through and batch normalization layers use their running
+// averages. In training mode batch normalization layers use the mean and
+// variance of the rows, which checks their backward pass through the batch
+// statistics, so at least 2 rows are needed; dropout layers still pass their
+// inputs through, as the rows they drop are random. The parameters, running
+// averages and mode of the network are left unchanged. Row weights are
+// ignored.
+//
+// Parameters:
+// - net: The network to check.
+// - rows: The rows the error is calculated for, which are preprocessed as they are when training.
+// - mode: The mode to check the network in.
+// - eps: The step used to find the numerical gradients, or zero to use 1e-6.
+//
+// Returns:
+// - A pointer to the report of the check.
+// - An error if there are no rows, eps is negative, there are too few rows
+// for batch normalization in training mode or a row cannot be predicted.
+func GradientCheck(net *Network, rows []*DataRow, mode Mode, eps float64) (*GradientCheckReport, error) {
+	if len(rows) == 0 {
+		return nil, errors.New("gradient check error: no rows")
+	}
+	if eps < 0 {
+		return nil, fmt.Errorf("gradient check error: negative step: %v", eps)
+	}
+	if eps == 0 {
+		eps = defaultGradientStep
+	}
+	if mode == Training && len(rows) < 2 && net.model.batchNormalized() {
+		return nil, errors.New("gradient check error: batch normalization needs at least 2 rows in training mode")
+	}
+	defer net.SetMode(net.Mode())
+	net.SetMode(mode)
+	disableDropout(net.model.layers)
+
+	// Keep the running averages, which are updated by each batch fed forward
+	// in training mode.
+	state := net.model.state()
+	defer net.model.setState(state)
+
+	batch, err := net.preprocess(rows)
+	if err != nil {
+		return nil, fmt.Errorf("gradient check error: %v", err)
+	}
+	inputs, targets, err := rowMatrices(batch)
+	if err != nil {
+		return nil, fmt.Errorf("gradient check error: %v", err)
+	}
+	loss := func() (float64, error) {
+		if err := net.feedForward(inputs); err != nil {
+			return 0, err
+		}
+		net.model.setState(state)
+		return net.batchLoss(targets, nil) + net.penalty(), nil
+	}
+
+	// Calculate the gradients by back propagation.
+	if err := net.feedForward(inputs); err != nil {
+		return nil, fmt.Errorf("gradient check error: %v", err)
+	}
+	net.model.setState(state)
+	if _, err := net.computeGradients(targets, nil); err != nil {
+		return nil, fmt.Errorf("gradient check error: %v", err)
+	}
+
+	// Compare the gradient of each parameter value with its numerical gradient.
+	res := &GradientCheckReport{}
+	for l, layer := range net.model.layers {
+		params := layer.Parameters()
+		if len(params) == 0 {
+			continue
+		}
+		check := LayerGradientCheck{Layer: l, Name: layerName(layer)}
+		if check.Name == "" {
+			check.Name = fmt.Sprintf("%T", layer)
+		}
+		for _, p := range params {
+			gradient := p.Gradient.Copy()
+			for k, v := range p.Value.values {
+				p.Value.values[k] = v + eps
+				plus, err := loss()
+				if err != nil {
+					p.Value.values[k] = v
+					return nil, fmt.Errorf("gradient check error: %v", err)
+				}
+				p.Value.values[k] = v - eps
+				minus, err := loss()
+				p.Value.values[k] = v
+				if err != nil {
+					return nil, fmt.Errorf("gradient check error: %v", err)
+				}
+				numerical := (plus - minus) / (2 * eps)
+				diff := math.Abs(gradient.values[k] - numerical)
+				relative := diff / max(math.Abs(gradient.values[k]), math.Abs(numerical), gradientFloor)
+				check.MaxRelativeError = max(check.MaxRelativeError, relative)
+				check.MaxAbsoluteError = max(check.MaxAbsoluteError, diff)
+				check.Values++
+			}
+		}
+		res.Layers = append(res.Layers, check)
+		res.MaxRelativeError = max(res.MaxRelativeError, check.MaxRelativeError)
+	}
+	return res, nil
+}
+
+// disableDropout sets every dropout layer, including those of any model
+// within the layers, to inference mode so that it passes its inputs through.
+//
+// Parameters:
+// - layers: The layers.
+func disableDropout(layers []Layer) {
+	for _, l := range layers {
+		switch l := l.(type) {
+		case *Dropout:
+			l.SetMode(Inference)
+		case *AlphaDropout:
+			l.SetMode(Inference)
+		case *Sequential:
+			disableDropout(l.layers)
+		}
+	}
+}
+
+// String formats the report as a text table of the errors of each layer.
+func (r *GradientCheckReport) String() string {
+	var sb strings.Builder
+	w := tabwriter.NewWriter(&sb, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(w, "layer\tname\tvalues\tmax relative error\tmax absolute error\t")
+	for _, l := range r.Layers {
+		fmt.Fprintf(w, "%v\t%v\t%v\t%.3e\t%.3e\t\n", l.Layer, l.Name, l.Values, l.MaxRelativeError, l.MaxAbsoluteError)
+	}
+	fmt.Fprintf(w, "overall\t\t\t%.3e\t\t\n", r.MaxRelativeError)
+	w.Flush()
+	return sb.String()
+}
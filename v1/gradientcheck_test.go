@@ -0,0 +1,183 @@
+package jasper
+
+import (
+	"testing"
+)
+
+// gradientTolerance is the largest relative error accepted from a gradient
+// check.
+const gradientTolerance = 1e-5
+
+// gradientRows returns the rows used to check gradients.
+func gradientRows() []*DataRow {
+	return []*DataRow{
+		{Input: []float64{0.3, -0.7, 0.5}, Ouput: []float64{1, 0}},
+		{Input: []float64{-1, 0.2, 0.9}, Ouput: []float64{0, 1}},
+		{Input: []float64{0.1, 0.4, -0.2}, Ouput: []float64{1, 0}},
+		{Input: []float64{0.8, -0.1, -0.6}, Ouput: []float64{0, 1}},
+	}
+}
+
+// checkGradients creates a network from the configuration and checks its
+// gradients in the given mode.
+func checkGradients(t *testing.T, c *NetworkConfiguration, mode Mode) {
+	t.Helper()
+	c.Seed = 1
+	c.Quiet = true
+	n, err := New(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := GradientCheck(n, gradientRows(), mode, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(r.Layers) == 0 {
+		t.Fatal("no layers checked")
+	}
+	if r.MaxRelativeError > gradientTolerance {
+		t.Errorf("relative error %.3e exceeds %.0e\n%v", r.MaxRelativeError, gradientTolerance, r)
+	}
+}
+
+func TestGradientCheckActivations(t *testing.T) {
+	tests := []struct {
+		name       string
+		activation ActivationFunction
+	}{
+		{"sigmoid", Sigmoid},
+		{"relu", Relu},
+		{"tanh", Tanh},
+		{"leakyrelu", LeakyRelu},
+		{"softplus", Softplus},
+		{"swish", Swish},
+		{"elu", ELU},
+		{"gelu", GELU},
+		{"linear", Linear},
+		{"selu", SELU},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := NewConfig([]uint32{3, 5, 4, 2})
+			c.Activation = tt.activation
+			c.Output = tt.activation
+			checkGradients(t, c, Inference)
+		})
+	}
+}
+
+func TestGradientCheckErrorFunctions(t *testing.T) {
+	tests := []struct {
+		name    string
+		err     ErrorFunction
+		output  ActivationFunction
+		softMax bool
+	}{
+		{"mse", MeanSquaredError, Linear, false},
+		{"mae", MeanAbsoluteError, Linear, false},
+		{"bce", BinaryCrossEntropy, Sigmoid, false},
+		{"cce", CategoricalCrossEntropy, Linear, true},
+		{"mse softmax", MeanSquaredError, Linear, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := NewConfig([]uint32{3, 4, 2})
+			c.Activation = Tanh
+			c.Output = tt.output
+			c.SoftMax = tt.softMax
+			c.Error = tt.err
+			checkGradients(t, c, Inference)
+		})
+	}
+}
+
+func TestGradientCheckLayers(t *testing.T) {
+	tests := []struct {
+		name  string
+		model func() *Sequential
+		mode  Mode
+	}{
+		{"dense", func() *Sequential {
+			return NewSequential(NewDense(4, Tanh), NewDense(2, Sigmoid))
+		}, Training},
+		{"batchnorm inference", func() *Sequential {
+			return NewSequential(NewDense(4, Tanh), NewBatchNorm(0, 0), NewDense(2, Sigmoid))
+		}, Inference},
+		{"batchnorm training", func() *Sequential {
+			return NewSequential(NewDense(4, Tanh), NewBatchNorm(0, 0), NewDense(2, Sigmoid))
+		}, Training},
+		{"layernorm", func() *Sequential {
+			return NewSequential(NewDense(4, Tanh), NewLayerNorm(0), NewDense(2, Sigmoid))
+		}, Training},
+		{"dropout", func() *Sequential {
+			return NewSequential(NewDense(4, Tanh), NewDropout(0.5), NewDense(2, Sigmoid))
+		}, Training},
+		{"alphadropout", func() *Sequential {
+			return NewSequential(NewDense(4, SELU), NewAlphaDropout(0.5), NewDense(2, Sigmoid))
+		}, Training},
+		{"nested", func() *Sequential {
+			return NewSequential(NewSequential(NewDense(4, Tanh), NewBatchNorm(0, 0)), NewDense(2, Sigmoid))
+		}, Training},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := NewConfig([]uint32{3})
+			c.Model = tt.model()
+			checkGradients(t, c, tt.mode)
+		})
+	}
+}
+
+func TestGradientCheckRegularization(t *testing.T) {
+	c := NewConfig([]uint32{3, 4, 2})
+	c.Regularization = NewElasticNet(0.01, 0.5)
+	checkGradients(t, c, Inference)
+}
+
+func TestGradientCheckLeavesNetworkUnchanged(t *testing.T) {
+	c := NewConfig([]uint32{3, 4, 2})
+	c.Normalization = BatchNormalization
+	c.Seed = 1
+	n, err := New(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	values := n.model.Parameters()[0].Value.Copy()
+	state := n.model.state()
+	if _, err := GradientCheck(n, gradientRows(), Training, 0); err != nil {
+		t.Fatal(err)
+	}
+	if n.Mode() != Inference {
+		t.Errorf("mode = %v, want inference", n.Mode())
+	}
+	for k, v := range n.model.Parameters()[0].Value.values {
+		if v != values.values[k] {
+			t.Fatalf("parameter %v changed from %v to %v", k, values.values[k], v)
+		}
+	}
+	for i, m := range n.model.state() {
+		for k, v := range m.values {
+			if v != state[i].values[k] {
+				t.Fatalf("running average %v changed from %v to %v", k, state[i].values[k], v)
+			}
+		}
+	}
+}
+
+func TestGradientCheckErrors(t *testing.T) {
+	c := NewConfig([]uint32{3, 4, 2})
+	c.Normalization = BatchNormalization
+	n, err := New(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := GradientCheck(n, nil, Inference, 0); err == nil {
+		t.Error("no rows: expected an error")
+	}
+	if _, err := GradientCheck(n, gradientRows(), Inference, -1); err == nil {
+		t.Error("negative step: expected an error")
+	}
+	if _, err := GradientCheck(n, gradientRows()[:1], Training, 0); err == nil {
+		t.Error("one row with batch normalization in training mode: expected an error")
+	}
+}
@@ -0,0 +1,176 @@
+// history.go - Training history and callbacks used when training the neural network.
+//
+// # Copyright 2024 Mark Oxley
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package jasper
+
+import (
+	"errors"
+	"log/slog"
+	"math"
+	"time"
+)
+
+// ErrStopTraining can be returned by a callback to stop training early.
+// Train returns normally when a callback stops it this way.
+var ErrStopTraining = errors.New("training stopped")
+
+// StopReason describes why training finished.
+type StopReason int
+
+const (
+	// Completed indicates that every training iteration was run.
+	Completed StopReason = iota
+	// WithinTolerance indicates that the validation error fell within the target error.
+	WithinTolerance
+	// StoppedByCallback indicates that a callback returned ErrStopTraining.
+	StoppedByCallback
+	// EarlyStopped indicates that the early stopping policy stopped training.
+	EarlyStopped
+	// SourceExhausted indicates that the training source could not be reset
+	// because it has no more data.
+	SourceExhausted
+)
+
+// String returns a description of the stop reason.
+func (r StopReason) String() string {
+	switch r {
+	case Completed:
+		return "completed"
+	case WithinTolerance:
+		return "within tolerance"
+	case StoppedByCallback:
+		return "stopped by callback"
+	case EarlyStopped:
+		return "early stopped"
+	case SourceExhausted:
+		return "source exhausted"
+	}
+	return "unknown"
+}
+
+// EpochRecord holds the results of a single training iteration.
+type EpochRecord struct {
+	// Epoch is the zero based training iteration.
+	Epoch int
+
+	// TrainLoss is the average error of the training rows during the iteration.
+	TrainLoss float64
+
+	// ValidationLoss is the average error of the validation rows after the iteration.
+	ValidationLoss float64
+
+	// LearningRate is the learning rate used during the iteration.
+	LearningRate float64
+
+	// Duration is the wall time taken by the iteration.
+	Duration time.Duration
+}
+
+// TrainingHistory holds the results of a call to Train.
+type TrainingHistory struct {
+	// Epochs holds a record for each completed training iteration.
+	Epochs []EpochRecord
+
+	// StopReason describes why training finished.
+	StopReason StopReason
+
+	// Duration is the wall time taken by the training.
+	Duration time.Duration
+
+	// BestEpoch is the iteration with the best value monitored by the early
+	// stopping policy, or -1 if no policy was used.
+	BestEpoch int
+
+	// Restored indicates that the weights and biases from BestEpoch were
+	// restored when training finished.
+	Restored bool
+}
+
+// Loss returns the validation error of the weights and biases the network was
+// left with, which is the last completed training iteration unless the
+// weights from the best iteration were restored.
+//
+// Returns:
+// - The validation error, or NaN if no iterations were completed.
+func (h *TrainingHistory) Loss() float64 {
+	if len(h.Epochs) == 0 {
+		return math.NaN()
+	}
+	if h.Restored {
+		for _, rec := range h.Epochs {
+			if rec.Epoch == h.BestEpoch {
+				return rec.ValidationLoss
+			}
+		}
+	}
+	return h.Epochs[len(h.Epochs)-1].ValidationLoss
+}
+
+// Callback holds functions that are called by Train as training progresses.
+//
+// Any of the functions may be nil. If a function returns ErrStopTraining the
+// training stops and Train returns normally. Any other error stops the
+// training and is returned by Train.
+type Callback struct {
+	// OnEpochStart is called before each training iteration.
+	OnEpochStart func(n *Network, epoch int) error
+
+	// OnEpochEnd is called after each training iteration, once the validation
+	// error has been calculated.
+	OnEpochEnd func(n *Network, rec EpochRecord) error
+
+	// OnBatchEnd is called after the weights have been updated for each batch,
+	// with the average error of the rows in the batch.
+	OnBatchEnd func(n *Network, epoch, batch int, loss float64) error
+
+	// OnTrainEnd is called once training has finished.
+	OnTrainEnd func(n *Network, h *TrainingHistory) error
+}
+
+// LogProgress creates a callback that logs the training progress.
+//
+// Parameters:
+// - logger: The logger to write to. If nil, the default logger is used.
+// - every: The number of training iterations between progress messages.
+//
+// Returns:
+// - The callback.
+func LogProgress(logger *slog.Logger, every int) Callback {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	every = max(every, 1)
+	return Callback{
+		OnEpochEnd: func(n *Network, rec EpochRecord) error {
+			if rec.Epoch%every == 0 {
+				logger.Info("training",
+					"iteration", rec.Epoch,
+					"train_loss", rec.TrainLoss,
+					"validation_loss", rec.ValidationLoss,
+					"learning_rate", rec.LearningRate)
+			}
+			return nil
+		},
+		OnTrainEnd: func(n *Network, h *TrainingHistory) error {
+			logger.Info("training complete",
+				"iterations", len(h.Epochs),
+				"duration", h.Duration,
+				"stop_reason", h.StopReason.String(),
+				"error_margin", h.Loss(),
+				"learning_rate", n.LearningRate())
+			return nil
+		},
+	}
+}
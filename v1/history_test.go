@@ -0,0 +1,132 @@
+package jasper
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+	"testing"
+)
+
+// callbackNetwork returns a small network and training data of ten rows, read
+// in batches of four for the given number of iterations.
+func callbackNetwork(t *testing.T, iterations uint32) (*Network, *TrainingData) {
+	t.Helper()
+	c := NewConfig([]uint32{1, 2, 1})
+	c.Seed = 1
+	c.Quiet = true
+	n, err := New(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rows := splitData(10, 1, 0).Data
+	return n, &TrainingData{
+		TrainingSource:   NewSliceSource(rows),
+		ValidationSource: NewSliceSource(rows),
+		Iterations:       iterations,
+		BatchSize:        4,
+	}
+}
+
+// recorder returns a callback that records each call as an event, returning
+// stop from the call named by at.
+func recorder(events *[]string, at string, stop error) Callback {
+	record := func(event string) error {
+		*events = append(*events, event)
+		if event == at {
+			return stop
+		}
+		return nil
+	}
+	return Callback{
+		OnEpochStart: func(n *Network, epoch int) error {
+			return record(fmt.Sprint("start ", epoch))
+		},
+		OnBatchEnd: func(n *Network, epoch, batch int, loss float64) error {
+			return record(fmt.Sprint("batch ", epoch, " ", batch))
+		},
+		OnEpochEnd: func(n *Network, rec EpochRecord) error {
+			return record(fmt.Sprint("end ", rec.Epoch))
+		},
+		OnTrainEnd: func(n *Network, h *TrainingHistory) error {
+			return record("train end")
+		},
+	}
+}
+
+func TestCallbacks(t *testing.T) {
+	n, td := callbackNetwork(t, 2)
+	var first, second []string
+	var records []EpochRecord
+	watch := Callback{
+		OnEpochEnd: func(n *Network, rec EpochRecord) error {
+			records = append(records, rec)
+			return nil
+		},
+	}
+	h, err := n.Train(td, recorder(&first, "", nil), recorder(&second, "", nil), watch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"start 0", "batch 0 0", "batch 0 1", "batch 0 2", "end 0",
+		"start 1", "batch 1 0", "batch 1 1", "batch 1 2", "end 1",
+		"train end",
+	}
+	if !reflect.DeepEqual(first, want) || !reflect.DeepEqual(second, want) {
+		t.Errorf("events:\n%v\n%v\nwant %v", first, second, want)
+	}
+	if !reflect.DeepEqual(records, h.Epochs) {
+		t.Errorf("records %+v, want %+v", records, h.Epochs)
+	}
+	if h.StopReason != Completed {
+		t.Errorf("stop reason = %v, want completed", h.StopReason)
+	}
+}
+
+func TestCallbackStop(t *testing.T) {
+	tests := []struct {
+		at     string
+		epochs int
+	}{
+		{"start 1", 1},
+		{"batch 1 1", 1},
+		{"end 1", 2},
+		{"train end", 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.at, func(t *testing.T) {
+			n, td := callbackNetwork(t, 3)
+			var events, later []string
+			h, err := n.Train(td, recorder(&events, tt.at, ErrStopTraining), recorder(&later, "", nil))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if h.StopReason != StoppedByCallback || len(h.Epochs) != tt.epochs {
+				t.Errorf("stopped by %v after %v iterations, want by callback after %v", h.StopReason, len(h.Epochs), tt.epochs)
+			}
+
+			// Training stops at once, without calling the later callback for
+			// the event, although the callbacks are told training has ended.
+			last := []string{tt.at, "train end"}
+			if tt.at == "train end" {
+				last = last[:1]
+			}
+			if !slices.Equal(events[len(events)-len(last):], last) {
+				t.Errorf("events %v, want them to end with %v", events, last)
+			}
+			i := len(events) - len(last)
+			if want := slices.Delete(slices.Clone(events), i, i+1); !slices.Equal(later, want) {
+				t.Errorf("later callback saw %v, want %v", later, want)
+			}
+		})
+	}
+
+	// Any other error is returned by Train.
+	n, td := callbackNetwork(t, 3)
+	failure := errors.New("failure")
+	var events []string
+	if _, err := n.Train(td, recorder(&events, "end 0", failure)); !errors.Is(err, failure) {
+		t.Errorf("error %v, want %v", err, failure)
+	}
+}
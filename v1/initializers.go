@@ -0,0 +1,233 @@
+// initializers.go - Weight and bias initializers used in the neural network.
+//
+// # Copyright 2024 Mark Oxley
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package jasper
+
+import (
+	"math"
+	"math/rand/v2"
+)
+
+// Initializer is an enumeration of the ways the weights and biases of a layer
+// can be given their starting values.
+//
+// The scale of the random initializers depends on the number of inputs
+// (fan in) and outputs (fan out) of the layer.
+type Initializer int
+
+const (
+	// DefaultInitializer chooses an initializer to suit the activation function
+	// of the layer. Weights use HeNormal for the rectified linear family of
+	// activation functions, LeCunNormal for SELU and GlorotUniform otherwise.
+	// Biases use Zeros.
+	DefaultInitializer Initializer = iota
+	// GlorotUniform draws values uniformly from ±√(6 / (fan in + fan out)).
+	GlorotUniform
+	// GlorotNormal draws values from a normal distribution with a standard
+	// deviation of √(2 / (fan in + fan out)).
+	GlorotNormal
+	// HeUniform draws values uniformly from ±√(6 / fan in).
+	HeUniform
+	// HeNormal draws values from a normal distribution with a standard
+	// deviation of √(2 / fan in).
+	HeNormal
+	// LeCunUniform draws values uniformly from ±√(3 / fan in).
+	LeCunUniform
+	// LeCunNormal draws values from a normal distribution with a standard
+	// deviation of √(1 / fan in).
+	LeCunNormal
+	// Orthogonal creates a random matrix with orthonormal rows or columns.
+	Orthogonal
+	// Zeros sets every value to zero.
+	Zeros
+	// Constant sets every value to the given constant.
+	Constant
+	// RandomUniform draws values uniformly from [0, 1). This is how earlier
+	// versions initialised every network.
+	RandomUniform
+)
+
+// String returns the name of the initializer.
+func (i Initializer) String() string {
+	switch i {
+	case DefaultInitializer:
+		return "default"
+	case GlorotUniform:
+		return "glorot uniform"
+	case GlorotNormal:
+		return "glorot normal"
+	case HeUniform:
+		return "he uniform"
+	case HeNormal:
+		return "he normal"
+	case LeCunUniform:
+		return "lecun uniform"
+	case LeCunNormal:
+		return "lecun normal"
+	case Orthogonal:
+		return "orthogonal"
+	case Zeros:
+		return "zeros"
+	case Constant:
+		return "constant"
+	case RandomUniform:
+		return "random uniform"
+	}
+	return "unknown"
+}
+
+// valid returns true if the initializer is one of the defined constants.
+func (i Initializer) valid() bool {
+	return i >= DefaultInitializer && i <= RandomUniform
+}
+
+// defaultWeightInitializer returns the weight initializer that suits an
+// activation function.
+//
+// Parameters:
+// - a: The activation function of the layer.
+//
+// Returns:
+// - The initializer.
+func defaultWeightInitializer(a ActivationFunction) Initializer {
+	switch a {
+	case Relu, LeakyRelu, ELU, GELU, Swish, Softplus:
+		return HeNormal
+	case SELU:
+		return LeCunNormal
+	}
+	return GlorotUniform
+}
+
+// initialize creates a matrix of starting values.
+//
+// Parameters:
+// - cols: The number of columns of the matrix.
+// - rows: The number of rows of the matrix.
+// - fanIn: The number of inputs of the layer.
+// - fanOut: The number of outputs of the layer.
+// - value: The value used by the Constant initializer.
+// - rng: The random number generator, or nil to use the shared generator.
+//
+// Returns:
+// - A pointer to the new matrix.
+func (i Initializer) initialize(cols, rows uint32, fanIn, fanOut int, value float64, rng *rand.Rand) *Matrix {
+	m := NewMatrix(cols, rows)
+	uniform := func(limit float64) {
+		for k := range m.values {
+			m.values[k] = (randomFloat(rng)*2 - 1) * limit
+		}
+	}
+	normal := func(sd float64) {
+		for k := range m.values {
+			m.values[k] = randomNormal(rng) * sd
+		}
+	}
+	in := float64(max(fanIn, 1))
+	out := float64(max(fanOut, 1))
+	switch i {
+	case GlorotUniform:
+		uniform(math.Sqrt(6 / (in + out)))
+	case GlorotNormal:
+		normal(math.Sqrt(2 / (in + out)))
+	case HeUniform:
+		uniform(math.Sqrt(6 / in))
+	case HeNormal:
+		normal(math.Sqrt(2 / in))
+	case LeCunUniform:
+		uniform(math.Sqrt(3 / in))
+	case LeCunNormal:
+		normal(math.Sqrt(1 / in))
+	case Orthogonal:
+		orthogonal(m, rng)
+	case Constant:
+		for k := range m.values {
+			m.values[k] = value
+		}
+	case RandomUniform:
+		for k := range m.values {
+			m.values[k] = randomFloat(rng)
+		}
+	}
+	return m
+}
+
+// orthogonal fills a matrix with random values and makes its rows, or its
+// columns if there are more rows than columns, orthonormal.
+//
+// Parameters:
+// - m: The matrix to fill.
+// - rng: The random number generator, or nil to use the shared generator.
+func orthogonal(m *Matrix, rng *rand.Rand) {
+	// Work on the shorter dimension as a set of vectors along the longer one.
+	count, length := int(m.cols), int(m.rows)
+	at := func(v, k int) *float64 { return &m.values[k*int(m.cols)+v] }
+	if m.rows < m.cols {
+		count, length = length, count
+		at = func(v, k int) *float64 { return &m.values[v*int(m.cols)+k] }
+	}
+	for k := range m.values {
+		m.values[k] = randomNormal(rng)
+	}
+
+	// Orthonormalise the vectors using the modified Gram-Schmidt process.
+	for v := 0; v < count; v++ {
+		for u := 0; u < v; u++ {
+			var dot float64
+			for k := 0; k < length; k++ {
+				dot += *at(u, k) * *at(v, k)
+			}
+			for k := 0; k < length; k++ {
+				*at(v, k) -= dot * *at(u, k)
+			}
+		}
+		var norm float64
+		for k := 0; k < length; k++ {
+			norm += *at(v, k) * *at(v, k)
+		}
+		norm = math.Sqrt(norm)
+		for k := 0; k < length; k++ {
+			*at(v, k) /= norm
+		}
+	}
+}
+
+// randomFloat returns a random value in [0, 1).
+//
+// Parameters:
+// - rng: The random number generator, or nil to use the shared generator.
+//
+// Returns:
+// - A random float64.
+func randomFloat(rng *rand.Rand) float64 {
+	if rng == nil {
+		return rand.Float64()
+	}
+	return rng.Float64()
+}
+
+// randomNormal returns a random value from the standard normal distribution.
+//
+// Parameters:
+// - rng: The random number generator, or nil to use the shared generator.
+//
+// Returns:
+// - A random float64.
+func randomNormal(rng *rand.Rand) float64 {
+	if rng == nil {
+		return rand.NormFloat64()
+	}
+	return rng.NormFloat64()
+}
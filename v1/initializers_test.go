@@ -0,0 +1,98 @@
+package jasper
+
+import (
+	"math"
+	"testing"
+)
+
+func TestInitializerStatistics(t *testing.T) {
+	const fanIn, fanOut = 200, 100
+	tests := []struct {
+		initializer Initializer
+		mean, sd    float64
+		limit       float64
+	}{
+		{GlorotUniform, 0, math.Sqrt(6.0/300) / math.Sqrt(3), math.Sqrt(6.0 / 300)},
+		{GlorotNormal, 0, math.Sqrt(2.0 / 300), 0},
+		{HeUniform, 0, math.Sqrt(6.0/200) / math.Sqrt(3), math.Sqrt(6.0 / 200)},
+		{HeNormal, 0, math.Sqrt(2.0 / 200), 0},
+		{LeCunUniform, 0, math.Sqrt(3.0/200) / math.Sqrt(3), math.Sqrt(3.0 / 200)},
+		{LeCunNormal, 0, math.Sqrt(1.0 / 200), 0},
+		{RandomUniform, 0.5, 1 / math.Sqrt(12), 1},
+		{Constant, 0.5, 0, 0.5},
+		{Zeros, 0, 0, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.initializer.String(), func(t *testing.T) {
+			m := tt.initializer.initialize(fanOut, fanIn, fanIn, fanOut, 0.5, newRandom(1, nil).rng)
+			if m.cols != fanOut || m.rows != fanIn {
+				t.Fatalf("size %vx%v, want %vx%v", m.cols, m.rows, fanOut, fanIn)
+			}
+			var sum, squares float64
+			for _, v := range m.values {
+				sum += v
+				squares += v * v
+				if tt.limit > 0 && math.Abs(v) > tt.limit {
+					t.Fatalf("value %v is outside ±%v", v, tt.limit)
+				}
+			}
+			count := float64(len(m.values))
+			mean := sum / count
+			sd := math.Sqrt(squares/count - mean*mean)
+
+			// Allow for sampling error in the twenty thousand values.
+			if math.Abs(mean-tt.mean) > 0.05*tt.sd+1e-12 {
+				t.Errorf("mean = %v, want %v", mean, tt.mean)
+			}
+			if math.Abs(sd-tt.sd) > 0.02*tt.sd+1e-12 {
+				t.Errorf("standard deviation = %v, want %v", sd, tt.sd)
+			}
+		})
+	}
+}
+
+func TestOrthogonalInitializer(t *testing.T) {
+	tests := []struct{ cols, rows uint32 }{{3, 5}, {5, 3}, {4, 4}}
+	for _, tt := range tests {
+		m := Orthogonal.initialize(tt.cols, tt.rows, 1, 1, 0, newRandom(1, nil).rng)
+
+		// The shorter dimension of the matrix holds orthonormal vectors.
+		p, err := m.Transpose().Multiply(m)
+		if tt.cols > tt.rows {
+			p, err = m.Multiply(m.Transpose())
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		for r := range int(p.rows) {
+			for c := range int(p.cols) {
+				want := 0.0
+				if r == c {
+					want = 1
+				}
+				if got := p.values[r*int(p.cols)+c]; !near(got, want) {
+					t.Errorf("%vx%v: product at %v,%v = %v, want %v", tt.cols, tt.rows, r, c, got, want)
+				}
+			}
+		}
+	}
+}
+
+func TestDefaultWeightInitializer(t *testing.T) {
+	tests := []struct {
+		activation ActivationFunction
+		want       Initializer
+	}{
+		{Relu, HeNormal},
+		{LeakyRelu, HeNormal},
+		{GELU, HeNormal},
+		{SELU, LeCunNormal},
+		{Sigmoid, GlorotUniform},
+		{Tanh, GlorotUniform},
+	}
+	for _, tt := range tests {
+		if got := defaultWeightInitializer(tt.activation); got != tt.want {
+			t.Errorf("%v: got %v, want %v", tt.activation, got, tt.want)
+		}
+	}
+}
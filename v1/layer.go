@@ -0,0 +1,647 @@
+// layer.go - Layers and the sequential model used to build the neural network.
+//
+// # Copyright 2024 Mark Oxley
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package jasper
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"reflect"
+	"sync"
+)
+
+// Parameter is a trainable matrix of a layer, together with its gradient and
+// the state kept for it by the optimizer.
+type Parameter struct {
+	// Value holds the values of the parameter.
+	Value *Matrix
+
+	// Gradient holds the gradient of the error with respect to Value, as
+	// calculated by the most recent call to Backward.
+	Gradient *Matrix
+
+	// State holds the optimizer state for the parameter.
+	State *OptimizerState
+
+	// Bias indicates that the parameter holds biases, which can be left out
+	// of the L1 and L2 penalties and are never constrained by MaxNorm.
+	Bias bool
+
+	// Regularization, if set, regularizes the parameter in place of the
+	// network's regularization.
+	Regularization *Regularization
+}
+
+// newParameter creates a parameter holding the given values.
+//
+// Parameters:
+// - value: The values of the parameter.
+//
+// Returns:
+// - A pointer to the parameter.
+func newParameter(value *Matrix) *Parameter {
+	return &Parameter{
+		Value: value,
+		State: &OptimizerState{},
+	}
+}
+
+// Layer is the interface implemented by the layers of a Sequential model.
+//
+// Layers work on batches, where each row of a matrix holds the values for one
+// sample.
+//
+// A layer that is not built in must be registered with RegisterLayer before
+// it is used in a model, as a network copies its model when it is created
+// and a layer is copied, saved and loaded through its JSON.
+type Layer interface {
+	// Build prepares the layer to receive inputs of the given width, creating
+	// its parameters if they do not already exist.
+	//
+	// Parameters:
+	// - inputs: The number of values in each input row.
+	//
+	// Returns:
+	// - The number of values in each output row.
+	// - An error if the layer cannot accept inputs of the given width.
+	Build(inputs uint32) (uint32, error)
+
+	// Forward feeds a batch of inputs through the layer.
+	//
+	// Parameters:
+	// - input: A matrix holding one row of input values for each sample.
+	//
+	// Returns:
+	// - A matrix holding one row of output values for each sample.
+	// - An error if the input is the wrong size.
+	Forward(input *Matrix) (*Matrix, error)
+
+	// Backward propagates the gradient of the error back through the layer,
+	// for the batch passed to the most recent call to Forward. The gradients
+	// of the layer's parameters are stored in the parameters.
+	//
+	// Parameters:
+	// - gradient: The gradient of the error with respect to the output of the layer.
+	//
+	// Returns:
+	// - The gradient of the error with respect to the input of the layer.
+	// - An error if the gradient is the wrong size.
+	Backward(gradient *Matrix) (*Matrix, error)
+
+	// Parameters returns the trainable parameters of the layer.
+	Parameters() []*Parameter
+
+	// OutputSize returns the number of values in each output row.
+	OutputSize() uint32
+}
+
+// randomLayer is implemented by layers that use random numbers, such as to
+// initialise their parameters. The network passes its random number generator
+// to these layers so that its results can be reproduced from its seed.
+type randomLayer interface {
+	// setRandom sets the random number generator used by the layer.
+	//
+	// Parameters:
+	// - rng: The random number generator.
+	setRandom(rng *rand.Rand)
+}
+
+// statefulLayer is implemented by layers that keep state outside of their
+// parameters, such as the running averages of a batch normalization layer.
+// The state is copied with the parameters when the best weights are kept by
+// early stopping.
+type statefulLayer interface {
+	// state returns a copy of the state of the layer.
+	//
+	// Returns:
+	// - The matrices holding the state.
+	state() []*Matrix
+
+	// setState replaces the state of the layer with a copy of the state
+	// returned by state.
+	//
+	// Parameters:
+	// - s: The matrices holding the state.
+	setState(s []*Matrix)
+}
+
+// Mode selects whether the layers of a network behave as they do while
+// training or while making predictions.
+type Mode int
+
+const (
+	// Inference is the mode used to make predictions, in which every layer is
+	// deterministic. It is the default mode.
+	Inference Mode = iota
+	// Training is the mode used while training, in which stochastic layers
+	// such as dropout are active.
+	Training
+)
+
+// ModeLayer is implemented by layers that behave differently while training,
+// such as dropout and batch normalization layers. The network passes its mode to these layers.
+type ModeLayer interface {
+	Layer
+
+	// SetMode sets the mode of the layer.
+	//
+	// Parameters:
+	// - m: The mode.
+	SetMode(m Mode)
+}
+
+// registeredLayers holds the layers registered with RegisterLayer.
+var registeredLayers = struct {
+	sync.RWMutex
+
+	// create holds the function that creates each layer, by name.
+	create map[string]func() Layer
+
+	// names holds the name of each layer, by type.
+	names map[reflect.Type]string
+}{
+	create: make(map[string]func() Layer),
+	names:  make(map[reflect.Type]string),
+}
+
+// RegisterLayer registers a layer that is not built in, so that a model using
+// it can be copied, saved and loaded. The layer is copied, saved and loaded
+// through its JSON, so it must marshal every setting and parameter that it
+// needs, such as with MarshalJSON and UnmarshalJSON methods.
+//
+// Parameters:
+// - name: The name used to identify the layer in a saved network.
+// - create: A function that returns an empty instance of the layer, which its
+// JSON is unmarshaled into.
+//
+// Returns:
+// - An error if the name is empty or already used, or create returns nil.
+func RegisterLayer(name string, create func() Layer) error {
+	if name == "" {
+		return errors.New("register layer error: no name")
+	}
+	if getLayer(name) != nil {
+		return fmt.Errorf("register layer error: name already used: %v", name)
+	}
+	l := create()
+	if l == nil {
+		return fmt.Errorf("register layer error: %v: no layer created", name)
+	}
+	registeredLayers.Lock()
+	defer registeredLayers.Unlock()
+	registeredLayers.create[name] = create
+	registeredLayers.names[reflect.TypeOf(l)] = name
+	return nil
+}
+
+// getLayer returns an empty instance of the layer with the given name.
+//
+// Parameters:
+// - name: The name of the layer.
+//
+// Returns:
+// - Layer: An instance of the layer, or nil if the name is unknown.
+func getLayer(name string) Layer {
+	registeredLayers.RLock()
+	create := registeredLayers.create[name]
+	registeredLayers.RUnlock()
+	if create != nil {
+		return create()
+	}
+	switch name {
+	case "dense":
+		return &Dense{}
+	case "sequential":
+		return &Sequential{}
+	case "dropout":
+		return &Dropout{}
+	case "alphadropout":
+		return &AlphaDropout{}
+	case "batchnorm":
+		return &BatchNorm{}
+	case "layernorm":
+		return &LayerNorm{}
+	}
+	return nil
+}
+
+// layerName returns the name used to identify a built in or registered layer
+// in a saved network.
+//
+// Parameters:
+// - l: The layer.
+//
+// Returns:
+// - The name of the layer, or an empty string if it is neither built in nor
+// registered.
+func layerName(l Layer) string {
+	registeredLayers.RLock()
+	name := registeredLayers.names[reflect.TypeOf(l)]
+	registeredLayers.RUnlock()
+	if name != "" {
+		return name
+	}
+	switch l.(type) {
+	case *Dense:
+		return "dense"
+	case *Sequential:
+		return "sequential"
+	case *Dropout:
+		return "dropout"
+	case *AlphaDropout:
+		return "alphadropout"
+	case *BatchNorm:
+		return "batchnorm"
+	case *LayerNorm:
+		return "layernorm"
+	}
+	return ""
+}
+
+// marshalLayer marshals a layer and its parameters into JSON.
+//
+// Parameters:
+// - l: The layer to marshal.
+//
+// Returns:
+// - A JSON byte slice holding the name and content of the layer.
+// - An error if the layer is not built in or cannot be marshaled.
+func marshalLayer(l Layer) ([]byte, error) {
+	name := layerName(l)
+	if name == "" {
+		return nil, fmt.Errorf("cannot save layer of type %T: it has not been registered", l)
+	}
+	params, err := json.Marshal(l)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(&struct {
+		Name   string          `json:"n"`
+		Params json.RawMessage `json:"p"`
+	}{
+		Name:   name,
+		Params: params,
+	})
+}
+
+// unmarshalLayer unmarshals a layer written by marshalLayer.
+//
+// Parameters:
+// - body: The JSON byte slice to unmarshal.
+//
+// Returns:
+// - The layer.
+// - An error if the layer is unknown or cannot be unmarshaled.
+func unmarshalLayer(body []byte) (Layer, error) {
+	data := struct {
+		Name   string          `json:"n"`
+		Params json.RawMessage `json:"p"`
+	}{}
+	if err := json.Unmarshal(body, &data); err != nil {
+		return nil, err
+	}
+	l := getLayer(data.Name)
+	if l == nil {
+		return nil, fmt.Errorf("unknown layer: %v", data.Name)
+	}
+	if err := json.Unmarshal(data.Params, l); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// Sequential is a model made of layers that are applied one after another,
+// with the output of each layer being the input of the next.
+//
+// Sequential is itself a Layer, so models can be nested.
+type Sequential struct {
+	// layers holds the layers of the model in order.
+	layers []Layer
+
+	// inputs is the number of values in each input row, set by Build.
+	inputs uint32
+
+	// outputs holds the output of each layer of the most recent call to
+	// Forward, so that they can be checked for divergence.
+	outputs []*Matrix
+}
+
+// NewSequential creates a sequential model from the given layers.
+//
+// The model is built, creating the parameters of its layers, when it is used
+// to create a network.
+//
+// Parameters:
+// - layers: The layers of the model, in order.
+//
+// Returns:
+// - A pointer to the model.
+func NewSequential(layers ...Layer) *Sequential {
+	return &Sequential{
+		layers: layers,
+	}
+}
+
+// Add appends a layer to the end of the model.
+//
+// Parameters:
+// - l: The layer to add.
+func (s *Sequential) Add(l Layer) {
+	s.layers = append(s.layers, l)
+}
+
+// Layers returns the layers of the model in order.
+//
+// Returns:
+// - A slice of the layers.
+func (s *Sequential) Layers() []Layer {
+	return s.layers
+}
+
+// Build prepares each layer in turn to receive the output of the layer before.
+//
+// Parameters:
+// - inputs: The number of values in each input row.
+//
+// Returns:
+// - The number of values in each output row of the last layer.
+// - An error if a layer cannot be built.
+func (s *Sequential) Build(inputs uint32) (uint32, error) {
+	if len(s.layers) == 0 {
+		return 0, errors.New("model has no layers")
+	}
+	s.inputs = inputs
+	size := inputs
+	for i, l := range s.layers {
+		var err error
+		if size, err = l.Build(size); err != nil {
+			return 0, fmt.Errorf("layer %v: %v", i, err)
+		}
+	}
+	return size, nil
+}
+
+// Forward feeds a batch of inputs through each layer in turn.
+//
+// Parameters:
+// - input: A matrix holding one row of input values for each sample.
+//
+// Returns:
+// - A matrix holding one row of output values for each sample.
+// - An error if the input is the wrong size.
+func (s *Sequential) Forward(input *Matrix) (*Matrix, error) {
+	if input.cols != s.inputs {
+		return nil, errors.New("incorrect input size")
+	}
+	values := input
+	s.outputs = s.outputs[:0]
+	for _, l := range s.layers {
+		var err error
+		if values, err = l.Forward(values); err != nil {
+			return nil, err
+		}
+		s.outputs = append(s.outputs, values)
+	}
+	return values, nil
+}
+
+// Backward propagates the gradient of the error back through each layer, from
+// the last layer to the first.
+//
+// Parameters:
+// - gradient: The gradient of the error with respect to the output of the model.
+//
+// Returns:
+// - The gradient of the error with respect to the input of the model.
+// - An error if the gradient is the wrong size.
+func (s *Sequential) Backward(gradient *Matrix) (*Matrix, error) {
+	for i := len(s.layers) - 1; i >= 0; i-- {
+		var err error
+		if gradient, err = s.layers[i].Backward(gradient); err != nil {
+			return nil, err
+		}
+	}
+	return gradient, nil
+}
+
+// Parameters returns the trainable parameters of every layer, in order.
+//
+// Returns:
+// - A slice of the parameters.
+func (s *Sequential) Parameters() []*Parameter {
+	var res []*Parameter
+	for _, l := range s.layers {
+		res = append(res, l.Parameters()...)
+	}
+	return res
+}
+
+// OutputSize returns the number of values in each output row of the last layer.
+//
+// Returns:
+// - The number of output values.
+func (s *Sequential) OutputSize() uint32 {
+	if len(s.layers) == 0 {
+		return s.inputs
+	}
+	return s.layers[len(s.layers)-1].OutputSize()
+}
+
+// setRandom sets the random number generator used by each layer that uses
+// random numbers.
+//
+// Parameters:
+// - rng: The random number generator.
+func (s *Sequential) setRandom(rng *rand.Rand) {
+	for _, l := range s.layers {
+		if r, ok := l.(randomLayer); ok {
+			r.setRandom(rng)
+		}
+	}
+}
+
+// SetMode sets the mode of each layer that behaves differently while training.
+//
+// Parameters:
+// - m: The mode.
+func (s *Sequential) SetMode(m Mode) {
+	for _, l := range s.layers {
+		if ml, ok := l.(ModeLayer); ok {
+			ml.SetMode(m)
+		}
+	}
+}
+
+// batchNormalized returns true if the model, or a model within it, has a
+// batch normalization layer.
+//
+// Returns:
+// - True if there is a batch normalization layer.
+func (s *Sequential) batchNormalized() bool {
+	for _, l := range s.layers {
+		switch l := l.(type) {
+		case *BatchNorm:
+			return true
+		case *Sequential:
+			if l.batchNormalized() {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// state returns a copy of the state of every layer that keeps state outside
+// of its parameters, in order.
+//
+// Returns:
+// - The matrices holding the state.
+func (s *Sequential) state() []*Matrix {
+	var res []*Matrix
+	for _, l := range s.layers {
+		if sl, ok := l.(statefulLayer); ok {
+			res = append(res, sl.state()...)
+		}
+	}
+	return res
+}
+
+// setState replaces the state of every layer that keeps state outside of its
+// parameters, in order.
+//
+// Parameters:
+// - st: The matrices holding the state, as returned by state.
+func (s *Sequential) setState(st []*Matrix) {
+	for _, l := range s.layers {
+		sl, ok := l.(statefulLayer)
+		if !ok {
+			continue
+		}
+		count := min(len(sl.state()), len(st))
+		sl.setState(st[:count])
+		st = st[count:]
+	}
+}
+
+// topology returns the number of inputs followed by the number of outputs of
+// each layer. Dropout and normalization layers, which pass on the size of
+// their inputs, are left out.
+//
+// Returns:
+// - A slice holding the size of each layer, starting with the inputs.
+func (s *Sequential) topology() []uint32 {
+	res := []uint32{s.inputs}
+	for _, l := range s.layers {
+		switch l.(type) {
+		case *Dropout, *AlphaDropout, *BatchNorm, *LayerNorm:
+			continue
+		}
+		res = append(res, l.OutputSize())
+	}
+	return res
+}
+
+// activations returns the activation function of each dense layer of the
+// model, including those of nested models, in order.
+//
+// Returns:
+// - A slice holding the activation functions.
+func (s *Sequential) activations() []ActivationFunction {
+	var res []ActivationFunction
+	for _, l := range s.layers {
+		switch l := l.(type) {
+		case *Dense:
+			res = append(res, l.Activation())
+		case *Sequential:
+			res = append(res, l.activations()...)
+		}
+	}
+	return res
+}
+
+// copy returns a copy of the model that shares no layers with the original.
+//
+// Returns:
+// - A pointer to the copy.
+// - An error if a layer is neither built in nor registered, or cannot be
+// copied.
+func (s *Sequential) copy() (*Sequential, error) {
+	res := &Sequential{inputs: s.inputs}
+	for _, l := range s.layers {
+		if layerName(l) == "" {
+			return nil, fmt.Errorf("cannot copy layer of type %T: it has not been registered", l)
+		}
+		body, err := marshalLayer(l)
+		if err != nil {
+			return nil, err
+		}
+		c, err := unmarshalLayer(body)
+		if err != nil {
+			return nil, err
+		}
+		res.layers = append(res.layers, c)
+	}
+	return res, nil
+}
+
+// MarshalJSON marshals the model and its layers into JSON.
+//
+// Returns:
+// - A JSON byte slice representing the model.
+// - An error if a layer cannot be marshaled.
+func (s *Sequential) MarshalJSON() ([]byte, error) {
+	layers := make([]json.RawMessage, len(s.layers))
+	for i, l := range s.layers {
+		var err error
+		if layers[i], err = marshalLayer(l); err != nil {
+			return nil, err
+		}
+	}
+	return json.Marshal(&struct {
+		Inputs uint32            `json:"i"`
+		Layers []json.RawMessage `json:"l"`
+	}{
+		Inputs: s.inputs,
+		Layers: layers,
+	})
+}
+
+// UnmarshalJSON unmarshals the model and its layers from JSON.
+//
+// Parameters:
+// - body: The JSON byte slice to unmarshal.
+//
+// Returns:
+// - An error if a layer cannot be unmarshaled.
+func (s *Sequential) UnmarshalJSON(body []byte) error {
+	data := struct {
+		Inputs uint32            `json:"i"`
+		Layers []json.RawMessage `json:"l"`
+	}{}
+	if err := json.Unmarshal(body, &data); err != nil {
+		return err
+	}
+	s.inputs = data.Inputs
+	s.layers = make([]Layer, len(data.Layers))
+	for i, raw := range data.Layers {
+		var err error
+		if s.layers[i], err = unmarshalLayer(raw); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,193 @@
+package jasper
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+// scaleLayer is a layer that is not built in, which multiplies each input by
+// a learnt scale.
+type scaleLayer struct {
+	// Scale holds the single row matrix of scales.
+	Scale *Matrix `json:"s"`
+
+	// param holds the scales as a parameter.
+	param *Parameter
+
+	// input holds the input of the most recent call to Forward.
+	input *Matrix
+}
+
+func init() {
+	if err := RegisterLayer("scale", func() Layer { return &scaleLayer{} }); err != nil {
+		panic(err)
+	}
+}
+
+// Build creates a scale of one for each input.
+func (l *scaleLayer) Build(inputs uint32) (uint32, error) {
+	if l.Scale == nil {
+		l.Scale = NewMatrix(inputs, 1)
+		for k := range l.Scale.values {
+			l.Scale.values[k] = 1
+		}
+	}
+	if l.Scale.cols != inputs {
+		return 0, errors.New("incorrect input size")
+	}
+	l.param = newParameter(l.Scale)
+	return inputs, nil
+}
+
+// Forward multiplies each input by its scale.
+func (l *scaleLayer) Forward(input *Matrix) (*Matrix, error) {
+	l.input = input
+	res := input.Copy()
+	for k := range res.values {
+		res.values[k] *= l.Scale.values[k%int(res.cols)]
+	}
+	return res, nil
+}
+
+// Backward calculates the gradient of the scales and of the inputs.
+func (l *scaleLayer) Backward(gradient *Matrix) (*Matrix, error) {
+	l.param.Gradient = NewMatrix(l.Scale.cols, 1)
+	res := gradient.Copy()
+	for k, g := range gradient.values {
+		c := k % int(gradient.cols)
+		l.param.Gradient.values[c] += g * l.input.values[k]
+		res.values[k] = g * l.Scale.values[c]
+	}
+	return res, nil
+}
+
+// Parameters returns the scales.
+func (l *scaleLayer) Parameters() []*Parameter {
+	if l.param == nil {
+		return nil
+	}
+	return []*Parameter{l.param}
+}
+
+// OutputSize returns the number of scales.
+func (l *scaleLayer) OutputSize() uint32 {
+	if l.Scale == nil {
+		return 0
+	}
+	return l.Scale.cols
+}
+
+// unregisteredLayer is a layer that is not built in or registered.
+type unregisteredLayer struct {
+	scaleLayer
+}
+
+// scaleModel returns a model holding a scale layer between dense layers.
+func scaleModel() *Sequential {
+	return NewSequential(NewDense(4, Tanh), &scaleLayer{}, NewDense(1, Sigmoid))
+}
+
+// xorRows returns the rows of the exclusive or function.
+func xorRows() []*DataRow {
+	var rows []*DataRow
+	for _, v := range [][]float64{{0, 0, 0}, {0, 1, 1}, {1, 0, 1}, {1, 1, 0}} {
+		rows = append(rows, &DataRow{Input: v[:2], Ouput: v[2:]})
+	}
+	return rows
+}
+
+func TestSequentialCustomLayer(t *testing.T) {
+	c := NewConfig([]uint32{2})
+	c.Model = scaleModel()
+	c.Seed = 1
+	c.Quiet = true
+	n, err := New(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := GradientCheck(n, xorRows(), Inference, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.MaxRelativeError > gradientTolerance || r.Layers[1].Name != "scale" {
+		t.Errorf("gradient check:\n%v", r)
+	}
+	h, err := n.Train(&TrainingData{TrainingSource: NewSliceSource(xorRows()), Iterations: 200, BatchSize: 4})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h.Epochs[len(h.Epochs)-1].TrainLoss >= h.Epochs[0].TrainLoss {
+		t.Errorf("loss did not fall: %v to %v", h.Epochs[0].TrainLoss, h.Epochs[len(h.Epochs)-1].TrainLoss)
+	}
+	scale := n.model.layers[1].(*scaleLayer).Scale
+	if scale.values[0] == 1 && scale.values[1] == 1 {
+		t.Error("the scales were not trained")
+	}
+
+	// The saved network predicts as the original does.
+	body, err := json.Marshal(n)
+	if err != nil {
+		t.Fatal(err)
+	}
+	loaded := &Network{}
+	if err := json.Unmarshal(body, loaded); err != nil {
+		t.Fatal(err)
+	}
+	for _, row := range xorRows() {
+		want, _ := n.Predict(row.Input)
+		got, err := loaded.Predict(row.Input)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got[0] != want[0] {
+			t.Errorf("loaded prediction of %v = %v, want %v", row.Input, got, want)
+		}
+	}
+}
+
+func TestSequentialCopiesCustomLayers(t *testing.T) {
+	c := NewConfig([]uint32{2})
+	c.Model = scaleModel()
+	a, err := New(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := New(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a.model.layers[1].(*scaleLayer).Scale.values[0] = 5
+	if got := b.model.layers[1].(*scaleLayer).Scale.values[0]; got != 1 {
+		t.Errorf("scale of the second network = %v, want 1", got)
+	}
+	if c.Model.layers[1] == a.model.layers[1] {
+		t.Error("the network shares the layer of the configuration")
+	}
+}
+
+func TestSequentialUnregisteredLayer(t *testing.T) {
+	c := NewConfig([]uint32{2})
+	c.Model = NewSequential(NewDense(4, Tanh), &unregisteredLayer{}, NewDense(1, Sigmoid))
+	if _, err := New(c); err == nil {
+		t.Error("expected an error")
+	}
+}
+
+func TestRegisterLayerErrors(t *testing.T) {
+	create := func() Layer { return &unregisteredLayer{} }
+	tests := []struct {
+		name   string
+		create func() Layer
+	}{
+		{"", create},
+		{"dense", create},
+		{"scale", create},
+		{"empty", func() Layer { return nil }},
+	}
+	for _, tt := range tests {
+		if err := RegisterLayer(tt.name, tt.create); err == nil {
+			t.Errorf("%q: expected an error", tt.name)
+		}
+	}
+}
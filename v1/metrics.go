@@ -0,0 +1,391 @@
+// metrics.go - Classification metrics used to evaluate the neural network.
+//
+// # Copyright 2024 Mark Oxley
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package jasper
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"text/tabwriter"
+)
+
+// logLossEpsilon is the smallest probability used when calculating the log
+// loss, so a confident wrong prediction does not give an infinite loss.
+const logLossEpsilon = 1e-15
+
+// Report holds the classification metrics of a network's predictions for a
+// set of rows.
+//
+// The class of a row with a single output is 0 if the output is below 0.5 and
+// 1 otherwise, and the output is the probability of class 1. For several
+// outputs the class is the index of the largest output, and the outputs,
+// normalised to sum to one, are the probabilities of each class.
+type Report struct {
+	// Rows is the number of rows evaluated.
+	Rows int
+
+	// Classes is the number of classes.
+	Classes int
+
+	// Accuracy is the proportion of rows predicted correctly.
+	Accuracy float64
+
+	// Precision holds the precision of each class, the proportion of the rows
+	// predicted to be in the class that are.
+	Precision []float64
+
+	// Recall holds the recall of each class, the proportion of the rows in the
+	// class that are predicted to be.
+	Recall []float64
+
+	// F1 holds the F1 score of each class, the harmonic mean of its precision
+	// and recall.
+	F1 []float64
+
+	// Support holds the number of rows in each class.
+	Support []int
+
+	// MacroPrecision, MacroRecall and MacroF1 are the unweighted means of the
+	// metrics of the classes.
+	MacroPrecision, MacroRecall, MacroF1 float64
+
+	// MicroPrecision, MicroRecall and MicroF1 are calculated from the total
+	// true positives, false positives and false negatives of every class.
+	MicroPrecision, MicroRecall, MicroF1 float64
+
+	// WeightedPrecision, WeightedRecall and WeightedF1 are the means of the
+	// metrics of the classes, weighted by their support.
+	WeightedPrecision, WeightedRecall, WeightedF1 float64
+
+	// ConfusionMatrix holds the number of rows of each actual class, by row,
+	// predicted to be in each class, by column.
+	ConfusionMatrix [][]int
+
+	// ROCAUC is the area under the receiver operating characteristic curve.
+	// For two classes it is the area for class 1, otherwise it is the mean of
+	// the areas of each class against the rest. It is NaN if it cannot be
+	// calculated because every row is in the same class.
+	ROCAUC float64
+
+	// PRAUC is the area under the precision-recall curve, calculated as the
+	// average precision, for class 1 or averaged over the classes as ROCAUC.
+	PRAUC float64
+
+	// LogLoss is the mean negative log of the probability predicted for the
+	// actual class of each row.
+	LogLoss float64
+
+	// TopK holds the top-k accuracy for k from 1 to the number of classes, at
+	// index k-1. A row is counted if its actual class is among the k classes
+	// with the highest probabilities.
+	TopK []float64
+}
+
+// Evaluate calculates the classification metrics of a network's predictions
+// for a set of rows.
+//
+// Each row's inputs are predicted with Predict and compared with its outputs.
+//
+// Parameters:
+// - net: The network to evaluate.
+// - rows: The rows to predict.
+//
+// Returns:
+// - A pointer to the report of the metrics.
+// - An error if there are no rows or a row cannot be predicted.
+func Evaluate(net *Network, rows []*DataRow) (*Report, error) {
+	if len(rows) == 0 {
+		return nil, errors.New("evaluation error: no rows")
+	}
+
+	// Predict the probabilities of the classes of each row.
+	outputs := len(rows[0].Ouput)
+	if outputs == 0 {
+		return nil, errors.New("evaluation error: no outputs")
+	}
+	classes := max(outputs, 2)
+	actual := make([]int, len(rows))
+	predicted := make([]int, len(rows))
+	scores := make([][]float64, len(rows))
+	for i, row := range rows {
+		if len(row.Ouput) != outputs {
+			return nil, fmt.Errorf("evaluation error: row %v: output is incorrect size", i)
+		}
+		p, err := net.Predict(row.Input)
+		if err != nil {
+			return nil, fmt.Errorf("evaluation error: row %v: %v", i, err)
+		}
+		if len(p) != outputs {
+			return nil, fmt.Errorf("evaluation error: row %v: output is incorrect size", i)
+		}
+		actual[i] = class(row.Ouput)
+		predicted[i] = class(p)
+		scores[i] = probabilities(p)
+	}
+
+	r := &Report{
+		Rows:      len(rows),
+		Classes:   classes,
+		Precision: make([]float64, classes),
+		Recall:    make([]float64, classes),
+		F1:        make([]float64, classes),
+		Support:   make([]int, classes),
+	}
+
+	// Count the predictions of each class, and the log loss.
+	r.ConfusionMatrix = make([][]int, classes)
+	for c := range r.ConfusionMatrix {
+		r.ConfusionMatrix[c] = make([]int, classes)
+	}
+	for i, ps := range scores {
+		r.ConfusionMatrix[actual[i]][predicted[i]]++
+		r.Support[actual[i]]++
+		if predicted[i] == actual[i] {
+			r.Accuracy++
+		}
+		r.LogLoss -= math.Log(min(max(ps[actual[i]], logLossEpsilon), 1-logLossEpsilon))
+	}
+	r.Accuracy /= float64(len(rows))
+	r.LogLoss /= float64(len(rows))
+
+	// Calculate the precision, recall and F1 score of each class and their averages.
+	var tpSum, fpSum, fnSum int
+	for c := range classes {
+		tp := r.ConfusionMatrix[c][c]
+		fp, fn := -tp, -tp
+		for k := range classes {
+			fp += r.ConfusionMatrix[k][c]
+			fn += r.ConfusionMatrix[c][k]
+		}
+		tpSum += tp
+		fpSum += fp
+		fnSum += fn
+		r.Precision[c] = ratio(tp, tp+fp)
+		r.Recall[c] = ratio(tp, tp+fn)
+		r.F1[c] = harmonicMean(r.Precision[c], r.Recall[c])
+
+		weight := float64(r.Support[c]) / float64(len(rows))
+		r.MacroPrecision += r.Precision[c] / float64(classes)
+		r.MacroRecall += r.Recall[c] / float64(classes)
+		r.MacroF1 += r.F1[c] / float64(classes)
+		r.WeightedPrecision += r.Precision[c] * weight
+		r.WeightedRecall += r.Recall[c] * weight
+		r.WeightedF1 += r.F1[c] * weight
+	}
+	r.MicroPrecision = ratio(tpSum, tpSum+fpSum)
+	r.MicroRecall = ratio(tpSum, tpSum+fnSum)
+	r.MicroF1 = harmonicMean(r.MicroPrecision, r.MicroRecall)
+
+	// Calculate the areas under the curves, for class 1 alone if there are two
+	// classes, otherwise averaged over the classes that can be scored.
+	first := 0
+	if classes == 2 {
+		first = 1
+	}
+	var rocSum, prSum float64
+	scored := 0
+	for c := first; c < classes; c++ {
+		roc, pr, ok := classAUC(c, actual, scores)
+		if !ok {
+			continue
+		}
+		rocSum += roc
+		prSum += pr
+		scored++
+	}
+	r.ROCAUC, r.PRAUC = math.NaN(), math.NaN()
+	if scored > 0 {
+		r.ROCAUC = rocSum / float64(scored)
+		r.PRAUC = prSum / float64(scored)
+	}
+
+	// Calculate the top-k accuracy for every k.
+	r.TopK = make([]float64, classes)
+	for i, ps := range scores {
+		higher := 0
+		for _, p := range ps {
+			if p > ps[actual[i]] {
+				higher++
+			}
+		}
+		for k := higher; k < classes; k++ {
+			r.TopK[k]++
+		}
+	}
+	for k := range r.TopK {
+		r.TopK[k] /= float64(len(rows))
+	}
+	return r, nil
+}
+
+// probabilities converts the outputs of a network to the probability of each
+// class.
+//
+// Parameters:
+// - outputs: The outputs of the network.
+//
+// Returns:
+// - The probability of each class.
+func probabilities(outputs []float64) []float64 {
+	if len(outputs) == 1 {
+		p := min(max(outputs[0], 0), 1)
+		return []float64{1 - p, p}
+	}
+	res := make([]float64, len(outputs))
+	var sum float64
+	for i, v := range outputs {
+		res[i] = max(v, 0)
+		sum += res[i]
+	}
+	if sum > 0 {
+		for i := range res {
+			res[i] /= sum
+		}
+	}
+	return res
+}
+
+// classAUC calculates the areas under the receiver operating characteristic
+// and precision-recall curves of one class against the rest.
+//
+// Parameters:
+// - c: The class.
+// - actual: The actual class of each row.
+// - scores: The probabilities of the classes of each row.
+//
+// Returns:
+// - The area under the receiver operating characteristic curve.
+// - The average precision.
+// - False if every row is in the class or none are.
+func classAUC(c int, actual []int, scores [][]float64) (float64, float64, bool) {
+	// Order the rows from the highest score for the class to the lowest.
+	index := make([]int, len(actual))
+	positives := 0
+	for i := range index {
+		index[i] = i
+		if actual[i] == c {
+			positives++
+		}
+	}
+	negatives := len(actual) - positives
+	if positives == 0 || negatives == 0 {
+		return 0, 0, false
+	}
+	sort.SliceStable(index, func(i, j int) bool {
+		return scores[index[i]][c] > scores[index[j]][c]
+	})
+
+	// Step through each distinct score, treating rows with equal scores as a
+	// single threshold. The area under the ROC curve is the proportion of
+	// pairs of positive and negative rows that are ordered correctly, with
+	// ties counting a half.
+	var roc, pr, recall float64
+	tp, fp := 0, 0
+	for start := 0; start < len(index); {
+		end := start
+		groupTP, groupFP := 0, 0
+		for end < len(index) && scores[index[end]][c] == scores[index[start]][c] {
+			if actual[index[end]] == c {
+				groupTP++
+			} else {
+				groupFP++
+			}
+			end++
+		}
+		roc += float64(groupTP) * (float64(negatives-fp) - float64(groupFP)/2)
+		tp += groupTP
+		fp += groupFP
+		next := float64(tp) / float64(positives)
+		pr += (next - recall) * float64(tp) / float64(tp+fp)
+		recall = next
+		start = end
+	}
+	return roc / float64(positives*negatives), pr, true
+}
+
+// ratio divides two counts.
+//
+// Parameters:
+// - a: The numerator.
+// - b: The denominator.
+//
+// Returns:
+// - a divided by b, or zero if b is zero.
+func ratio(a, b int) float64 {
+	if b == 0 {
+		return 0
+	}
+	return float64(a) / float64(b)
+}
+
+// harmonicMean calculates the harmonic mean of two values.
+//
+// Parameters:
+// - a: The first value.
+// - b: The second value.
+//
+// Returns:
+// - The harmonic mean, or zero if both values are zero.
+func harmonicMean(a, b float64) float64 {
+	if a+b == 0 {
+		return 0
+	}
+	return 2 * a * b / (a + b)
+}
+
+// String formats the report as text tables of the metrics of each class, the
+// confusion matrix and the overall metrics.
+func (r *Report) String() string {
+	var sb strings.Builder
+	w := tabwriter.NewWriter(&sb, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(w, "\tprecision\trecall\tf1-score\tsupport\t")
+	for c := range r.Classes {
+		fmt.Fprintf(w, "%v\t%.4f\t%.4f\t%.4f\t%v\t\n", c, r.Precision[c], r.Recall[c], r.F1[c], r.Support[c])
+	}
+	fmt.Fprintf(w, "accuracy\t\t\t%.4f\t%v\t\n", r.Accuracy, r.Rows)
+	fmt.Fprintf(w, "macro avg\t%.4f\t%.4f\t%.4f\t%v\t\n", r.MacroPrecision, r.MacroRecall, r.MacroF1, r.Rows)
+	fmt.Fprintf(w, "micro avg\t%.4f\t%.4f\t%.4f\t%v\t\n", r.MicroPrecision, r.MicroRecall, r.MicroF1, r.Rows)
+	fmt.Fprintf(w, "weighted avg\t%.4f\t%.4f\t%.4f\t%v\t\n", r.WeightedPrecision, r.WeightedRecall, r.WeightedF1, r.Rows)
+	w.Flush()
+
+	sb.WriteString("\nconfusion matrix (rows are actual, columns predicted)\n")
+	w = tabwriter.NewWriter(&sb, 0, 0, 2, ' ', tabwriter.AlignRight)
+	for c := range r.Classes {
+		fmt.Fprintf(w, "\t%v", c)
+	}
+	fmt.Fprintln(w, "\t")
+	for c, counts := range r.ConfusionMatrix {
+		fmt.Fprintf(w, "%v", c)
+		for _, count := range counts {
+			fmt.Fprintf(w, "\t%v", count)
+		}
+		fmt.Fprintln(w, "\t")
+	}
+	w.Flush()
+
+	sb.WriteString("\n")
+	w = tabwriter.NewWriter(&sb, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "roc auc\t%.4f\n", r.ROCAUC)
+	fmt.Fprintf(w, "pr auc\t%.4f\n", r.PRAUC)
+	fmt.Fprintf(w, "log loss\t%.4f\n", r.LogLoss)
+	for k, acc := range r.TopK {
+		fmt.Fprintf(w, "top-%v accuracy\t%.4f\n", k+1, acc)
+	}
+	w.Flush()
+	return sb.String()
+}
@@ -0,0 +1,130 @@
+package jasper
+
+import (
+	"math"
+	"testing"
+)
+
+// passThrough creates a network whose predictions are its inputs, so that
+// the predictions can be given as the inputs of the rows evaluated.
+func passThrough(t *testing.T, size uint32) *Network {
+	t.Helper()
+	c := NewConfig([]uint32{size, size})
+	c.Output = Linear
+	c.Quiet = true
+	n, err := New(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range n.model.Parameters() {
+		for k := range p.Value.values {
+			p.Value.values[k] = 0
+			if !p.Bias && k%int(size+1) == 0 {
+				p.Value.values[k] = 1
+			}
+		}
+	}
+	return n
+}
+
+func TestEvaluateBinary(t *testing.T) {
+	n := passThrough(t, 1)
+	rows := []*DataRow{
+		{Input: []float64{0.1}, Ouput: []float64{0}},
+		{Input: []float64{0.4}, Ouput: []float64{0}},
+		{Input: []float64{0.35}, Ouput: []float64{1}},
+		{Input: []float64{0.8}, Ouput: []float64{1}},
+	}
+	r, err := Evaluate(n, rows)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name      string
+		got, want float64
+	}{
+		{"accuracy", r.Accuracy, 0.75},
+		{"precision 0", r.Precision[0], 2.0 / 3},
+		{"precision 1", r.Precision[1], 1},
+		{"recall 0", r.Recall[0], 1},
+		{"recall 1", r.Recall[1], 0.5},
+		{"f1 1", r.F1[1], 2.0 / 3},
+		{"roc auc", r.ROCAUC, 0.75},
+		{"pr auc", r.PRAUC, 0.8333},
+		{"log loss", r.LogLoss, 0.4723},
+	}
+	for _, tt := range tests {
+		if !near(tt.got, tt.want) {
+			t.Errorf("%v = %v, want %v", tt.name, tt.got, tt.want)
+		}
+	}
+	want := [][]int{{2, 0}, {1, 1}}
+	for a := range want {
+		for p := range want[a] {
+			if r.ConfusionMatrix[a][p] != want[a][p] {
+				t.Fatalf("confusion matrix = %v, want %v", r.ConfusionMatrix, want)
+			}
+		}
+	}
+}
+
+func TestEvaluateMulticlass(t *testing.T) {
+	n := passThrough(t, 3)
+	rows := []*DataRow{
+		{Input: []float64{0.7, 0.2, 0.1}, Ouput: []float64{1, 0, 0}},
+		{Input: []float64{0.2, 0.5, 0.3}, Ouput: []float64{0, 1, 0}},
+		{Input: []float64{0.1, 0.6, 0.3}, Ouput: []float64{0, 0, 1}},
+		{Input: []float64{0.3, 0.3, 0.4}, Ouput: []float64{0, 0, 1}},
+	}
+	r, err := Evaluate(n, rows)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name      string
+		got, want float64
+	}{
+		{"accuracy", r.Accuracy, 0.75},
+		{"precision 1", r.Precision[1], 0.5},
+		{"recall 2", r.Recall[2], 0.5},
+		{"macro precision", r.MacroPrecision, 2.5 / 3},
+		{"micro f1", r.MicroF1, 0.75},
+		{"weighted recall", r.WeightedRecall, 0.75},
+		{"top 1", r.TopK[0], 0.75},
+		{"top 2", r.TopK[1], 1},
+	}
+	for _, tt := range tests {
+		if !near(tt.got, tt.want) {
+			t.Errorf("%v = %v, want %v", tt.name, tt.got, tt.want)
+		}
+	}
+	if r.Classes != 3 || r.Support[2] != 2 {
+		t.Errorf("classes = %v, support = %v", r.Classes, r.Support)
+	}
+}
+
+func TestEvaluateSingleClass(t *testing.T) {
+	n := passThrough(t, 1)
+	rows := []*DataRow{
+		{Input: []float64{0.2}, Ouput: []float64{1}},
+		{Input: []float64{0.9}, Ouput: []float64{1}},
+	}
+	r, err := Evaluate(n, rows)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !math.IsNaN(r.ROCAUC) || !math.IsNaN(r.PRAUC) {
+		t.Errorf("areas = %v, %v, want NaN", r.ROCAUC, r.PRAUC)
+	}
+}
+
+func TestEvaluateErrors(t *testing.T) {
+	n := passThrough(t, 1)
+	if _, err := Evaluate(n, nil); err == nil {
+		t.Error("no rows: expected an error")
+	}
+	rows := []*DataRow{{Input: []float64{0.2}, Ouput: []float64{1, 0}}}
+	if _, err := Evaluate(n, rows); err == nil {
+		t.Error("wrong output size: expected an error")
+	}
+}
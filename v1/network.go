@@ -1,517 +1,1269 @@
-package jasper
-
-import (
-	"encoding/json"
-	"errors"
-	"fmt"
-	"math"
-	"math/rand"
-	"time"
-)
-
-// Network represents a neural network.
-type Network struct {
-	// topology is the configuration of the network, a slice of uint32 that
-	// represents the number of neurons in each layer.
-	topology []uint32
-
-	// weightMatrices is a slice of weight matrices, each matrix is a connection
-	// between two layers.
-	weightMatrices []*Matrix
-
-	// valueMatrices is a slice of matrices that represent the output values of
-	// each layer.
-	valueMatrices []*Matrix
-
-	// biasMatrices is a slice of bias matrices, each matrix is a bias for each
-	// layer.
-	biasMatrices []*Matrix
-
-	// learningRate is a float64 that represents the learning rate of the network.
-	learningRate float64
-
-	// activation is the activation function used in the network.
-	activation ActivationFunction
-
-	// solver is the solver for the activation function.
-	solver activationSolver
-
-	// output is the output activation function of the network.
-	output ActivationFunction
-
-	// outputSolver is the solver for the output activation function.
-	outputSolver activationSolver
-
-	// errFunc is the error function used in the network.
-	errFunc ErrorFunction
-
-	// errorSolver is the solver for the error function.
-	errorSolver errorSolver
-
-	// debug is a boolean that indicates if the network is in debug mode.
-	debug bool
-
-	// sm is a boolean that indicates if the network should use soft max.
-	sm bool
-}
-
-// getRandom generates a random float64 using the math/rand package.
-//
-// The parameter is unused and is only included to maintain the same function
-// signature as getRandomFloats.
-//
-// Returns:
-// - A random float64.
-func getRandom(unused float64) float64 {
-	// Generate a random float64 using the math/rand package.
-	// The random float64 is between 0 and 1.
-	return rand.Float64()
-}
-
-// softMax calculates the softmax function on a given Matrix.
-//
-// The softmax function is used to normalize a set of values into a probability distribution.
-// It takes a Matrix as input and returns a new Matrix with the same dimensions.
-//
-// Parameters:
-// - vs: A pointer to a Matrix representing the input values.
-//
-// Returns:
-// - A pointer to a new Matrix with the same dimensions as the input Matrix, containing the softmax values.
-func softMax(vs *Matrix) *Matrix {
-	// Calculate the total sum of the exponentials of the input values.
-	// This is used to normalize the output values.
-	var total float64
-
-	// Create an output slice to hold the result of applying the softmax function.
-	output := make([]float64, len(vs.Values()))
-
-	// Iterate over the input slice and calculate the exponential of each value.
-	// Add each value to the total sum.
-	for i, v := range vs.values {
-		output[i] = math.Exp(v)
-		total += v
-	}
-
-	// Iterate over the output slice and divide each value by the total sum.
-	// This normalizes the output values to be between 0 and 1.
-	for i := range vs.values {
-		output[i] /= total
-	}
-
-	// Return the output slice.
-	return NewMatrixFromSlice(output)
-}
-
-// New creates a new instance of the Network struct.
-//
-// Parameters:
-// - c: A pointer to the NetworkConfiguration struct that contains the configuration settings for the network.
-//
-// Returns:
-// - A pointer to the newly created Network struct and an error if any.
-func New(c *NetworkConfiguration) (*Network, error) {
-	// Create a new instance of the Network struct using the configuration settings.
-	s := Network{
-		topology:     c.Topology,                           // Set the topology of the network.
-		learningRate: c.LearningRate,                       // Set the learning rate of the network.
-		activation:   c.Activation,                         // Set the function name of the network.
-		solver:       getActivationFunctions(c.Activation), // Set the activation function of the network.
-		output:       c.Output,
-		outputSolver: getActivationFunctions(c.Output),
-		errFunc:      c.Error,
-		errorSolver:  getErrorFunction(c.Error),
-		debug:        !c.Quiet, // Set the debug mode of the network.
-		sm:           c.SoftMax,
-	}
-
-	// Iterate over each layer of the network.
-	for i := 0; i < len(s.topology)-1; i++ {
-		// Create a new weight matrix for the current layer.
-		wm := NewMatrix(s.topology[i+1], s.topology[i])                          // Set the dimensions of the weight matrix.
-		s.weightMatrices = append(s.weightMatrices, wm.ApplyFunction(getRandom)) // Apply a random function to each element of the weight matrix.
-
-		// Create a new bias matrix for the current layer.
-		bm := NewMatrix(s.topology[i+1], 1)                                  // Set the dimensions of the bias matrix.
-		s.biasMatrices = append(s.biasMatrices, bm.ApplyFunction(getRandom)) // Apply a random function to each element of the bias matrix.
-	}
-
-	// Create a slice to store the value matrices for each layer.
-	s.valueMatrices = make([]*Matrix, len(s.topology))
-
-	// Return the newly created Network struct.
-	return &s, nil
-}
-
-// feedForward performs a feed-forward operation on the network.
-//
-// Parameters:
-// - input: A slice of floats representing the input values.
-//
-// Returns:
-// - An error if the input size is incorrect.
-func (n *Network) feedForward(input []float64) error {
-	// Check if the input size is correct.
-	if len(input) != int(n.topology[0]) {
-		return errors.New("incorrect input size")
-	}
-
-	// Create a new matrix to hold the input values.
-	values := NewMatrix(uint32(len(input)), 1)
-
-	// Populate the input values into the matrix.
-	for i, in := range input {
-		values.Set(uint32(i), 0, in)
-	}
-
-	var err error
-
-	// Feed forward to each layer.
-	for i, w := range n.weightMatrices {
-		// Set the current layer's values to the input values.
-		n.valueMatrices[i] = values
-
-		// Multiply the input values with the weight matrix.
-		values, err = values.Multiply(w)
-		if err != nil {
-			return fmt.Errorf("feed forward error: %v", err)
-		}
-
-		// Add the bias values to the current layer's values.
-		values, err = values.Add(n.biasMatrices[i])
-		if err != nil {
-			return fmt.Errorf("feed forward error: %v", err)
-		}
-
-		// Apply the activation function to the current layer's values.
-		if i < len(n.weightMatrices)-1 {
-			values = values.ApplyFunction(n.solver.f)
-		} else {
-			values = values.ApplyFunction(n.outputSolver.f)
-		}
-	}
-
-	// Set the output values of the network to the final layer's values.
-	n.valueMatrices[len(n.weightMatrices)] = values
-
-	if n.sm {
-		n.valueMatrices[len(n.weightMatrices)] = softMax(values)
-	} else {
-		n.valueMatrices[len(n.weightMatrices)] = values
-	}
-	// Return nil if there are no errors.
-	return nil
-}
-
-// backPropagate performs the back propagation operation on the network.
-//
-// Parameters:
-// - tgtOut: A slice of floats representing the target output values.
-//
-// Returns:
-// - An error if the target output size is incorrect.
-func (n *Network) backPropagate(tgtOut []float64) error {
-	// Check if the target output size is correct.
-	if len(tgtOut) != int(n.topology[len(n.topology)-1]) {
-		return errors.New("output is incorrect size")
-	}
-
-	// Create a new matrix to hold the target output values.
-	errMtx := NewMatrix(uint32(len(tgtOut)), 1)
-
-	// Populate the target output values into the matrix.
-	errMtx.SetValues(tgtOut)
-
-	// Calculate the error matrix.
-	errMtx, err := errMtx.Add(n.valueMatrices[len(n.valueMatrices)-1].Negative())
-	if err != nil {
-		return fmt.Errorf("back propagation error: %v", err)
-	}
-
-	// Iterate through the layers from the last layer to the first layer.
-	for i := len(n.weightMatrices) - 1; i >= 0; i-- {
-		// Calculate the error at the current layer.
-		prevErrors, err := errMtx.Multiply(n.weightMatrices[i].Transpose())
-		if err != nil {
-			return fmt.Errorf("back propagation error: %v", err)
-		}
-
-		// Apply the derivative of the activation function to the output values of the current layer.
-		dOutputs := n.valueMatrices[i+1].ApplyFunction(n.solver.df)
-
-		// Calculate the gradients of the error with respect to the weights and biases.
-		gradients, err := errMtx.MultiplyElements(dOutputs)
-		if err != nil {
-			return fmt.Errorf("back propagation error: %v", err)
-		}
-		gradients = gradients.MultiplyScalar(n.learningRate)
-
-		// Calculate the weight gradients.
-		weightGradients, err := n.valueMatrices[i].Transpose().Multiply(gradients)
-		if err != nil {
-			return fmt.Errorf("back propagation error: %v", err)
-		}
-
-		// Update the weight matrices.
-		n.weightMatrices[i], err = n.weightMatrices[i].Add(weightGradients)
-		if err != nil {
-			return fmt.Errorf("back propagation error: %v", err)
-		}
-
-		// Update the bias matrices.
-		n.biasMatrices[i], err = n.biasMatrices[i].Add(gradients)
-		if err != nil {
-			return fmt.Errorf("back propagation error: %v", err)
-		}
-
-		// Update the error matrix for the next iteration.
-		errMtx = prevErrors
-	}
-
-	return nil
-}
-
-// getPrediction returns the values of the output layer of the network.
-//
-// This function does not take any parameters.
-// It returns a slice of floats representing the output values of the network.
-func (n *Network) getPrediction() []float64 {
-	// The output values of the network are stored in the last value matrix.
-	// We return the values of this matrix.
-	// The Values() function returns a slice of floats representing the values of the matrix.
-	return n.valueMatrices[len(n.valueMatrices)-1].Values()
-}
-
-// Train trains the network using the training data.
-//
-// This function takes a TrainingData object as a parameter and returns the average
-// error and an error object.
-//
-// The function iterates over the training data for the specified number of iterations.
-// During each iteration, it feeds the input data through the network and backpropagates
-// the error to update the network's weights and biases.
-// After each iteration, it checks if the network's error is within the specified tolerance.
-// If it is, the training process is terminated early.
-// The function returns the average error and a nil error object if the training is successful.
-func (n *Network) Train(td *TrainingData) (float64, error) {
-	// Initialize the training process and print debug information if debug mode is enabled
-	var start time.Time
-	if n.debug {
-		fmt.Println("initialising training...")
-		fmt.Printf("\t%v input neurons\n", n.topology[0])
-		fmt.Printf("\t%v hidden layers\n", len(n.topology)-2)
-		for i := 1; i < len(n.topology)-1; i++ {
-			fmt.Printf("\t\t%v neurons\n", n.topology[i])
-		}
-		fmt.Printf("\t%v output neurons\n", n.topology[len(n.topology)-1])
-		totalNeuronCount := 0
-		totalSynapsCount := 0
-		last := 0
-		for _, nc := range n.topology {
-			totalNeuronCount += int(nc)
-			totalSynapsCount += (int(nc) * last)
-			last = int(nc)
-		}
-		fmt.Printf("\t%v total neuron count\n", totalNeuronCount)
-		fmt.Printf("\t%v total synapse count\n", totalSynapsCount)
-		fmt.Println("\npreparing data")
-	}
-	td.prepare()
-	if n.debug {
-		fmt.Printf("\t%v rows of training data\n", td.TrainingCount())
-		fmt.Printf("\t%v rows of testing data\n", td.TestCount())
-	}
-	var errSum float64
-	if n.debug {
-		start = time.Now()
-		fmt.Printf("\ntraining commencing at %v\n", start)
-	}
-	iterCount := 0 // Keep track of the number of iterations
-	for i := 0; i < int(td.Iterations); i++ {
-
-		// Print a dot for each 1000 iterations and a new line for each 80,000 iterations
-		if n.debug {
-			iterCount++
-			if i%1000 == 0 {
-				if i > 0 && i%10000 == 0 {
-					fmt.Printf(" %v\n", i)
-				}
-				fmt.Print(".")
-			}
-		}
-		// Iterate over the training data and feed it through the network
-		for {
-			row := td.nextRow()
-			if row == nil {
-				break
-			}
-			if err := n.feedForward(row.Input); err != nil {
-				return 0, fmt.Errorf("training error: %v", err)
-			}
-			if err := n.backPropagate(row.Ouput); err != nil {
-				return 0, fmt.Errorf("training error: %v", err)
-			}
-		}
-		errSum = 0
-		errorWithinTolerence := true
-		testCount := 0
-		// Calculate the average error for the testing data
-		for _, errCheck := range td.TestData() {
-			testCount++
-			answer, err := n.Predict(errCheck.Input)
-			if err != nil {
-				return 0, fmt.Errorf("error testing error value: %v", err)
-			}
-			v := n.errorSolver.e(errCheck.Ouput, answer)
-			if v > td.TargetError {
-				errorWithinTolerence = false
-			}
-			errSum += v
-			// var v float64
-			// for i, a := range answer {
-			// 	v += math.Pow(errCheck.Ouput[i]-a, 2)
-			// }
-			// v /= float64(len(answer))
-			// // Check if the error is within the specified tolerance
-			// if math.Sqrt(v) > td.TargetError {
-			// 	errorWithinTolerence = false
-			// 	break
-			// }
-			// errSum += v
-		}
-		// errSum = math.Sqrt(errSum / float64(len(td.TestData())))
-		errSum /= float64(testCount)
-		// Check if the error is within the specified tolerance
-		if errorWithinTolerence && errSum <= td.TargetError {
-			if n.debug {
-				fmt.Print("\nterminating early. Within tolerance.")
-			}
-			break
-		}
-	}
-	// Print the training completion time and the number of iterations if debug mode is enabled
-	if n.debug {
-		stop := time.Now()
-		fmt.Printf("\ntraining complete at %v\n", stop)
-		fmt.Printf("training took %v minutes\n", stop.Sub(start).Minutes())
-		fmt.Printf("\t%v iterations run\n", iterCount)
-		fmt.Printf("\terror margin is %0.5f\n", errSum)
-	}
-	// Return the average error and a nil error object if the training is successful
-	return errSum, nil
-}
-
-// Predict uses the network to predict the output given an input.
-// It performs a feed-forward operation on the network and returns the predicted output.
-//
-// Parameters:
-// - input: A slice of floats representing the input values.
-//
-// Returns:
-// - A slice of floats representing the predicted output values.
-// - An error if there is an error during the prediction.
-func (n *Network) Predict(input []float64) ([]float64, error) {
-	// Perform a feed-forward operation on the network.
-	err := n.feedForward(input)
-	if err != nil {
-		// Return an error if there is an error during the feed-forward operation.
-		return nil, fmt.Errorf("prediction error: %v", err)
-	}
-	// Return the predicted output values.
-	return n.getPrediction(), nil
-}
-
-// SetDebug sets the debug mode of the network.
-//
-// The debug mode determines whether debug information is printed during the training process.
-//
-// Parameters:
-// - v: A boolean value indicating whether the debug mode is enabled (true) or disabled (false).
-func (n *Network) SetDebug(v bool) {
-	// Set the debug mode of the network to the specified value.
-	n.debug = v
-}
-
-// Debug returns the debug mode of the network.
-//
-// The debug mode determines whether debug information is printed during the training process.
-//
-// Returns:
-// - A boolean value indicating whether the debug mode is enabled (true) or disabled (false).
-func (n *Network) Debug() bool {
-	// Return the debug mode of the network.
-	return n.debug
-}
-
-// MarshalJSON marshals the Network object into a JSON byte slice.
-//
-// Parameters:
-// - None
-//
-// Returns:
-// - A JSON byte slice representing the Network object.
-// - An error if there is an error during the marshaling process.
-func (n *Network) MarshalJSON() ([]byte, error) {
-
-	res := struct {
-		Topology       []uint32  `json:"t"`
-		WeightMatrices []*Matrix `json:"w"`
-		BiasMatrices   []*Matrix `json:"b"`
-		LearningRate   float64   `json:"k"`
-		Activation     int       `json:"a"`
-		Output         int       `json:"o"`
-		ErrFunc        int       `json:"e"`
-		Debug          bool      `json:"d"`
-		SM             bool      `json:"s"`
-	}{
-		Topology:       n.topology,
-		WeightMatrices: n.weightMatrices,
-		BiasMatrices:   n.biasMatrices,
-		LearningRate:   n.learningRate,
-		Activation:     int(n.activation),
-		Output:         int(n.output),
-		ErrFunc:        int(n.errFunc),
-		Debug:          n.debug,
-		SM:             n.sm,
-	}
-
-	return json.Marshal(&res)
-}
-
-// UnmarshalJSON unmarshals the JSON byte slice into the Network object.
-//
-// Parameters:
-// - body (byte slice): The JSON byte slice to be unmarshaled.
-//
-// Returns:
-// - err (error): An error if there is an error during the unmarshaling process.
-func (n *Network) UnmarshalJSON(body []byte) (err error) {
-	data := struct {
-		Topology       []uint32  `json:"t"`
-		WeightMatrices []*Matrix `json:"w"`
-		BiasMatrices   []*Matrix `json:"b"`
-		LearningRate   float64   `json:"k"`
-		Activation     int       `json:"a"`
-		Output         int       `json:"o"`
-		ErrFunc        int       `json:"e"`
-		Debug          bool      `json:"d"`
-		SM             bool      `json:"s"`
-	}{}
-	if err := json.Unmarshal(body, &data); err != nil {
-		return err
-	}
-	n.topology = data.Topology
-	n.weightMatrices = data.WeightMatrices
-	n.biasMatrices = data.BiasMatrices
-	n.learningRate = data.LearningRate
-	n.activation = ActivationFunction(data.Activation)
-	n.output = ActivationFunction(data.Output)
-	n.errFunc = ErrorFunction(data.ErrFunc)
-	n.debug = data.Debug
-	n.sm = data.SM
-	n.solver = getActivationFunctions(n.activation)
-	n.outputSolver = getActivationFunctions(n.output)
-	n.errorSolver = getErrorFunction(n.errFunc)
-	n.valueMatrices = make([]*Matrix, len(n.topology))
-	return nil
-}
+package jasper
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
+	"time"
+)
+
+// Network represents a neural network.
+type Network struct {
+	// topology is the configuration of the network, a slice of uint32 that
+	// represents the number of neurons in each layer.
+	topology []uint32
+
+	// model holds the layers of the network.
+	model *Sequential
+
+	// prediction holds the output values of the most recent call to
+	// feedForward, after soft max has been applied.
+	prediction *Matrix
+
+	// learningRate is a float64 that represents the learning rate of the network.
+	learningRate float64
+
+	// schedule is the learning rate schedule, or nil for a constant learning rate.
+	schedule LearningRateSchedule
+
+	// rate is the learning rate used for the current training iteration.
+	rate float64
+
+	// optimizer is the optimizer used to update the weights and biases.
+	optimizer Optimizer
+
+	// errFunc is the error function used in the network.
+	errFunc ErrorFunction
+
+	// errorSolver is the solver for the error function.
+	errorSolver errorSolver
+
+	// debug is a boolean that indicates if the network is in debug mode.
+	debug bool
+
+	// logger receives the debug output. If nil, the default logger is used.
+	logger *slog.Logger
+
+	// sm is a boolean that indicates if the network should use soft max.
+	sm bool
+
+	// random is the random number generator of the network.
+	random *random
+
+	// inputSteps holds the preprocessors applied to the inputs.
+	inputSteps pipeline
+
+	// mode is the mode of the network's layers.
+	mode Mode
+
+	// regularization is the regularization of the parameters of layers that
+	// do not have their own, or nil for none.
+	regularization *Regularization
+
+	// clipValue, if greater than zero, is the largest size of each value of
+	// the gradients.
+	clipValue float64
+
+	// clipNorm, if greater than zero, is the largest combined length of the
+	// gradients.
+	clipNorm float64
+
+	// outputSteps holds the preprocessors applied to the target outputs, which
+	// are reversed for the predicted outputs.
+	outputSteps pipeline
+}
+
+// softMax calculates the softmax function on a given Matrix.
+//
+// The softmax function is used to normalize a set of values into a probability distribution.
+// It takes a Matrix as input and returns a new Matrix with the same dimensions, where each
+// row of the input has been normalized separately.
+//
+// Parameters:
+// - vs: A pointer to a Matrix representing the input values.
+//
+// Returns:
+// - A pointer to a new Matrix with the same dimensions as the input Matrix, containing the softmax values.
+func softMax(vs *Matrix) *Matrix {
+	// Create an output matrix to hold the result of applying the softmax function.
+	output := NewMatrix(vs.cols, vs.rows)
+
+	// Normalize each row of the input matrix.
+	for r := 0; r < int(vs.rows); r++ {
+		in := vs.values[r*int(vs.cols) : (r+1)*int(vs.cols)]
+		out := output.values[r*int(vs.cols) : (r+1)*int(vs.cols)]
+
+		// Find the largest input value. Subtracting it before taking the exponentials
+		// keeps them from overflowing without changing the result.
+		largest := math.Inf(-1)
+		for _, v := range in {
+			largest = math.Max(largest, v)
+		}
+
+		// Calculate the exponential of each value and the total sum of the
+		// exponentials, which is used to normalize the output values.
+		var total float64
+		for i, v := range in {
+			out[i] = math.Exp(v - largest)
+			total += out[i]
+		}
+
+		// Divide each value by the total sum.
+		// This normalizes the output values to be between 0 and 1.
+		for i := range out {
+			out[i] /= total
+		}
+	}
+
+	// Return the output matrix.
+	return output
+}
+
+// softMaxBackward calculates the gradient with respect to the inputs of the
+// softmax function, given the gradient with respect to its outputs.
+//
+// Parameters:
+// - ys: A slice of floats containing the outputs of the softmax function.
+// - gradient: A slice of floats containing the gradient with respect to each output.
+//
+// Returns:
+// - A slice of floats containing the gradient with respect to each input.
+func softMaxBackward(ys, gradient []float64) []float64 {
+	// The softmax Jacobian is y_i(δij - y_j), so each input receives
+	// y_j(g_j - Σ g_i y_i).
+	var dot float64
+	for i, y := range ys {
+		dot += gradient[i] * y
+	}
+	res := make([]float64, len(ys))
+	for j, y := range ys {
+		res[j] = y * (gradient[j] - dot)
+	}
+	return res
+}
+
+// New creates a new instance of the Network struct.
+//
+// Parameters:
+// - c: A pointer to the NetworkConfiguration struct that contains the configuration settings for the network.
+//
+// Returns:
+// - A pointer to the newly created Network struct and an error if any.
+func New(c *NetworkConfiguration) (*Network, error) {
+	// Create a new instance of the Network struct using the configuration settings.
+	s := Network{
+		learningRate:   c.LearningRate, // Set the learning rate of the network.
+		rate:           c.LearningRate,
+		errFunc:        c.Error,
+		errorSolver:    getErrorFunction(c.Error),
+		debug:          !c.Quiet, // Set the debug mode of the network.
+		logger:         c.Logger,
+		sm:             c.SoftMax,
+		optimizer:      c.Optimizer,
+		random:         newRandom(c.Seed, c.Source),
+		regularization: c.Regularization,
+		clipValue:      c.ClipValue,
+		clipNorm:       c.ClipNorm,
+	}
+	if err := c.Regularization.validate(); err != nil {
+		return nil, err
+	}
+	if c.ClipValue < 0 || c.ClipNorm < 0 {
+		return nil, errors.New("gradient clipping is negative")
+	}
+
+	// Use a copy of the configured schedule, so its state is not shared by
+	// every network created from the configuration.
+	var err error
+	if s.schedule, err = copySchedule(c.Schedule); err != nil {
+		return nil, fmt.Errorf("schedule error: %v", err)
+	}
+
+	// Use plain gradient descent if no optimizer has been configured.
+	if s.optimizer == nil {
+		s.optimizer = NewSGD(0, false)
+	}
+	if len(c.Topology) == 0 {
+		return nil, errors.New("topology has no input layer")
+	}
+
+	if c.Model != nil {
+		if len(c.LayerRegularization) > 0 || len(c.Dropout) > 0 || c.Normalization != NoNormalization {
+			return nil, errors.New("model error: layer regularization, dropout and normalization cannot be configured with a model")
+		}
+
+		// Use a copy of the configured model, so the configuration can be reused.
+		if s.model, err = c.Model.copy(); err != nil {
+			return nil, fmt.Errorf("model error: %v", err)
+		}
+	} else {
+		// Create a dense layer for each layer of the topology after the inputs.
+		if len(c.Topology) < 2 {
+			return nil, errors.New("topology has no output layer")
+		}
+		activations, err := c.layerActivations()
+		if err != nil {
+			return nil, err
+		}
+		if len(c.LayerRegularization) > len(activations) {
+			return nil, fmt.Errorf("%v layer regularizations for %v layers", len(c.LayerRegularization), len(activations))
+		}
+		if len(c.Dropout) > len(activations)-1 {
+			return nil, fmt.Errorf("%v dropout rates for %v hidden layers", len(c.Dropout), len(activations)-1)
+		}
+		s.model = NewSequential()
+		for i, a := range activations {
+			d := NewDense(c.Topology[i+1], a)
+			d.SetInitializers(c.Initializer, c.BiasInitializer, c.InitialValue)
+			if i < len(c.LayerRegularization) {
+				d.SetRegularization(c.LayerRegularization[i])
+			}
+			s.model.Add(d)
+			if i == len(activations)-1 {
+				continue
+			}
+
+			// Follow a hidden layer with a normalization layer if one is selected.
+			norm, err := c.Normalization.layer()
+			if err != nil {
+				return nil, err
+			}
+			if norm != nil {
+				s.model.Add(norm)
+			}
+
+			// Follow a hidden layer with a dropout layer if it has a dropout rate.
+			if i < len(c.Dropout) && c.Dropout[i] != 0 {
+				if c.AlphaDropout {
+					s.model.Add(NewAlphaDropout(c.Dropout[i]))
+				} else {
+					s.model.Add(NewDropout(c.Dropout[i]))
+				}
+			}
+		}
+	}
+
+	// Use copies of the configured preprocessors, so the configuration can be reused.
+	if s.inputSteps, err = copyPreprocessors(c.InputPreprocessing); err != nil {
+		return nil, fmt.Errorf("preprocessing error: %v", err)
+	}
+	if s.outputSteps, err = copyPreprocessors(c.OutputPreprocessing); err != nil {
+		return nil, fmt.Errorf("preprocessing error: %v", err)
+	}
+
+	// Build the layers for the number of inputs, using the network's random numbers.
+	s.model.setRandom(s.random.rng)
+	if _, err := s.model.Build(c.Topology[0]); err != nil {
+		return nil, fmt.Errorf("model error: %v", err)
+	}
+	s.topology = s.model.topology()
+
+	// Return the newly created Network struct.
+	return &s, nil
+}
+
+// feedForward performs a feed-forward operation on the network.
+//
+// Each row of the input matrix is fed through the network at the same time,
+// producing the matching row of the output matrix.
+//
+// Parameters:
+// - inputs: A matrix holding one row of input values for each sample.
+//
+// Returns:
+// - An error if the input size is incorrect.
+func (n *Network) feedForward(inputs *Matrix) error {
+	// Feed the inputs through each layer of the model.
+	values, err := n.model.Forward(inputs)
+	if err != nil {
+		return err
+	}
+
+	// Set the output values of the network to the final layer's values.
+	if n.sm {
+		n.prediction = softMax(values)
+	} else {
+		n.prediction = values
+	}
+	// Return nil if there are no errors.
+	return nil
+}
+
+// backPropagate performs the back propagation operation on the network.
+//
+// The gradients are calculated by computeGradients, and the parameters of
+// every layer are then updated once by the optimizer.
+//
+// Parameters:
+// - targets: A matrix holding one row of target output values for each sample.
+// - weights: The weight of each sample, or nil if every sample has a weight of one.
+//
+// Returns:
+// - An error if the target output size is incorrect, or a DivergenceError if
+// a gradient or updated parameter is not finite.
+func (n *Network) backPropagate(targets *Matrix, weights []float64) error {
+	params, err := n.computeGradients(targets, weights)
+	if err != nil {
+		return err
+	}
+	if err := n.checkParameters(true); err != nil {
+		return err
+	}
+	n.clipGradients(params)
+
+	// Update the parameters of each layer using the optimizer, applying any
+	// constraint afterwards.
+	for _, p := range params {
+		n.optimizer.Update(p.Value, p.Gradient, p.State, n.rate)
+		n.regularizationOf(p).constrain(p)
+	}
+	return n.checkParameters(false)
+}
+
+// computeGradients calculates the gradient of the error with respect to every
+// parameter of the network, storing it in the parameter.
+//
+// The gradient of the configured error function is propagated from the output
+// layer back through each layer of the model. When soft max is enabled the
+// gradient is passed through the softmax function, using the combined softmax
+// and categorical cross entropy gradient where possible.
+//
+// The gradients are averaged over the rows of the batch fed forward by the
+// previous call to feedForward, with the gradient of each row scaled by its
+// weight, and the gradient of any penalty is added.
+//
+// Parameters:
+// - targets: A matrix holding one row of target output values for each sample.
+// - weights: The weight of each sample, or nil if every sample has a weight of one.
+//
+// Returns:
+// - The parameters of the network.
+// - An error if the target output size is incorrect.
+func (n *Network) computeGradients(targets *Matrix, weights []float64) ([]*Parameter, error) {
+	// Check if the target output size is correct.
+	output := n.prediction
+	if targets.cols != output.cols || targets.rows != output.rows {
+		return nil, errors.New("output is incorrect size")
+	}
+
+	// Calculate the gradient of the error with respect to the output values of
+	// each row, weighted and averaged over the batch.
+	errMtx := NewMatrix(targets.cols, targets.rows)
+	size := int(targets.cols)
+	for r := 0; r < int(targets.rows); r++ {
+		scale := 1 / float64(targets.rows)
+		if weights != nil {
+			scale *= weights[r]
+		}
+		ys := output.values[r*size : (r+1)*size]
+		ts := targets.values[r*size : (r+1)*size]
+		var gradient []float64
+		if n.sm {
+			if s, ok := n.errorSolver.(softMaxSolver); ok {
+				gradient = s.softMaxGradient(ys, ts)
+			} else {
+				gradient = softMaxBackward(ys, n.errorSolver.g(ys, ts))
+			}
+		} else {
+			gradient = n.errorSolver.g(ys, ts)
+		}
+		for i, g := range gradient {
+			errMtx.values[r*size+i] = g * scale
+		}
+	}
+
+	// Propagate the error back through the layers, from the last layer to the first.
+	if _, err := n.model.Backward(errMtx); err != nil {
+		return nil, err
+	}
+
+	// Add the gradient of any penalty.
+	params := n.model.Parameters()
+	for _, p := range params {
+		n.regularizationOf(p).addGradient(p)
+	}
+	return params, nil
+}
+
+// getPrediction returns the values of the output layer of the network.
+//
+// This function does not take any parameters.
+// It returns a slice of floats representing the output values of the network.
+func (n *Network) getPrediction() []float64 {
+	// The output values of the network are stored in the prediction matrix.
+	// We return the values of this matrix.
+	// The Values() function returns a slice of floats representing the values of the matrix.
+	return n.prediction.Values()
+}
+
+// Train trains the network using the training data.
+//
+// This function takes a TrainingData object and any number of callbacks as
+// parameters, and returns the history of the training and an error object.
+//
+// The function iterates over the training data for the specified number of iterations.
+// The rows are read from the training data's TrainingSource if it has one, so
+// data too large to hold in memory can be streamed through the network.
+// During each iteration, it feeds the input data through the network and backpropagates
+// the error to update the network's weights and biases.
+// After each iteration, it checks if the network's error is within the specified tolerance.
+// If it is, the training process is terminated early.
+// The callbacks are called as training progresses and may stop the training early.
+// If debug mode is enabled, the progress is written to the network's logger.
+// The function returns the training history and a nil error object if the training is
+// successful. If an error occurs, the history of the iterations completed so far is
+// returned with the error.
+func (n *Network) Train(td *TrainingData, callbacks ...Callback) (*TrainingHistory, error) {
+	// Initialize the training process and log debug information if debug mode is enabled
+	logger := n.logger
+	if logger == nil {
+		logger = slog.Default()
+	}
+	if n.debug {
+		n.logTopology(logger)
+		callbacks = append([]Callback{LogProgress(logger, 1000)}, callbacks...)
+	}
+	// Continue from a checkpoint if the training data has been resumed,
+	// otherwise split the data afresh
+	h := &TrainingHistory{StopReason: Completed, BestEpoch: -1}
+
+	// Without a validation source the training error of each iteration stands
+	// in for the validation error, which cannot be monitored for improvement
+	if td.TrainingSource != nil && td.ValidationSource == nil {
+		if es := td.EarlyStopping; es != nil && es.Value == nil && es.Monitor != MonitorTrainLoss {
+			return h, errors.New("training error: early stopping monitors the validation data, which needs a validation source")
+		}
+		if observesLoss(n.schedule) {
+			return h, errors.New("training error: the learning rate schedule observes the validation error, which needs a validation source")
+		}
+		logger.Warn("no validation source, the training error is used as the validation error")
+	}
+	// Batch normalization needs the mean and variance of more than one row
+	if max(td.BatchSize, 1) < 2 && n.model.batchNormalized() {
+		return h, errors.New("training error: batch normalization needs a BatchSize of at least 2")
+	}
+	resume := td.resume
+	td.resume = nil
+	if resume == nil {
+		if err := td.prepare(n.random.rng); err != nil {
+			return h, fmt.Errorf("training error: %v", err)
+		}
+	}
+	if n.debug {
+		logger.Info("training data prepared",
+			"training_rows", td.TrainingCount(),
+			"validation_rows", td.ValidationCount(),
+			"test_rows", len(td.testData))
+	}
+
+	start := time.Now()
+
+	// Leave the network in inference mode however training finishes
+	defer n.SetMode(Inference)
+
+	// Fit the preprocessors from the training rows the first time the network is
+	// trained, and weigh the classes, sampling the rows from the start of a
+	// training source, which begin the first iteration's pass over it
+	fitRows := td.trainingData
+	balance := td.BalanceClasses && td.ClassWeights == nil
+	if td.TrainingSource != nil && (balance || !(n.inputSteps.fitted() && n.outputSteps.fitted())) {
+		rows, err := td.sourceRows(sourceFitRows)
+		if err != nil {
+			return h, fmt.Errorf("training error: %v", err)
+		}
+		fitRows = rows
+	}
+	if err := n.fitPreprocessing(fitRows); err != nil {
+		return h, fmt.Errorf("training error: %v", err)
+	}
+	td.weighClasses(fitRows)
+	if n.debug && td.classWeights != nil {
+		logger.Info("class weights", "weights", td.classWeights)
+	}
+	es := td.EarlyStopping
+	if es != nil {
+		es.reset()
+	}
+	if c := td.Checkpoint; c != nil {
+		c.started = false
+	}
+	first := 0
+	if resume != nil {
+		first = resume.Epoch
+		h.Epochs = append(h.Epochs, resume.History...)
+		resume.restore(td)
+		if n.debug {
+			logger.Info("resuming training", "iteration", first)
+		}
+	}
+
+	// stop records the reason training stopped if a callback returns ErrStopTraining,
+	// otherwise it returns the wrapped callback error.
+	stop := func(err error) error {
+		if errors.Is(err, ErrStopTraining) {
+			h.StopReason = StoppedByCallback
+			return nil
+		}
+		return fmt.Errorf("training error: %w", err)
+	}
+
+training:
+	for i := first; i < int(td.Iterations); i++ {
+		epochStart := time.Now()
+
+		// Set the learning rate for the iteration from the schedule
+		n.rate = n.learningRate
+		if n.schedule != nil {
+			n.rate = n.schedule.Rate(i, n.learningRate)
+		}
+
+		if err := notify(callbacks, func(c Callback) error {
+			if c.OnEpochStart == nil {
+				return nil
+			}
+			return c.OnEpochStart(n, i)
+		}); err != nil {
+			if err = stop(err); err != nil {
+				return h, err
+			}
+			break training
+		}
+
+		// Reshuffle the training rows if required
+		if td.Shuffle {
+			td.shuffle(n.random.rng)
+		}
+
+		// Start a new pass over a training source, stopping if it has no more data
+		if err := td.rewind(); err != nil {
+			if errors.Is(err, ErrSourceExhausted) {
+				h.StopReason = SourceExhausted
+				break
+			}
+			return h, fmt.Errorf("training error: %v", err)
+		}
+
+		// Iterate over the training data, feeding each batch through the network
+		// in training mode
+		n.SetMode(Training)
+		var trainSum float64
+		trainCount := 0
+		for batchIndex := 0; ; batchIndex++ {
+			batch, err := td.nextBatch()
+			if err != nil {
+				return h, fmt.Errorf("training error: %v", err)
+			}
+			if len(batch) == 0 {
+				break
+			}
+			first := trainCount
+			weights := td.weights(batch)
+			batch, err = n.preprocess(batch)
+			if err != nil {
+				return h, fmt.Errorf("training error: %v", err)
+			}
+			inputs, targets, err := rowMatrices(batch)
+			if err != nil {
+				return h, fmt.Errorf("training error: %v", err)
+			}
+			if err := n.feedForward(inputs); err != nil {
+				return h, fmt.Errorf("training error: %v", err)
+			}
+			if err := n.checkForward(targets); err != nil {
+				return h, trainingError(err, i, batchIndex, first)
+			}
+			batchLoss := n.batchLoss(targets, weights) + n.penalty()
+			trainSum += batchLoss * float64(len(batch))
+			trainCount += len(batch)
+			if err := n.backPropagate(targets, weights); err != nil {
+				return h, trainingError(err, i, batchIndex, first)
+			}
+
+			if err := notify(callbacks, func(c Callback) error {
+				if c.OnBatchEnd == nil {
+					return nil
+				}
+				return c.OnBatchEnd(n, i, batchIndex, batchLoss)
+			}); err != nil {
+				if err = stop(err); err != nil {
+					return h, err
+				}
+				break training
+			}
+		}
+
+		n.SetMode(Inference)
+
+		// Stop if a training source ended before providing any rows
+		if trainCount == 0 && td.TrainingSource != nil {
+			h.StopReason = SourceExhausted
+			break
+		}
+
+		// Calculate the average error for the validation data
+		errSum, errorWithinTolerence, err := n.validate(td, trainSum/float64(trainCount))
+		if err != nil {
+			return h, fmt.Errorf("error validating error value: %v", err)
+		}
+		if td.TrainingSource == nil || td.ValidationSource != nil {
+			errSum += n.penalty()
+		}
+
+		// Let the learning rate schedule react to the validation error
+		if o, ok := n.schedule.(LossObserver); ok {
+			o.Observe(i, errSum)
+		}
+
+		// Record the results of the iteration
+		rec := EpochRecord{
+			Epoch:          i,
+			TrainLoss:      trainSum / float64(trainCount),
+			ValidationLoss: errSum,
+			LearningRate:   n.rate,
+			Duration:       time.Since(epochStart),
+		}
+		h.Epochs = append(h.Epochs, rec)
+
+		if err := notify(callbacks, func(c Callback) error {
+			if c.OnEpochEnd == nil {
+				return nil
+			}
+			return c.OnEpochEnd(n, rec)
+		}); err != nil {
+			if err = stop(err); err != nil {
+				return h, err
+			}
+			break training
+		}
+
+		// Update the early stopping policy and write any checkpoints that are due
+		exhausted := false
+		if es != nil {
+			var err error
+			if exhausted, err = es.update(n, td, rec); err != nil {
+				return h, fmt.Errorf("training error: %v", err)
+			}
+		}
+		if c := td.Checkpoint; c != nil {
+			if err := c.update(n, td, h); err != nil {
+				return h, fmt.Errorf("training error: %v", err)
+			}
+		}
+
+		// Check if the error is within the specified tolerance
+		if errorWithinTolerence && errSum <= td.TargetError {
+			h.StopReason = WithinTolerance
+			break
+		}
+
+		// Check if the early stopping policy has run out of patience
+		if exhausted {
+			h.StopReason = EarlyStopped
+			break
+		}
+	}
+	h.Duration = time.Since(start)
+
+	// Restore the weights and biases from the best iteration if required
+	if es != nil {
+		h.BestEpoch = es.bestEpoch
+		if es.RestoreBest && es.weights != nil {
+			n.restore(es.weights)
+			h.Restored = true
+		}
+	}
+
+	// Let the callbacks know that training has finished
+	if err := notify(callbacks, func(c Callback) error {
+		if c.OnTrainEnd == nil {
+			return nil
+		}
+		return c.OnTrainEnd(n, h)
+	}); err != nil {
+		if err = stop(err); err != nil {
+			return h, err
+		}
+	}
+
+	// Return the training history and a nil error object if the training is successful
+	return h, nil
+}
+
+// validate calculates the average weighted error of the validation rows, read
+// from the training data's ValidationSource if it has one.
+//
+// Parameters:
+// - td: The training data.
+// - trainLoss: The training error of the iteration, used in place of the
+// validation error when training from a source without a validation source.
+//
+// Returns:
+// - The average weighted error of the validation rows.
+// - True if the weighted error of every validation row is within the target error.
+// - An error if there are no validation rows, or a row cannot be read or predicted.
+func (n *Network) validate(td *TrainingData, trainLoss float64) (float64, bool, error) {
+	if td.TrainingSource != nil && td.ValidationSource == nil {
+		return trainLoss, trainLoss <= td.TargetError, nil
+	}
+	var sum float64
+	count := 0
+	within := true
+	add := func(row *DataRow) error {
+		v, err := n.rowError(row)
+		if err != nil {
+			return err
+		}
+		v *= td.weight(row)
+		if v > td.TargetError {
+			within = false
+		}
+		sum += v
+		count++
+		return nil
+	}
+	if src := td.ValidationSource; src != nil {
+		if err := src.Reset(); err != nil {
+			return 0, false, err
+		}
+		for {
+			row, err := src.Next()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return 0, false, err
+			}
+			if err := add(row); err != nil {
+				return 0, false, err
+			}
+		}
+	} else {
+		for _, row := range td.ValidationData() {
+			if err := add(row); err != nil {
+				return 0, false, err
+			}
+		}
+	}
+	if count == 0 {
+		return 0, false, errors.New("no validation rows")
+	}
+	return sum / float64(count), within, nil
+}
+
+// TestLoss scores the network against the test rows of the training data,
+// which are held out from training and validation by its TestSplit.
+//
+// The test rows are only available once the training data has been split by
+// Train. They are scored once, after training is finished, to give an
+// unbiased measure of the network, so a second call with the same training
+// data returns an error rather than letting the test rows guide the choice of
+// network. If TestSplit is zero, the validation rows returned by TestData are
+// scored instead, and may be scored any number of times. Evaluate and
+// EvaluateRegression give fuller reports for the rows of TestData, and do not
+// count as scoring them.
+//
+// Parameters:
+// - td: The training data the network was trained with.
+//
+// Returns:
+// - The average error of the test rows.
+// - An error if there are no test rows, the test rows have already been
+// scored, or a row cannot be predicted.
+func (n *Network) TestLoss(td *TrainingData) (float64, error) {
+	rows := td.TestData()
+	if len(rows) == 0 {
+		return 0, errors.New("evaluation error: no test data")
+	}
+	if td.tested {
+		return 0, errors.New("evaluation error: test data has already been scored")
+	}
+	loss, err := n.averageError(rows)
+	if err != nil {
+		return 0, fmt.Errorf("evaluation error: %v", err)
+	}
+	td.tested = td.TestSplit > 0
+	return loss, nil
+}
+
+// averageError calculates the average error of the network's predictions for
+// a set of rows.
+//
+// Parameters:
+// - rows: The rows to predict.
+//
+// Returns:
+// - The average error of the rows.
+// - An error if a row cannot be predicted.
+func (n *Network) averageError(rows []*DataRow) (float64, error) {
+	var sum float64
+	for _, row := range rows {
+		v, err := n.rowError(row)
+		if err != nil {
+			return 0, err
+		}
+		sum += v
+	}
+	return sum / float64(len(rows)), nil
+}
+
+// rowError calculates the error of the network's prediction for a row. The
+// error is calculated after the row has been preprocessed, as it is when
+// training.
+//
+// Parameters:
+// - row: The row to predict.
+//
+// Returns:
+// - The error of the prediction.
+// - An error if the row cannot be preprocessed or predicted.
+func (n *Network) rowError(row *DataRow) (float64, error) {
+	rows, err := n.preprocess([]*DataRow{row})
+	if err != nil {
+		return 0, err
+	}
+	if err := n.feedForward(NewMatrixFromSlice(rows[0].Input)); err != nil {
+		return 0, err
+	}
+	return n.errorSolver.e(n.getPrediction(), rows[0].Ouput), nil
+}
+
+// fitPreprocessing fits any preprocessors that have not been fitted, from the
+// training rows.
+//
+// Parameters:
+// - rows: The training rows.
+//
+// Returns:
+// - An error if a preprocessor cannot be fitted.
+func (n *Network) fitPreprocessing(rows []*DataRow) error {
+	if n.inputSteps.fitted() && n.outputSteps.fitted() {
+		return nil
+	}
+	inputs := make([][]float64, len(rows))
+	outputs := make([][]float64, len(rows))
+	for i, row := range rows {
+		inputs[i] = row.Input
+		outputs[i] = row.Ouput
+	}
+	if err := n.inputSteps.fit(inputs); err != nil {
+		return fmt.Errorf("preprocessing error: inputs: %v", err)
+	}
+	if err := n.outputSteps.fit(outputs); err != nil {
+		return fmt.Errorf("preprocessing error: outputs: %v", err)
+	}
+	return nil
+}
+
+// preprocess applies the preprocessors to the inputs and target outputs of
+// a set of rows.
+//
+// Parameters:
+// - rows: The rows to preprocess.
+//
+// Returns:
+// - The preprocessed rows, or rows itself if there are no preprocessors.
+// - An error if a row cannot be preprocessed.
+func (n *Network) preprocess(rows []*DataRow) ([]*DataRow, error) {
+	if len(n.inputSteps) == 0 && len(n.outputSteps) == 0 {
+		return rows, nil
+	}
+	res := make([]*DataRow, len(rows))
+	for i, row := range rows {
+		input, err := n.inputSteps.transform(row.Input)
+		if err != nil {
+			return nil, fmt.Errorf("preprocessing error: inputs: %v", err)
+		}
+		output, err := n.outputSteps.transform(row.Ouput)
+		if err != nil {
+			return nil, fmt.Errorf("preprocessing error: outputs: %v", err)
+		}
+		res[i] = &DataRow{Input: input, Ouput: output}
+	}
+	return res, nil
+}
+
+// notify calls f for each of the callbacks in turn, stopping at the first error.
+//
+// Parameters:
+// - callbacks: The callbacks to notify.
+// - f: A function that calls the relevant function of a callback.
+//
+// Returns:
+// - The first error returned by f.
+func notify(callbacks []Callback, f func(c Callback) error) error {
+	for _, c := range callbacks {
+		if err := f(c); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// batchLoss calculates the average weighted error of the rows fed forward by
+// the previous call to feedForward.
+//
+// Parameters:
+// - targets: A matrix holding one row of target output values for each sample.
+// - weights: The weight of each sample, or nil if every sample has a weight of one.
+//
+// Returns:
+// - The average weighted error of the rows.
+func (n *Network) batchLoss(targets *Matrix, weights []float64) float64 {
+	output := n.prediction
+	size := int(targets.cols)
+	var sum float64
+	for r := 0; r < int(targets.rows); r++ {
+		e := n.errorSolver.e(output.values[r*size:(r+1)*size], targets.values[r*size:(r+1)*size])
+		if weights != nil {
+			e *= weights[r]
+		}
+		sum += e
+	}
+	return sum / float64(targets.rows)
+}
+
+// logTopology logs the shape of the network and its training settings.
+//
+// Parameters:
+// - logger: The logger to write to.
+func (n *Network) logTopology(logger *slog.Logger) {
+	totalNeuronCount := 0
+	totalSynapsCount := 0
+	last := 0
+	for _, nc := range n.topology {
+		totalNeuronCount += int(nc)
+		totalSynapsCount += (int(nc) * last)
+		last = int(nc)
+	}
+	args := []any{
+		"input_neurons", n.topology[0],
+		"hidden_layers", len(n.topology) - 2,
+		"hidden_neurons", n.topology[1 : len(n.topology)-1],
+		"output_neurons", n.topology[len(n.topology)-1],
+		"total_neurons", totalNeuronCount,
+		"total_synapses", totalSynapsCount,
+		"learning_rate", n.learningRate,
+	}
+	if n.schedule != nil {
+		args = append(args, "schedule", fmt.Sprint(n.schedule))
+	}
+	if n.random.seed != 0 {
+		args = append(args, "seed", n.random.seed)
+	}
+	logger.Info("initialising training", args...)
+}
+
+// Predict uses the network to predict the output given an input.
+// It performs a feed-forward operation on the network and returns the predicted output.
+//
+// The input preprocessors are applied to the input, and the output
+// preprocessors are reversed for the predicted output, so both are in the
+// same units as the training data.
+//
+// The prediction is made in the network's mode, which is Inference unless it
+// has been changed with SetMode, so dropout layers pass their inputs through.
+//
+// Parameters:
+// - input: A slice of floats representing the input values.
+//
+// Returns:
+// - A slice of floats representing the predicted output values.
+// - An error if there is an error during the prediction.
+func (n *Network) Predict(input []float64) ([]float64, error) {
+	// Preprocess the input values.
+	input, err := n.inputSteps.transform(input)
+	if err != nil {
+		return nil, fmt.Errorf("prediction error: %v", err)
+	}
+
+	// Perform a feed-forward operation on the network.
+	err = n.feedForward(NewMatrixFromSlice(input))
+	if err != nil {
+		// Return an error if there is an error during the feed-forward operation.
+		return nil, fmt.Errorf("prediction error: %v", err)
+	}
+
+	// Return the predicted output values, reversing the output preprocessing.
+	output, err := n.outputSteps.inverse(n.getPrediction())
+	if err != nil {
+		return nil, fmt.Errorf("prediction error: %v", err)
+	}
+	return output, nil
+}
+
+// SetMode sets the mode of the network and of its layers that behave
+// differently while training.
+//
+// Train puts the network into Training mode while it updates the weights and
+// biases, and back into Inference mode when it validates and when it
+// returns. Setting Training mode outside of training keeps stochastic layers
+// active in Predict, such as to estimate the uncertainty of a prediction from
+// the spread of repeated predictions.
+//
+// Parameters:
+// - m: The mode.
+func (n *Network) SetMode(m Mode) {
+	n.mode = m
+	n.model.SetMode(m)
+}
+
+// Mode returns the mode of the network.
+//
+// Returns:
+// - The mode.
+func (n *Network) Mode() Mode {
+	return n.mode
+}
+
+// SetDebug sets the debug mode of the network.
+//
+// The debug mode determines whether debug information is logged during the training process.
+//
+// Parameters:
+// - v: A boolean value indicating whether the debug mode is enabled (true) or disabled (false).
+func (n *Network) SetDebug(v bool) {
+	// Set the debug mode of the network to the specified value.
+	n.debug = v
+}
+
+// SetLogger sets the logger that receives the debug output of the network.
+//
+// Parameters:
+// - l: The logger to write to, or nil to use the default logger.
+func (n *Network) SetLogger(l *slog.Logger) {
+	n.logger = l
+}
+
+// Debug returns the debug mode of the network.
+//
+// The debug mode determines whether debug information is logged during the training process.
+//
+// Returns:
+// - A boolean value indicating whether the debug mode is enabled (true) or disabled (false).
+func (n *Network) Debug() bool {
+	// Return the debug mode of the network.
+	return n.debug
+}
+
+// LearningRate returns the learning rate used for the current, or most recent,
+// training iteration.
+//
+// Returns:
+// - The learning rate after the schedule has been applied.
+func (n *Network) LearningRate() float64 {
+	return n.rate
+}
+
+// Schedule returns the learning rate schedule of the network.
+//
+// Returns:
+// - The learning rate schedule, or nil if the learning rate is constant.
+func (n *Network) Schedule() LearningRateSchedule {
+	return n.schedule
+}
+
+// MarshalJSON marshals the Network object into a JSON byte slice.
+//
+// Parameters:
+// - None
+//
+// Returns:
+// - A JSON byte slice representing the Network object.
+// - An error if there is an error during the marshaling process.
+func (n *Network) MarshalJSON() ([]byte, error) {
+	opt, err := marshalOptimizer(n.optimizer)
+	if err != nil {
+		return nil, err
+	}
+	sched, err := marshalSchedule(n.schedule)
+	if err != nil {
+		return nil, err
+	}
+	state, err := n.random.state()
+	if err != nil {
+		return nil, err
+	}
+	var inputSteps, outputSteps json.RawMessage
+	if len(n.inputSteps) > 0 {
+		if inputSteps, err = marshalPreprocessors(n.inputSteps); err != nil {
+			return nil, err
+		}
+	}
+	if len(n.outputSteps) > 0 {
+		if outputSteps, err = marshalPreprocessors(n.outputSteps); err != nil {
+			return nil, err
+		}
+	}
+
+	res := networkJSON{
+		Topology:       n.topology,
+		Model:          n.model,
+		LearningRate:   n.learningRate,
+		ErrFunc:        int(n.errFunc),
+		Debug:          n.debug,
+		SM:             n.sm,
+		Optimizer:      opt,
+		Schedule:       sched,
+		Seed:           n.random.seed,
+		RandomState:    state,
+		InputSteps:     inputSteps,
+		OutputSteps:    outputSteps,
+		Regularization: n.regularization,
+		ClipValue:      n.clipValue,
+		ClipNorm:       n.clipNorm,
+	}
+
+	return json.Marshal(&res)
+}
+
+// UnmarshalJSON unmarshals the JSON byte slice into the Network object.
+//
+// Parameters:
+// - body (byte slice): The JSON byte slice to be unmarshaled.
+//
+// Returns:
+// - err (error): An error if there is an error during the unmarshaling process.
+func (n *Network) UnmarshalJSON(body []byte) (err error) {
+	data := networkJSON{}
+	if err := json.Unmarshal(body, &data); err != nil {
+		return err
+	}
+	n.optimizer = NewSGD(0, false)
+	if len(data.Optimizer) > 0 {
+		if n.optimizer, err = unmarshalOptimizer(data.Optimizer); err != nil {
+			return err
+		}
+	}
+	n.schedule = nil
+	if len(data.Schedule) > 0 {
+		if n.schedule, err = unmarshalSchedule(data.Schedule); err != nil {
+			return err
+		}
+	}
+	n.model = data.Model
+	if n.model == nil {
+		// Networks saved before layers were introduced hold the matrices of
+		// each dense layer.
+		if n.model, err = data.legacyModel(); err != nil {
+			return err
+		}
+	}
+	if n.random, err = restoreRandom(data.Seed, data.RandomState); err != nil {
+		return err
+	}
+	n.model.setRandom(n.random.rng)
+	n.inputSteps, n.outputSteps = nil, nil
+	if len(data.InputSteps) > 0 {
+		if n.inputSteps, err = unmarshalPreprocessors(data.InputSteps); err != nil {
+			return err
+		}
+	}
+	if len(data.OutputSteps) > 0 {
+		if n.outputSteps, err = unmarshalPreprocessors(data.OutputSteps); err != nil {
+			return err
+		}
+	}
+	n.regularization = data.Regularization
+	n.clipValue = data.ClipValue
+	n.clipNorm = data.ClipNorm
+	n.mode = Inference
+	n.topology = n.model.topology()
+	n.prediction = nil
+	n.learningRate = data.LearningRate
+	n.rate = data.LearningRate
+	n.errFunc = ErrorFunction(data.ErrFunc)
+	n.debug = data.Debug
+	n.sm = data.SM
+	n.errorSolver = getErrorFunction(n.errFunc)
+	return nil
+}
+
+// Seed returns the seed of the network's random number generator.
+//
+// Returns:
+// - The seed, or zero if the network was created from a random number source.
+func (n *Network) Seed() uint64 {
+	return n.random.seed
+}
+
+// Model returns the layers of the network.
+//
+// Returns:
+// - A pointer to the sequential model holding the layers.
+func (n *Network) Model() *Sequential {
+	return n.model
+}
+
+// Activations returns the activation function of each dense layer of the
+// network, ending with the output layer. Layers within nested models are
+// included in order.
+//
+// Returns:
+// - A slice holding the activation function of each dense layer.
+func (n *Network) Activations() []ActivationFunction {
+	return n.model.activations()
+}
+
+// networkJSON is the saved form of a Network.
+type networkJSON struct {
+	Topology       []uint32        `json:"t"`
+	Model          *Sequential     `json:"m,omitempty"`
+	LearningRate   float64         `json:"k"`
+	ErrFunc        int             `json:"e"`
+	Debug          bool            `json:"d"`
+	SM             bool            `json:"s"`
+	Optimizer      json.RawMessage `json:"op,omitempty"`
+	Schedule       json.RawMessage `json:"sc,omitempty"`
+	Seed           uint64          `json:"sd,omitempty"`
+	RandomState    []byte          `json:"rs,omitempty"`
+	InputSteps     json.RawMessage `json:"pi,omitempty"`
+	OutputSteps    json.RawMessage `json:"po,omitempty"`
+	Regularization *Regularization `json:"rg,omitempty"`
+	ClipValue      float64         `json:"cv,omitempty"`
+	ClipNorm       float64         `json:"cn,omitempty"`
+
+	// The fields below are only read from networks saved before layers
+	// were introduced.
+	WeightMatrices []*Matrix         `json:"w,omitempty"`
+	BiasMatrices   []*Matrix         `json:"b,omitempty"`
+	Activation     int               `json:"a,omitempty"`
+	Output         int               `json:"o,omitempty"`
+	WeightStates   []*OptimizerState `json:"ws,omitempty"`
+	BiasStates     []*OptimizerState `json:"bs,omitempty"`
+}
+
+// legacyModel creates the dense layers of a network saved before layers were
+// introduced.
+//
+// Returns:
+// - A pointer to the model.
+// - An error if the saved matrices do not match the topology.
+func (data *networkJSON) legacyModel() (*Sequential, error) {
+	if len(data.Topology) < 2 || len(data.WeightMatrices) != len(data.Topology)-1 || len(data.BiasMatrices) != len(data.WeightMatrices) {
+		return nil, errors.New("saved network does not match its topology")
+	}
+	model := NewSequential()
+	for i, w := range data.WeightMatrices {
+		activation := ActivationFunction(data.Activation)
+		if i == len(data.WeightMatrices)-1 {
+			activation = ActivationFunction(data.Output)
+		}
+		d := NewDense(data.Topology[i+1], activation)
+		var ws, bs *OptimizerState
+		if i < len(data.WeightStates) {
+			ws = data.WeightStates[i]
+		}
+		if i < len(data.BiasStates) {
+			bs = data.BiasStates[i]
+		}
+		d.setParameters(w, data.BiasMatrices[i], ws, bs)
+		model.Add(d)
+	}
+	if _, err := model.Build(data.Topology[0]); err != nil {
+		return nil, err
+	}
+	return model, nil
+}
@@ -1,54 +1,190 @@
-package jasper
-
-// NetworkConfiguration represents the configuration of a neural network.
-// It contains the topology of the network, the learning rate, activation and output functions,
-// quiet mode, softmax mode, and the error function.
-type NetworkConfiguration struct {
-	// Topology is a slice of uint32 representing the topology of the neural network.
-	// The topology is a sequence of integers where each integer represents the number of neurons in a layer.
-	Topology []uint32
-
-	// LearningRate is a float64 representing the learning rate of the network.
-	// The learning rate determines how quickly the weights of the network are adjusted during training.
-	LearningRate float64
-
-	// Activation is an enum representing the activation function used in the hidden layers of the network.
-	Activation ActivationFunction
-
-	// Output is an enum representing the activation function used in the output layer of the network.
-	Output ActivationFunction
-
-	// Quiet is a boolean indicating whether the network should run in quiet mode.
-	// If true, the network will not print any messages during training.
-	Quiet bool
-
-	// SoftMax is a boolean indicating whether the network should use the SoftMax activation function in the output layer.
-	// If true, the output is normalized to a probability distribution.
-	SoftMax bool
-
-	// Error is an enum representing the error function used in the network.
-	// The error function is used to calculate the error between the predicted output and the target output.
-	Error ErrorFunction
-}
-
-// NewConfig creates a new NetworkConfiguration object with the given topology.
-// It sets the default learning rate to 0.1, the default activation function to Sigmoid,
-// and the default quiet mode to true.
-//
-// Parameters:
-// - topology: A slice of uint32 representing the topology of the neural network.
-//
-// Returns:
-// - A pointer to the created NetworkConfiguration object.
-func NewConfig(topology []uint32) *NetworkConfiguration {
-	// Create a new NetworkConfiguration object and set its properties.
-	return &NetworkConfiguration{
-		Topology:     topology, // Set the topology of the neural network.
-		LearningRate: 0.1,      // Set the learning rate to 0.1.
-		Activation:   Sigmoid,  // Set the activation function to Sigmoid.
-		Output:       Sigmoid,
-		Quiet:        false, // Set the quiet mode to true.
-		Error:        MeanSquaredError,
-		SoftMax:      false,
-	}
-}
+package jasper
+
+import (
+	"fmt"
+	"log/slog"
+	"math/rand/v2"
+)
+
+// NetworkConfiguration represents the configuration of a neural network.
+// It contains the topology of the network, the learning rate, activation and output functions,
+// quiet mode, softmax mode, and the error function.
+type NetworkConfiguration struct {
+	// Topology is a slice of uint32 representing the topology of the neural network.
+	// The topology is a sequence of integers where each integer represents the number of neurons in a layer.
+	Topology []uint32
+
+	// LearningRate is a float64 representing the learning rate of the network.
+	// The learning rate determines how quickly the weights of the network are adjusted during training.
+	LearningRate float64
+
+	// Activation is an enum representing the activation function used in the hidden layers of the network.
+	Activation ActivationFunction
+
+	// Output is an enum representing the activation function used in the output layer of the network.
+	Output ActivationFunction
+
+	// Activations holds the activation function of each layer after the input
+	// layer, ending with the output layer, so it must have one less entry than
+	// Topology. If set, it is used in place of Activation and Output.
+	Activations []ActivationFunction
+
+	// Quiet is a boolean indicating whether the network should run in quiet mode.
+	// If true, the network will not log any messages during training.
+	Quiet bool
+
+	// Logger receives the messages logged during training when Quiet is false.
+	// If nil, the default slog logger is used.
+	Logger *slog.Logger
+
+	// SoftMax is a boolean indicating whether the network should use the SoftMax activation function in the output layer.
+	// If true, the output is normalized to a probability distribution.
+	SoftMax bool
+
+	// Error is an enum representing the error function used in the network.
+	// The error function is used to calculate the error between the predicted output and the target output.
+	Error ErrorFunction
+
+	// Initializer sets how the weights are given their starting values. The
+	// DefaultInitializer chooses one to suit the activation function of each layer.
+	Initializer Initializer
+
+	// BiasInitializer sets how the biases are given their starting values. The
+	// DefaultInitializer sets them to zero.
+	BiasInitializer Initializer
+
+	// InitialValue is the value used by the Constant initializer.
+	InitialValue float64
+
+	// Optimizer is the algorithm used to update the weights and biases of the network.
+	// If nil, plain stochastic gradient descent is used.
+	Optimizer Optimizer
+
+	// Schedule varies the learning rate over the training iterations.
+	// If nil, the learning rate is constant. Each network is given its own
+	// copy of a built in schedule, so state such as that kept by
+	// ReduceOnPlateau is not shared.
+	Schedule LearningRateSchedule
+
+	// InputPreprocessing holds the preprocessors applied, in order, to the
+	// inputs of every row used to train the network and every input passed to
+	// Predict. Any that have not been fitted are fitted from the training rows
+	// the first time the network is trained. They are saved with the network.
+	// The first value of Topology must match the number of values produced.
+	InputPreprocessing []Preprocessor
+
+	// OutputPreprocessing holds the preprocessors applied, in order, to the
+	// target outputs of every row used to train the network. They are
+	// reversed for the outputs returned by Predict, so regression outputs are
+	// returned in their original units. The training and validation errors
+	// are calculated after preprocessing.
+	OutputPreprocessing []Preprocessor
+
+	// Seed seeds the random number generator of the network, which drives the
+	// initialisation of the weights and any other random behaviour, including
+	// the shuffling of the training data unless it has its own seed. Networks
+	// created with the same seed and configuration give the same results.
+	// If zero, a seed is chosen at random. The seed is saved with the network.
+	Seed uint64
+
+	// Source, if set, is used as the random number generator in place of Seed.
+	// A network created from a source cannot save the state of its generator.
+	Source rand.Source
+
+	// Model holds the layers of the network. If set, only the first value of
+	// Topology is used, giving the number of inputs, and Activation, Output,
+	// Activations and the initializers are ignored. LayerRegularization,
+	// Dropout and Normalization only apply to layers created from the
+	// Topology, so New returns an error if any of them are set with a Model;
+	// add the layers to the model instead. The network is built from a copy
+	// of the model, so the configuration can be used to create more than one
+	// network, and New returns an error if a layer is neither built in nor
+	// registered with RegisterLayer. If nil, a dense layer is created for each
+	// layer of the Topology.
+	Model *Sequential
+
+	// Regularization, if set, penalises the parameters of every layer that
+	// does not have a regularization of its own, and constrains their weights.
+	Regularization *Regularization
+
+	// LayerRegularization, if set, holds the regularization of each layer
+	// created from the Topology, in place of Regularization. A nil entry uses
+	// Regularization. The layers of a Model are given their own with
+	// SetRegularization.
+	LayerRegularization []*Regularization
+
+	// Dropout, if set, holds the dropout rate of each hidden layer created
+	// from the Topology. A dropout layer is added after each hidden layer
+	// with a rate other than zero, dropping that proportion of its outputs
+	// while training.
+	Dropout []float64
+
+	// AlphaDropout uses alpha dropout layers in place of dropout layers, to
+	// suit hidden layers that use the SELU activation function.
+	AlphaDropout bool
+
+	// Normalization selects the normalization layer added after each hidden
+	// layer created from the Topology, before any dropout layer. This helps
+	// deep networks to train, particularly with the Sigmoid and Tanh
+	// activation functions. BatchNormalization needs a BatchSize of at least
+	// 2.
+	Normalization Normalization
+
+	// ClipValue, if greater than zero, limits each value of the gradients to
+	// between -ClipValue and ClipValue before the parameters are updated.
+	ClipValue float64
+
+	// ClipNorm, if greater than zero, scales the gradients down when their
+	// combined length, over every parameter of the network, exceeds ClipNorm.
+	// It is applied after ClipValue.
+	ClipNorm float64
+}
+
+// NewConfig creates a new NetworkConfiguration object with the given topology.
+// It sets the default learning rate to 0.1, the default activation function to Sigmoid,
+// and the default quiet mode to true.
+//
+// Parameters:
+// - topology: A slice of uint32 representing the topology of the neural network.
+//
+// Returns:
+// - A pointer to the created NetworkConfiguration object.
+func NewConfig(topology []uint32) *NetworkConfiguration {
+	// Create a new NetworkConfiguration object and set its properties.
+	return &NetworkConfiguration{
+		Topology:     topology, // Set the topology of the neural network.
+		LearningRate: 0.1,      // Set the learning rate to 0.1.
+		Activation:   Sigmoid,  // Set the activation function to Sigmoid.
+		Output:       Sigmoid,
+		Quiet:        false, // Set the quiet mode to true.
+		Error:        MeanSquaredError,
+		SoftMax:      false,
+		Optimizer:    NewSGD(0, false),
+	}
+}
+
+// layerActivations returns the activation function of each layer after the
+// input layer.
+//
+// Returns:
+// - A slice holding the activation function of each layer, ending with the output layer.
+// - An error if Activations does not match the Topology.
+func (c *NetworkConfiguration) layerActivations() ([]ActivationFunction, error) {
+	if len(c.Activations) > 0 {
+		if len(c.Activations) != len(c.Topology)-1 {
+			return nil, fmt.Errorf("topology has %v layers after the inputs but %v activation functions were given", len(c.Topology)-1, len(c.Activations))
+		}
+		for i, a := range c.Activations {
+			if getActivationFunctions(a) == nil {
+				return nil, fmt.Errorf("layer %v: unknown activation function: %v", i, a)
+			}
+		}
+		return c.Activations, nil
+	}
+	res := make([]ActivationFunction, len(c.Topology)-1)
+	for i := range res {
+		res[i] = c.Activation
+	}
+	res[len(res)-1] = c.Output
+	return res, nil
+}
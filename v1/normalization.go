@@ -0,0 +1,689 @@
+// normalization.go - Batch and layer normalization layers used in the neural network.
+//
+// # Copyright 2024 Mark Oxley
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package jasper
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+)
+
+const (
+	// defaultMomentum is the momentum of the running statistics of a batch
+	// normalization layer created with a momentum of zero.
+	defaultMomentum = 0.9
+
+	// defaultEpsilon is the value added to the variance of a normalization
+	// layer created with an epsilon of zero, to avoid dividing by zero.
+	defaultEpsilon = 1e-5
+)
+
+// Normalization selects the normalization layers added after the hidden
+// layers of a network created from a topology.
+type Normalization int
+
+const (
+	// NoNormalization adds no normalization layers. It is the default.
+	NoNormalization Normalization = iota
+	// BatchNormalization adds a batch normalization layer after each hidden layer.
+	BatchNormalization
+	// LayerNormalization adds a layer normalization layer after each hidden layer.
+	LayerNormalization
+)
+
+// String returns the name of the normalization.
+func (n Normalization) String() string {
+	switch n {
+	case NoNormalization:
+		return "none"
+	case BatchNormalization:
+		return "batch"
+	case LayerNormalization:
+		return "layer"
+	}
+	return fmt.Sprintf("unknown normalization (%d)", int(n))
+}
+
+// layer creates a normalization layer of the type selected.
+//
+// Returns:
+// - The layer, or nil for NoNormalization.
+// - An error if the normalization is unknown.
+func (n Normalization) layer() (Layer, error) {
+	switch n {
+	case NoNormalization:
+		return nil, nil
+	case BatchNormalization:
+		return NewBatchNorm(0, 0), nil
+	case LayerNormalization:
+		return NewLayerNorm(0), nil
+	}
+	return nil, fmt.Errorf("unknown normalization: %d", int(n))
+}
+
+// unregularized is the regularization given to the scales and shifts of the
+// normalization layers, so that they are not penalised or constrained by the
+// network's regularization.
+var unregularized = &Regularization{}
+
+// normJSON is the saved form of a normalization layer.
+type normJSON struct {
+	Size       uint32          `json:"s,omitempty"`
+	Momentum   float64         `json:"m,omitempty"`
+	Epsilon    float64         `json:"e,omitempty"`
+	Gamma      *Matrix         `json:"g,omitempty"`
+	Beta       *Matrix         `json:"b,omitempty"`
+	GammaState *OptimizerState `json:"gs,omitempty"`
+	BetaState  *OptimizerState `json:"bs,omitempty"`
+	Mean       *Matrix         `json:"rm,omitempty"`
+	Variance   *Matrix         `json:"rv,omitempty"`
+}
+
+// scaleShift holds the learnable scale (gamma) and shift (beta) of a
+// normalization layer, with a value of each for every input.
+type scaleShift struct {
+	// size is the number of values in each input row, set by Build.
+	size uint32
+
+	// epsilon is added to the variance to avoid dividing by zero.
+	epsilon float64
+
+	// gamma holds the single row matrix of scales.
+	gamma *Parameter
+
+	// beta holds the single row matrix of shifts.
+	beta *Parameter
+
+	// normalized holds the normalized inputs of the most recent call to
+	// Forward, before they are scaled and shifted.
+	normalized *Matrix
+}
+
+// build creates the scales and shifts, starting at one and zero, if they do
+// not already exist for inputs of the given width.
+//
+// Parameters:
+// - inputs: The number of values in each input row.
+//
+// Returns:
+// - An error if epsilon is negative.
+func (s *scaleShift) build(inputs uint32) error {
+	if s.epsilon < 0 {
+		return fmt.Errorf("normalization epsilon is negative: %v", s.epsilon)
+	}
+	if s.epsilon == 0 {
+		s.epsilon = defaultEpsilon
+	}
+	s.size = inputs
+	if s.gamma != nil && s.gamma.Value.cols == inputs {
+		return nil
+	}
+	gamma := NewMatrix(inputs, 1)
+	for k := range gamma.values {
+		gamma.values[k] = 1
+	}
+	s.setParameters(gamma, NewMatrix(inputs, 1), nil, nil)
+	return nil
+}
+
+// setParameters sets the scales and shifts, with their optimizer state.
+//
+// Parameters:
+// - gamma: The scales.
+// - beta: The shifts.
+// - gammaState: The optimizer state of the scales, or nil.
+// - betaState: The optimizer state of the shifts, or nil.
+func (s *scaleShift) setParameters(gamma, beta *Matrix, gammaState, betaState *OptimizerState) {
+	s.gamma = newParameter(gamma)
+	s.beta = newParameter(beta)
+	if gammaState != nil {
+		s.gamma.State = gammaState
+	}
+	if betaState != nil {
+		s.beta.State = betaState
+	}
+	s.gamma.Regularization = unregularized
+	s.beta.Regularization = unregularized
+	s.beta.Bias = true
+}
+
+// apply scales and shifts the normalized inputs, keeping them for the back
+// propagation.
+//
+// Parameters:
+// - normalized: The normalized inputs.
+//
+// Returns:
+// - The scaled and shifted values.
+func (s *scaleShift) apply(normalized *Matrix) *Matrix {
+	s.normalized = normalized
+	res := NewMatrix(normalized.cols, normalized.rows)
+	for k, v := range normalized.values {
+		c := k % int(normalized.cols)
+		res.values[k] = s.gamma.Value.values[c]*v + s.beta.Value.values[c]
+	}
+	return res
+}
+
+// backward stores the gradients of the scales and shifts.
+//
+// Parameters:
+// - gradient: The gradient of the error with respect to the output of the layer.
+//
+// Returns:
+// - The gradient of the error with respect to the normalized inputs.
+// - An error if the gradient is the wrong size.
+func (s *scaleShift) backward(gradient *Matrix) (*Matrix, error) {
+	if s.normalized == nil || gradient.cols != s.normalized.cols || gradient.rows != s.normalized.rows {
+		return nil, errors.New("back propagation error: incorrect gradient size")
+	}
+	s.gamma.Gradient = NewMatrix(s.size, 1)
+	s.beta.Gradient = gradient.SumRows()
+	res := NewMatrix(gradient.cols, gradient.rows)
+	for k, g := range gradient.values {
+		c := k % int(gradient.cols)
+		s.gamma.Gradient.values[c] += g * s.normalized.values[k]
+		res.values[k] = g * s.gamma.Value.values[c]
+	}
+	return res, nil
+}
+
+// parameters returns the scales and shifts.
+//
+// Returns:
+// - A slice holding the scales and the shifts.
+func (s *scaleShift) parameters() []*Parameter {
+	if s.gamma == nil {
+		return nil
+	}
+	return []*Parameter{s.gamma, s.beta}
+}
+
+// BatchNorm is a batch normalization layer. It normalizes each input to a
+// mean of zero and a variance of one across the rows of a batch, then scales
+// and shifts it by a learnt amount. This keeps the inputs of the following
+// layer in a steady range, which helps deep networks to train.
+//
+// In training mode the mean and variance of the batch are used, and running
+// averages of them are kept. Train returns an error if the BatchSize is less
+// than 2. A batch of a single row, such as the last batch of an iteration,
+// has no variance, so it is normalized with the running averages instead,
+// which it updates. In inference mode the running averages are always used,
+// so predictions do not depend on the other rows. The running averages are
+// saved with the network.
+type BatchNorm struct {
+	scaleShift
+
+	// momentum is the proportion of the running averages kept at each update.
+	momentum float64
+
+	// mean holds the single row matrix of the running means.
+	mean *Matrix
+
+	// variance holds the single row matrix of the running variances.
+	variance *Matrix
+
+	// mode is the mode of the layer.
+	mode Mode
+
+	// invStd holds the inverse standard deviations used by the most recent
+	// call to Forward.
+	invStd []float64
+
+	// batchStats is true if the most recent call to Forward normalized with
+	// the statistics of the batch.
+	batchStats bool
+}
+
+// NewBatchNorm creates a batch normalization layer.
+//
+// Parameters:
+// - momentum: The proportion of the running averages kept at each update, from 0 up to but not including 1. Zero uses 0.9.
+// - epsilon: The value added to the variance to avoid dividing by zero. Zero uses 1e-5.
+//
+// Returns:
+// - A pointer to the layer.
+func NewBatchNorm(momentum, epsilon float64) *BatchNorm {
+	return &BatchNorm{
+		scaleShift: scaleShift{epsilon: epsilon},
+		momentum:   momentum,
+	}
+}
+
+// Build prepares the layer to receive inputs of the given width, creating the
+// scales, shifts and running averages if they do not already exist with the
+// right width.
+//
+// Parameters:
+// - inputs: The number of values in each input row.
+//
+// Returns:
+// - The number of values in each output row, which is the same as the inputs.
+// - An error if the momentum or epsilon is out of range.
+func (b *BatchNorm) Build(inputs uint32) (uint32, error) {
+	if b.momentum < 0 || b.momentum >= 1 {
+		return 0, fmt.Errorf("batch normalization momentum out of range: %v", b.momentum)
+	}
+	if b.momentum == 0 {
+		b.momentum = defaultMomentum
+	}
+	if err := b.build(inputs); err != nil {
+		return 0, err
+	}
+	if b.mean == nil || b.mean.cols != inputs {
+		b.mean = NewMatrix(inputs, 1)
+		b.variance = NewMatrix(inputs, 1)
+		for k := range b.variance.values {
+			b.variance.values[k] = 1
+		}
+	}
+	return inputs, nil
+}
+
+// SetMode sets whether the layer normalizes with the statistics of each
+// batch, which it does only in training mode.
+//
+// Parameters:
+// - m: The mode.
+func (b *BatchNorm) SetMode(m Mode) {
+	b.mode = m
+}
+
+// RunningMean returns the running averages of the means of the inputs.
+//
+// Returns:
+// - A slice holding the mean of each input.
+func (b *BatchNorm) RunningMean() []float64 {
+	if b.mean == nil {
+		return nil
+	}
+	return append([]float64{}, b.mean.values...)
+}
+
+// RunningVariance returns the running averages of the variances of the inputs.
+//
+// Returns:
+// - A slice holding the variance of each input.
+func (b *BatchNorm) RunningVariance() []float64 {
+	if b.variance == nil {
+		return nil
+	}
+	return append([]float64{}, b.variance.values...)
+}
+
+// state returns a copy of the running averages.
+//
+// Returns:
+// - The running means and variances.
+func (b *BatchNorm) state() []*Matrix {
+	if b.mean == nil {
+		return nil
+	}
+	return []*Matrix{b.mean.Copy(), b.variance.Copy()}
+}
+
+// setState replaces the running averages with a copy of those returned by state.
+//
+// Parameters:
+// - s: The running means and variances.
+func (b *BatchNorm) setState(s []*Matrix) {
+	if len(s) == 2 {
+		b.mean = s[0].Copy()
+		b.variance = s[1].Copy()
+	}
+}
+
+// Forward feeds a batch of inputs through the layer.
+//
+// Parameters:
+// - input: A matrix holding one row of input values for each sample.
+//
+// Returns:
+// - A matrix holding one row of output values for each sample.
+// - An error if the input is the wrong size.
+func (b *BatchNorm) Forward(input *Matrix) (*Matrix, error) {
+	if b.gamma == nil {
+		return nil, errors.New("batch normalization layer has not been built")
+	}
+	if input.cols != b.size {
+		return nil, errors.New("incorrect input size")
+	}
+	cols := int(input.cols)
+	rows := float64(input.rows)
+	mean := b.mean.values
+	variance := b.variance.values
+	b.batchStats = b.mode == Training && input.rows > 1
+	if b.batchStats {
+		// Find the mean and variance of each input over the batch.
+		mean = make([]float64, cols)
+		variance = make([]float64, cols)
+		for k, v := range input.values {
+			mean[k%cols] += v / rows
+		}
+		for k, v := range input.values {
+			d := v - mean[k%cols]
+			variance[k%cols] += d * d / rows
+		}
+	}
+
+	// Normalize the inputs before updating the running averages, so a single
+	// row is normalized by the averages of the rows before it.
+	b.invStd = make([]float64, cols)
+	for c := range cols {
+		b.invStd[c] = 1 / math.Sqrt(variance[c]+b.epsilon)
+	}
+	normalized := NewMatrix(input.cols, input.rows)
+	for k, v := range input.values {
+		c := k % cols
+		normalized.values[k] = (v - mean[c]) * b.invStd[c]
+	}
+	if b.mode == Training {
+		b.update(input, mean, variance)
+	}
+	return b.apply(normalized), nil
+}
+
+// update moves the running averages towards the statistics of a batch. A
+// single row updates the variance with its squared distance from the running
+// mean.
+//
+// Parameters:
+// - input: The inputs of the batch.
+// - mean: The mean of each input over the batch.
+// - variance: The variance of each input over the batch.
+func (b *BatchNorm) update(input *Matrix, mean, variance []float64) {
+	rate := 1 - b.momentum
+	for c := range b.mean.values {
+		if !b.batchStats {
+			d := input.values[c] - b.mean.values[c]
+			b.mean.values[c] += rate * d
+			b.variance.values[c] += rate * (d*d - b.variance.values[c])
+			continue
+		}
+		b.mean.values[c] += rate * (mean[c] - b.mean.values[c])
+		b.variance.values[c] += rate * (variance[c] - b.variance.values[c])
+	}
+}
+
+// Backward propagates the gradient of the error back through the layer,
+// storing the gradients of the scales and shifts.
+//
+// Parameters:
+// - gradient: The gradient of the error with respect to the output of the layer.
+//
+// Returns:
+// - The gradient of the error with respect to the input of the layer.
+// - An error if the gradient is the wrong size.
+func (b *BatchNorm) Backward(gradient *Matrix) (*Matrix, error) {
+	gradients, err := b.backward(gradient)
+	if err != nil {
+		return nil, err
+	}
+	cols := int(gradients.cols)
+	if !b.batchStats {
+		// The running averages are constants, so each input is simply scaled.
+		for k := range gradients.values {
+			gradients.values[k] *= b.invStd[k%cols]
+		}
+		return gradients, nil
+	}
+
+	// The mean and variance depend on every row of the batch, so the gradient
+	// of each input is reduced by the mean gradient and by its share of the
+	// gradient of the variance.
+	rows := float64(gradients.rows)
+	sum := make([]float64, cols)
+	dot := make([]float64, cols)
+	for k, g := range gradients.values {
+		sum[k%cols] += g
+		dot[k%cols] += g * b.normalized.values[k]
+	}
+	res := NewMatrix(gradients.cols, gradients.rows)
+	for k, g := range gradients.values {
+		c := k % cols
+		res.values[k] = b.invStd[c] * (g - sum[c]/rows - b.normalized.values[k]*dot[c]/rows)
+	}
+	return res, nil
+}
+
+// Parameters returns the scales and shifts of the layer.
+//
+// Returns:
+// - A slice holding the scales and the shifts.
+func (b *BatchNorm) Parameters() []*Parameter {
+	return b.parameters()
+}
+
+// OutputSize returns the number of values in each output row.
+//
+// Returns:
+// - The number of output values.
+func (b *BatchNorm) OutputSize() uint32 {
+	return b.size
+}
+
+// MarshalJSON marshals the layer, its parameters, their optimizer state and
+// the running averages into JSON.
+//
+// Returns:
+// - A JSON byte slice representing the layer.
+// - An error if there is an error during the marshaling process.
+func (b *BatchNorm) MarshalJSON() ([]byte, error) {
+	data := normJSON{
+		Size:     b.size,
+		Momentum: b.momentum,
+		Epsilon:  b.epsilon,
+		Mean:     b.mean,
+		Variance: b.variance,
+	}
+	if b.gamma != nil {
+		data.Gamma = b.gamma.Value
+		data.Beta = b.beta.Value
+		data.GammaState = b.gamma.State
+		data.BetaState = b.beta.State
+	}
+	return json.Marshal(&data)
+}
+
+// UnmarshalJSON unmarshals the layer from JSON.
+//
+// Parameters:
+// - body: The JSON byte slice to unmarshal.
+//
+// Returns:
+// - An error if there is an error during the unmarshaling process.
+func (b *BatchNorm) UnmarshalJSON(body []byte) error {
+	data := normJSON{}
+	if err := json.Unmarshal(body, &data); err != nil {
+		return err
+	}
+	*b = *NewBatchNorm(data.Momentum, data.Epsilon)
+	b.size = data.Size
+	if data.Gamma != nil && data.Beta != nil {
+		b.setParameters(data.Gamma, data.Beta, data.GammaState, data.BetaState)
+	}
+	b.mean = data.Mean
+	b.variance = data.Variance
+	return nil
+}
+
+// LayerNorm is a layer normalization layer. It normalizes the inputs of each
+// row to a mean of zero and a variance of one, then scales and shifts each
+// input by a learnt amount.
+//
+// Each row is normalized on its own, so the layer behaves the same in
+// training and inference, and for any size of batch.
+type LayerNorm struct {
+	scaleShift
+
+	// invStd holds the inverse standard deviation of each row of the most
+	// recent call to Forward.
+	invStd []float64
+}
+
+// NewLayerNorm creates a layer normalization layer.
+//
+// Parameters:
+// - epsilon: The value added to the variance to avoid dividing by zero. Zero uses 1e-5.
+//
+// Returns:
+// - A pointer to the layer.
+func NewLayerNorm(epsilon float64) *LayerNorm {
+	return &LayerNorm{scaleShift: scaleShift{epsilon: epsilon}}
+}
+
+// Build prepares the layer to receive inputs of the given width, creating the
+// scales and shifts if they do not already exist with the right width.
+//
+// Parameters:
+// - inputs: The number of values in each input row.
+//
+// Returns:
+// - The number of values in each output row, which is the same as the inputs.
+// - An error if epsilon is negative.
+func (l *LayerNorm) Build(inputs uint32) (uint32, error) {
+	if err := l.build(inputs); err != nil {
+		return 0, err
+	}
+	return inputs, nil
+}
+
+// Forward feeds a batch of inputs through the layer.
+//
+// Parameters:
+// - input: A matrix holding one row of input values for each sample.
+//
+// Returns:
+// - A matrix holding one row of output values for each sample.
+// - An error if the input is the wrong size.
+func (l *LayerNorm) Forward(input *Matrix) (*Matrix, error) {
+	if l.gamma == nil {
+		return nil, errors.New("layer normalization layer has not been built")
+	}
+	if input.cols != l.size {
+		return nil, errors.New("incorrect input size")
+	}
+	cols := int(input.cols)
+	l.invStd = make([]float64, input.rows)
+	normalized := NewMatrix(input.cols, input.rows)
+	for r := range int(input.rows) {
+		values := input.values[r*cols : (r+1)*cols]
+		var mean, variance float64
+		for _, v := range values {
+			mean += v / float64(cols)
+		}
+		for _, v := range values {
+			variance += (v - mean) * (v - mean) / float64(cols)
+		}
+		l.invStd[r] = 1 / math.Sqrt(variance+l.epsilon)
+		for c, v := range values {
+			normalized.values[r*cols+c] = (v - mean) * l.invStd[r]
+		}
+	}
+	return l.apply(normalized), nil
+}
+
+// Backward propagates the gradient of the error back through the layer,
+// storing the gradients of the scales and shifts.
+//
+// Parameters:
+// - gradient: The gradient of the error with respect to the output of the layer.
+//
+// Returns:
+// - The gradient of the error with respect to the input of the layer.
+// - An error if the gradient is the wrong size.
+func (l *LayerNorm) Backward(gradient *Matrix) (*Matrix, error) {
+	gradients, err := l.backward(gradient)
+	if err != nil {
+		return nil, err
+	}
+
+	// The mean and variance of a row depend on each of its inputs, so the
+	// gradient of each input is reduced by the mean gradient of the row and by
+	// its share of the gradient of the variance.
+	cols := int(gradients.cols)
+	res := NewMatrix(gradients.cols, gradients.rows)
+	for r := range int(gradients.rows) {
+		row := gradients.values[r*cols : (r+1)*cols]
+		normalized := l.normalized.values[r*cols : (r+1)*cols]
+		var sum, dot float64
+		for c, g := range row {
+			sum += g
+			dot += g * normalized[c]
+		}
+		for c, g := range row {
+			res.values[r*cols+c] = l.invStd[r] * (g - sum/float64(cols) - normalized[c]*dot/float64(cols))
+		}
+	}
+	return res, nil
+}
+
+// Parameters returns the scales and shifts of the layer.
+//
+// Returns:
+// - A slice holding the scales and the shifts.
+func (l *LayerNorm) Parameters() []*Parameter {
+	return l.parameters()
+}
+
+// OutputSize returns the number of values in each output row.
+//
+// Returns:
+// - The number of output values.
+func (l *LayerNorm) OutputSize() uint32 {
+	return l.size
+}
+
+// MarshalJSON marshals the layer, its parameters and their optimizer state
+// into JSON.
+//
+// Returns:
+// - A JSON byte slice representing the layer.
+// - An error if there is an error during the marshaling process.
+func (l *LayerNorm) MarshalJSON() ([]byte, error) {
+	data := normJSON{
+		Size:    l.size,
+		Epsilon: l.epsilon,
+	}
+	if l.gamma != nil {
+		data.Gamma = l.gamma.Value
+		data.Beta = l.beta.Value
+		data.GammaState = l.gamma.State
+		data.BetaState = l.beta.State
+	}
+	return json.Marshal(&data)
+}
+
+// UnmarshalJSON unmarshals the layer from JSON.
+//
+// Parameters:
+// - body: The JSON byte slice to unmarshal.
+//
+// Returns:
+// - An error if there is an error during the unmarshaling process.
+func (l *LayerNorm) UnmarshalJSON(body []byte) error {
+	data := normJSON{}
+	if err := json.Unmarshal(body, &data); err != nil {
+		return err
+	}
+	*l = *NewLayerNorm(data.Epsilon)
+	l.size = data.Size
+	if data.Gamma != nil && data.Beta != nil {
+		l.setParameters(data.Gamma, data.Beta, data.GammaState, data.BetaState)
+	}
+	return nil
+}
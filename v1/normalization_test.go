@@ -0,0 +1,176 @@
+package jasper
+
+import (
+	"encoding/json"
+	"math"
+	"slices"
+	"testing"
+)
+
+// normalizationInput returns a batch of four rows of two inputs.
+func normalizationInput() *Matrix {
+	m := NewMatrix(2, 4)
+	copy(m.values, []float64{1, 10, 2, 20, 3, 30, 6, 60})
+	return m
+}
+
+// columnStats returns the mean and variance of each column of a matrix.
+func columnStats(m *Matrix) ([]float64, []float64) {
+	cols, rows := int(m.cols), float64(m.rows)
+	mean := make([]float64, cols)
+	variance := make([]float64, cols)
+	for k, v := range m.values {
+		mean[k%cols] += v / rows
+	}
+	for k, v := range m.values {
+		d := v - mean[k%cols]
+		variance[k%cols] += d * d / rows
+	}
+	return mean, variance
+}
+
+func TestBatchNormTraining(t *testing.T) {
+	b := NewBatchNorm(0, 0)
+	if _, err := b.Build(2); err != nil {
+		t.Fatal(err)
+	}
+	b.SetMode(Training)
+	input := normalizationInput()
+	out, err := b.Forward(input)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Each input is normalized with the statistics of the batch.
+	mean, variance := columnStats(out)
+	for c := range mean {
+		if !near(mean[c], 0) || !near(variance[c], 1) {
+			t.Errorf("input %v: mean %v and variance %v, want 0 and 1", c, mean[c], variance[c])
+		}
+	}
+
+	// The running averages move a tenth of the way to the batch statistics.
+	mean, variance = columnStats(input)
+	for c := range mean {
+		if !near(b.RunningMean()[c], 0.1*mean[c]) || !near(b.RunningVariance()[c], 0.9+0.1*variance[c]) {
+			t.Errorf("input %v: running mean %v and variance %v, want %v and %v", c, b.RunningMean()[c], b.RunningVariance()[c], 0.1*mean[c], 0.9+0.1*variance[c])
+		}
+	}
+}
+
+func TestBatchNormInference(t *testing.T) {
+	b := NewBatchNorm(0.5, 0)
+	if _, err := b.Build(2); err != nil {
+		t.Fatal(err)
+	}
+	b.SetMode(Training)
+	for range 3 {
+		if _, err := b.Forward(normalizationInput()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	mean, variance := b.RunningMean(), b.RunningVariance()
+
+	// Each row is normalized with the running averages, which are unchanged.
+	b.SetMode(Inference)
+	input := normalizationInput()
+	out, err := b.Forward(input)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for k, v := range input.values {
+		c := k % 2
+		if want := (v - mean[c]) / math.Sqrt(variance[c]+defaultEpsilon); !near(out.values[k], want) {
+			t.Errorf("value %v normalized to %v, want %v", v, out.values[k], want)
+		}
+	}
+	if !slices.Equal(b.RunningMean(), mean) || !slices.Equal(b.RunningVariance(), variance) {
+		t.Error("inference changed the running averages")
+	}
+}
+
+func TestBatchNormJSON(t *testing.T) {
+	c := NewConfig([]uint32{2})
+	c.Seed = 1
+	c.Quiet = true
+	c.Model = NewSequential(NewDense(4, Tanh), NewBatchNorm(0, 0), NewDense(1, Sigmoid))
+	n, err := New(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	td := &TrainingData{TrainingSource: NewSliceSource(xorRows()), ValidationSource: NewSliceSource(xorRows()), Iterations: 20, BatchSize: 4}
+	if _, err := n.Train(td); err != nil {
+		t.Fatal(err)
+	}
+	body, err := json.Marshal(n)
+	if err != nil {
+		t.Fatal(err)
+	}
+	loaded := &Network{}
+	if err := json.Unmarshal(body, loaded); err != nil {
+		t.Fatal(err)
+	}
+	want := n.model.layers[1].(*BatchNorm)
+	got := loaded.model.layers[1].(*BatchNorm)
+	if !slices.Equal(got.RunningMean(), want.RunningMean()) || !slices.Equal(got.RunningVariance(), want.RunningVariance()) {
+		t.Errorf("running averages %v and %v, want %v and %v", got.RunningMean(), got.RunningVariance(), want.RunningMean(), want.RunningVariance())
+	}
+	if slices.Equal(want.RunningVariance(), []float64{1, 1, 1, 1}) {
+		t.Error("the running averages were not trained")
+	}
+	for _, row := range xorRows() {
+		p, _ := n.Predict(row.Input)
+		q, err := loaded.Predict(row.Input)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if p[0] != q[0] {
+			t.Errorf("loaded prediction of %v = %v, want %v", row.Input, q, p)
+		}
+	}
+}
+
+func TestBatchNormErrors(t *testing.T) {
+	for _, momentum := range []float64{-0.1, 1} {
+		if _, err := NewBatchNorm(momentum, 0).Build(2); err == nil {
+			t.Errorf("momentum %v: expected an error", momentum)
+		}
+	}
+	if _, err := NewBatchNorm(0, -1).Build(2); err == nil {
+		t.Error("negative epsilon: expected an error")
+	}
+
+	// The statistics of a single row cannot be used for training.
+	c := NewConfig([]uint32{2})
+	c.Quiet = true
+	c.Model = NewSequential(NewDense(4, Tanh), NewBatchNorm(0, 0), NewDense(1, Sigmoid))
+	n, err := New(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	td := &TrainingData{TrainingSource: NewSliceSource(xorRows()), ValidationSource: NewSliceSource(xorRows()), Iterations: 1}
+	if _, err := n.Train(td); err == nil {
+		t.Error("batch size of one: expected an error")
+	}
+}
+
+func TestLayerNorm(t *testing.T) {
+	l := NewLayerNorm(0)
+	if _, err := l.Build(4); err != nil {
+		t.Fatal(err)
+	}
+	input := NewMatrix(4, 2)
+	copy(input.values, []float64{1, 2, 3, 6, -5, 0, 5, 10})
+	out, err := l.Forward(input)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Each row is normalized on its own.
+	mean, variance := columnStats(out.Transpose())
+	for r := range mean {
+		if !near(mean[r], 0) || !near(variance[r], 1) {
+			t.Errorf("row %v: mean %v and variance %v, want 0 and 1", r, mean[r], variance[r])
+		}
+	}
+}
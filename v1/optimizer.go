@@ -0,0 +1,436 @@
+// optimizer.go - Optimizers used to update the weights and biases of the neural network.
+//
+// # Copyright 2024 Mark Oxley
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package jasper
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+)
+
+// Optimizer is the interface implemented by the algorithms that update the
+// weights and biases of the network from their gradients.
+//
+// The built in optimizers are SGD (with optional momentum and Nesterov
+// momentum), RMSProp, Adam, AdamW and Adagrad. Other optimizers can be used
+// for training, but cannot be saved, so saving a network or checkpoint that
+// uses one returns an error.
+type Optimizer interface {
+	// Name returns the name used to identify the optimizer in a saved network.
+	Name() string
+
+	// Update adjusts the values of param in place, stepping against gradient.
+	//
+	// Parameters:
+	// - param: The weight or bias matrix to update.
+	// - gradient: The gradient of the error with respect to param.
+	// - state: The state kept by the optimizer for param.
+	// - rate: The learning rate.
+	Update(param, gradient *Matrix, state *OptimizerState, rate float64)
+}
+
+// OptimizerState holds the values an optimizer keeps between updates for a
+// single weight or bias matrix.
+type OptimizerState struct {
+	// Step is the number of updates applied to the matrix.
+	Step int `json:"s"`
+
+	// Velocity holds the velocity or first moment of the gradients.
+	Velocity *Matrix `json:"m,omitempty"`
+
+	// Squares holds the running or accumulated squares of the gradients.
+	Squares *Matrix `json:"v,omitempty"`
+}
+
+// velocity returns the velocity matrix, creating it with the same dimensions
+// as param if it does not exist.
+//
+// Parameters:
+// - param: The matrix the state belongs to.
+//
+// Returns:
+// - The velocity matrix.
+func (s *OptimizerState) velocity(param *Matrix) *Matrix {
+	if s.Velocity == nil || len(s.Velocity.values) != len(param.values) {
+		s.Velocity = NewMatrix(param.cols, param.rows)
+	}
+	return s.Velocity
+}
+
+// squares returns the squared gradient matrix, creating it with the same
+// dimensions as param if it does not exist.
+//
+// Parameters:
+// - param: The matrix the state belongs to.
+//
+// Returns:
+// - The squared gradient matrix.
+func (s *OptimizerState) squares(param *Matrix) *Matrix {
+	if s.Squares == nil || len(s.Squares.values) != len(param.values) {
+		s.Squares = NewMatrix(param.cols, param.rows)
+	}
+	return s.Squares
+}
+
+// copy returns a copy of the state that shares no matrices with the original.
+//
+// Returns:
+// - A pointer to the copy.
+func (s *OptimizerState) copy() *OptimizerState {
+	res := &OptimizerState{Step: s.Step}
+	if s.Velocity != nil {
+		res.Velocity = s.Velocity.Copy()
+	}
+	if s.Squares != nil {
+		res.Squares = s.Squares.Copy()
+	}
+	return res
+}
+
+// getOptimizer returns an empty instance of the optimizer with the given name.
+//
+// Parameters:
+// - name: The name of the optimizer.
+//
+// Returns:
+// - Optimizer: An instance of the optimizer, or nil if the name is unknown.
+func getOptimizer(name string) Optimizer {
+	switch name {
+	case "sgd":
+		return &SGD{}
+	case "rmsprop":
+		return &RMSProp{}
+	case "adam":
+		return &Adam{}
+	case "adamw":
+		return &AdamW{}
+	case "adagrad":
+		return &Adagrad{}
+	}
+	return nil
+}
+
+// marshalOptimizer marshals an optimizer and its settings into JSON.
+//
+// Parameters:
+// - o: The optimizer to marshal.
+//
+// Returns:
+// - A JSON byte slice holding the name and settings of the optimizer.
+// - An error if the optimizer is not built in or cannot be marshaled.
+func marshalOptimizer(o Optimizer) ([]byte, error) {
+	if reflect.TypeOf(getOptimizer(o.Name())) != reflect.TypeOf(o) {
+		return nil, fmt.Errorf("cannot save optimizer of type %T", o)
+	}
+	params, err := json.Marshal(o)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(&struct {
+		Name   string          `json:"n"`
+		Params json.RawMessage `json:"p"`
+	}{
+		Name:   o.Name(),
+		Params: params,
+	})
+}
+
+// unmarshalOptimizer unmarshals an optimizer written by marshalOptimizer.
+//
+// Parameters:
+// - body: The JSON byte slice to unmarshal.
+//
+// Returns:
+// - The optimizer.
+// - An error if the optimizer is unknown or cannot be unmarshaled.
+func unmarshalOptimizer(body []byte) (Optimizer, error) {
+	data := struct {
+		Name   string          `json:"n"`
+		Params json.RawMessage `json:"p"`
+	}{}
+	if err := json.Unmarshal(body, &data); err != nil {
+		return nil, err
+	}
+	o := getOptimizer(data.Name)
+	if o == nil {
+		return nil, fmt.Errorf("unknown optimizer: %v", data.Name)
+	}
+	if err := json.Unmarshal(data.Params, o); err != nil {
+		return nil, err
+	}
+	return o, nil
+}
+
+// SGD is stochastic gradient descent with optional momentum.
+//
+// With a Momentum of zero this is plain gradient descent, which is the
+// behaviour of the network when no optimizer is configured.
+type SGD struct {
+	// Momentum is the fraction of the previous update carried into the next.
+	Momentum float64 `json:"m"`
+
+	// Nesterov selects Nesterov accelerated gradient when momentum is used.
+	Nesterov bool `json:"n"`
+}
+
+// NewSGD creates a stochastic gradient descent optimizer.
+//
+// Parameters:
+// - momentum: The momentum to use, or zero for plain gradient descent.
+// - nesterov: Whether to use Nesterov momentum.
+//
+// Returns:
+// - A pointer to the optimizer.
+func NewSGD(momentum float64, nesterov bool) *SGD {
+	return &SGD{
+		Momentum: momentum,
+		Nesterov: nesterov,
+	}
+}
+
+// Name returns the name used to identify the optimizer in a saved network.
+func (o *SGD) Name() string {
+	return "sgd"
+}
+
+// Update adjusts the values of param in place, stepping against gradient.
+//
+// Parameters:
+// - param: The weight or bias matrix to update.
+// - gradient: The gradient of the error with respect to param.
+// - state: The state kept by the optimizer for param.
+// - rate: The learning rate.
+func (o *SGD) Update(param, gradient *Matrix, state *OptimizerState, rate float64) {
+	state.Step++
+	if o.Momentum == 0 {
+		for i, g := range gradient.values {
+			param.values[i] -= rate * g
+		}
+		return
+	}
+	v := state.velocity(param)
+	for i, g := range gradient.values {
+		v.values[i] = o.Momentum*v.values[i] - rate*g
+		if o.Nesterov {
+			param.values[i] += o.Momentum*v.values[i] - rate*g
+		} else {
+			param.values[i] += v.values[i]
+		}
+	}
+}
+
+// RMSProp divides the learning rate by a running average of the magnitude of
+// recent gradients.
+type RMSProp struct {
+	// Rho is the decay rate of the running average.
+	Rho float64 `json:"r"`
+
+	// Epsilon is added to the denominator to avoid division by zero.
+	Epsilon float64 `json:"e"`
+}
+
+// NewRMSProp creates an RMSProp optimizer with a Rho of 0.9 and an Epsilon of 1e-8.
+//
+// Returns:
+// - A pointer to the optimizer.
+func NewRMSProp() *RMSProp {
+	return &RMSProp{
+		Rho:     0.9,
+		Epsilon: 1e-8,
+	}
+}
+
+// Name returns the name used to identify the optimizer in a saved network.
+func (o *RMSProp) Name() string {
+	return "rmsprop"
+}
+
+// Update adjusts the values of param in place, stepping against gradient.
+//
+// Parameters:
+// - param: The weight or bias matrix to update.
+// - gradient: The gradient of the error with respect to param.
+// - state: The state kept by the optimizer for param.
+// - rate: The learning rate.
+func (o *RMSProp) Update(param, gradient *Matrix, state *OptimizerState, rate float64) {
+	state.Step++
+	s := state.squares(param)
+	for i, g := range gradient.values {
+		s.values[i] = o.Rho*s.values[i] + (1-o.Rho)*g*g
+		param.values[i] -= rate * g / (math.Sqrt(s.values[i]) + o.Epsilon)
+	}
+}
+
+// Adam combines momentum with RMSProp style scaling, using bias corrected
+// estimates of the first and second moments of the gradients.
+type Adam struct {
+	// Beta1 is the decay rate of the first moment.
+	Beta1 float64 `json:"b1"`
+
+	// Beta2 is the decay rate of the second moment.
+	Beta2 float64 `json:"b2"`
+
+	// Epsilon is added to the denominator to avoid division by zero.
+	Epsilon float64 `json:"e"`
+}
+
+// NewAdam creates an Adam optimizer with a Beta1 of 0.9, a Beta2 of 0.999 and
+// an Epsilon of 1e-8.
+//
+// Returns:
+// - A pointer to the optimizer.
+func NewAdam() *Adam {
+	return &Adam{
+		Beta1:   0.9,
+		Beta2:   0.999,
+		Epsilon: 1e-8,
+	}
+}
+
+// Name returns the name used to identify the optimizer in a saved network.
+func (o *Adam) Name() string {
+	return "adam"
+}
+
+// Update adjusts the values of param in place, stepping against gradient.
+//
+// Parameters:
+// - param: The weight or bias matrix to update.
+// - gradient: The gradient of the error with respect to param.
+// - state: The state kept by the optimizer for param.
+// - rate: The learning rate.
+func (o *Adam) Update(param, gradient *Matrix, state *OptimizerState, rate float64) {
+	adamUpdate(param, gradient, state, rate, o.Beta1, o.Beta2, o.Epsilon, 0)
+}
+
+// AdamW is Adam with decoupled weight decay.
+//
+// The weight decay is applied directly to the parameters rather than being
+// added to the gradient, so it is not scaled by the second moment.
+type AdamW struct {
+	// Beta1 is the decay rate of the first moment.
+	Beta1 float64 `json:"b1"`
+
+	// Beta2 is the decay rate of the second moment.
+	Beta2 float64 `json:"b2"`
+
+	// Epsilon is added to the denominator to avoid division by zero.
+	Epsilon float64 `json:"e"`
+
+	// WeightDecay is the fraction of each parameter removed per update,
+	// scaled by the learning rate.
+	WeightDecay float64 `json:"w"`
+}
+
+// NewAdamW creates an AdamW optimizer with a Beta1 of 0.9, a Beta2 of 0.999
+// and an Epsilon of 1e-8.
+//
+// Parameters:
+// - weightDecay: The weight decay to apply.
+//
+// Returns:
+// - A pointer to the optimizer.
+func NewAdamW(weightDecay float64) *AdamW {
+	return &AdamW{
+		Beta1:       0.9,
+		Beta2:       0.999,
+		Epsilon:     1e-8,
+		WeightDecay: weightDecay,
+	}
+}
+
+// Name returns the name used to identify the optimizer in a saved network.
+func (o *AdamW) Name() string {
+	return "adamw"
+}
+
+// Update adjusts the values of param in place, stepping against gradient.
+//
+// Parameters:
+// - param: The weight or bias matrix to update.
+// - gradient: The gradient of the error with respect to param.
+// - state: The state kept by the optimizer for param.
+// - rate: The learning rate.
+func (o *AdamW) Update(param, gradient *Matrix, state *OptimizerState, rate float64) {
+	adamUpdate(param, gradient, state, rate, o.Beta1, o.Beta2, o.Epsilon, o.WeightDecay)
+}
+
+// adamUpdate performs the update shared by Adam and AdamW.
+//
+// Parameters:
+// - param: The weight or bias matrix to update.
+// - gradient: The gradient of the error with respect to param.
+// - state: The state kept by the optimizer for param.
+// - rate: The learning rate.
+// - beta1: The decay rate of the first moment.
+// - beta2: The decay rate of the second moment.
+// - eps: The value added to the denominator to avoid division by zero.
+// - decay: The decoupled weight decay, zero for Adam.
+func adamUpdate(param, gradient *Matrix, state *OptimizerState, rate, beta1, beta2, eps, decay float64) {
+	state.Step++
+	m := state.velocity(param)
+	v := state.squares(param)
+
+	// Correct the bias towards zero of the freshly initialised moments.
+	c1 := 1 - math.Pow(beta1, float64(state.Step))
+	c2 := 1 - math.Pow(beta2, float64(state.Step))
+	for i, g := range gradient.values {
+		m.values[i] = beta1*m.values[i] + (1-beta1)*g
+		v.values[i] = beta2*v.values[i] + (1-beta2)*g*g
+		mHat := m.values[i] / c1
+		vHat := v.values[i] / c2
+		param.values[i] -= rate * (mHat/(math.Sqrt(vHat)+eps) + decay*param.values[i])
+	}
+}
+
+// Adagrad scales the learning rate of each parameter by the accumulated
+// squares of all of its previous gradients.
+type Adagrad struct {
+	// Epsilon is added to the denominator to avoid division by zero.
+	Epsilon float64 `json:"e"`
+}
+
+// NewAdagrad creates an Adagrad optimizer with an Epsilon of 1e-8.
+//
+// Returns:
+// - A pointer to the optimizer.
+func NewAdagrad() *Adagrad {
+	return &Adagrad{
+		Epsilon: 1e-8,
+	}
+}
+
+// Name returns the name used to identify the optimizer in a saved network.
+func (o *Adagrad) Name() string {
+	return "adagrad"
+}
+
+// Update adjusts the values of param in place, stepping against gradient.
+//
+// Parameters:
+// - param: The weight or bias matrix to update.
+// - gradient: The gradient of the error with respect to param.
+// - state: The state kept by the optimizer for param.
+// - rate: The learning rate.
+func (o *Adagrad) Update(param, gradient *Matrix, state *OptimizerState, rate float64) {
+	state.Step++
+	s := state.squares(param)
+	for i, g := range gradient.values {
+		s.values[i] += g * g
+		param.values[i] -= rate * g / (math.Sqrt(s.values[i]) + o.Epsilon)
+	}
+}
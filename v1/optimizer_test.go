@@ -0,0 +1,74 @@
+package jasper
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestOptimizerJSON(t *testing.T) {
+	tests := []Optimizer{
+		NewSGD(0.9, true),
+		NewRMSProp(),
+		NewAdam(),
+		NewAdamW(0.01),
+		NewAdagrad(),
+	}
+	for _, o := range tests {
+		t.Run(o.Name(), func(t *testing.T) {
+			body, err := marshalOptimizer(o)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := unmarshalOptimizer(body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, o) {
+				t.Errorf("got %+v, want %+v", got, o)
+			}
+		})
+	}
+}
+
+func TestOptimizerJSONUnknown(t *testing.T) {
+	if _, err := unmarshalOptimizer([]byte(`{"n":"nadam","p":{}}`)); err == nil {
+		t.Error("expected an error")
+	}
+}
+
+// halvingStep is an optimizer that is not built in, which steps half as far
+// as plain gradient descent.
+type halvingStep struct{}
+
+// Name returns the name of the optimizer.
+func (halvingStep) Name() string {
+	return "sgd"
+}
+
+// Update steps against half the gradient.
+func (halvingStep) Update(param, gradient *Matrix, state *OptimizerState, rate float64) {
+	for k, g := range gradient.values {
+		param.values[k] -= rate * g / 2
+	}
+}
+
+func TestOptimizerNotBuiltIn(t *testing.T) {
+	c := NewConfig([]uint32{1, 2, 1})
+	c.Optimizer = halvingStep{}
+	c.Quiet = true
+	n, err := New(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rows := []*DataRow{{Input: []float64{0}, Ouput: []float64{0}}, {Input: []float64{1}, Ouput: []float64{1}}}
+	if _, err := n.Train(&TrainingData{TrainingSource: NewSliceSource(rows), Iterations: 2}); err != nil {
+		t.Fatalf("training: %v", err)
+	}
+	if _, err := n.MarshalJSON(); err == nil {
+		t.Error("saving: expected an error")
+	}
+	td := &TrainingData{TrainingSource: NewSliceSource(rows), Iterations: 2, Checkpoint: NewCheckpoint(t.TempDir(), 1, 1)}
+	if _, err := n.Train(td); err == nil {
+		t.Error("checkpointing: expected an error")
+	}
+}
@@ -0,0 +1,853 @@
+// preprocessing.go - Preprocessing of the inputs and outputs of the neural network.
+//
+// # Copyright 2024 Mark Oxley
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package jasper
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+)
+
+// Preprocessor is the interface implemented by the steps that transform the
+// inputs or outputs of the network.
+//
+// A preprocessor is fitted once, from the training rows, and then transforms
+// every row fed to the network. Steps are applied in order, so the columns of
+// each step refer to the values produced by the step before.
+type Preprocessor interface {
+	// Name returns the name used to identify the preprocessor in a saved network.
+	Name() string
+
+	// Fitted returns true once the preprocessor has been fitted.
+	Fitted() bool
+
+	// Fit learns the parameters of the preprocessor from a set of rows.
+	//
+	// Parameters:
+	// - rows: The values of each row.
+	//
+	// Returns:
+	// - An error if the preprocessor cannot be fitted to the rows.
+	Fit(rows [][]float64) error
+
+	// Transform transforms the values of a row.
+	//
+	// Parameters:
+	// - vs: The values of the row.
+	//
+	// Returns:
+	// - The transformed values.
+	// - An error if the values cannot be transformed.
+	Transform(vs []float64) ([]float64, error)
+
+	// Inverse reverses Transform.
+	//
+	// Parameters:
+	// - vs: The transformed values.
+	//
+	// Returns:
+	// - The values before they were transformed.
+	// - An error if the values cannot be transformed.
+	Inverse(vs []float64) ([]float64, error)
+}
+
+// getPreprocessor returns an empty instance of the preprocessor with the given name.
+//
+// Parameters:
+// - name: The name of the preprocessor.
+//
+// Returns:
+// - Preprocessor: An instance of the preprocessor, or nil if the name is unknown.
+func getPreprocessor(name string) Preprocessor {
+	switch name {
+	case "minmax":
+		return &MinMaxScaler{}
+	case "standard":
+		return &StandardScaler{}
+	case "robust":
+		return &RobustScaler{}
+	case "log":
+		return &LogTransform{}
+	case "onehot":
+		return &OneHotEncoder{}
+	case "label":
+		return &LabelEncoder{}
+	}
+	return nil
+}
+
+// marshalPreprocessors marshals a set of preprocessors and their fitted
+// parameters into JSON.
+//
+// Parameters:
+// - ps: The preprocessors to marshal.
+//
+// Returns:
+// - A JSON byte slice holding the name and content of each preprocessor.
+// - An error if a preprocessor is not built in or cannot be marshaled.
+func marshalPreprocessors(ps []Preprocessor) ([]byte, error) {
+	type entry struct {
+		Name   string          `json:"n"`
+		Params json.RawMessage `json:"p"`
+	}
+	res := make([]entry, len(ps))
+	for i, p := range ps {
+		if getPreprocessor(p.Name()) == nil {
+			return nil, fmt.Errorf("cannot save preprocessor of type %T", p)
+		}
+		params, err := json.Marshal(p)
+		if err != nil {
+			return nil, err
+		}
+		res[i] = entry{Name: p.Name(), Params: params}
+	}
+	return json.Marshal(res)
+}
+
+// unmarshalPreprocessors unmarshals preprocessors written by marshalPreprocessors.
+//
+// Parameters:
+// - body: The JSON byte slice to unmarshal.
+//
+// Returns:
+// - The preprocessors.
+// - An error if a preprocessor is unknown or cannot be unmarshaled.
+func unmarshalPreprocessors(body []byte) ([]Preprocessor, error) {
+	var data []struct {
+		Name   string          `json:"n"`
+		Params json.RawMessage `json:"p"`
+	}
+	if err := json.Unmarshal(body, &data); err != nil {
+		return nil, err
+	}
+	res := make([]Preprocessor, len(data))
+	for i, d := range data {
+		res[i] = getPreprocessor(d.Name)
+		if res[i] == nil {
+			return nil, fmt.Errorf("unknown preprocessor: %v", d.Name)
+		}
+		if err := json.Unmarshal(d.Params, res[i]); err != nil {
+			return nil, err
+		}
+	}
+	return res, nil
+}
+
+// copyPreprocessors returns copies of a set of preprocessors. Preprocessors
+// that are not built in cannot be copied and are shared.
+//
+// Parameters:
+// - ps: The preprocessors to copy.
+//
+// Returns:
+// - The copies.
+// - An error if a preprocessor cannot be copied.
+func copyPreprocessors(ps []Preprocessor) ([]Preprocessor, error) {
+	res := make([]Preprocessor, len(ps))
+	for i, p := range ps {
+		res[i] = p
+		if getPreprocessor(p.Name()) == nil {
+			continue
+		}
+		body, err := marshalPreprocessors([]Preprocessor{p})
+		if err != nil {
+			return nil, err
+		}
+		c, err := unmarshalPreprocessors(body)
+		if err != nil {
+			return nil, err
+		}
+		res[i] = c[0]
+	}
+	return res, nil
+}
+
+// pipeline is a set of preprocessors applied in order.
+type pipeline []Preprocessor
+
+// fitted returns true if every step of the pipeline has been fitted.
+func (p pipeline) fitted() bool {
+	for _, s := range p {
+		if !s.Fitted() {
+			return false
+		}
+	}
+	return true
+}
+
+// fit fits each step of the pipeline that has not been fitted, from the rows
+// as transformed by the steps before it.
+//
+// Parameters:
+// - rows: The values of each row.
+//
+// Returns:
+// - An error if a step cannot be fitted or applied.
+func (p pipeline) fit(rows [][]float64) error {
+	for i, s := range p {
+		if !s.Fitted() {
+			if err := s.Fit(rows); err != nil {
+				return fmt.Errorf("preprocessor %v: %v", i, err)
+			}
+		}
+		if i == len(p)-1 {
+			break
+		}
+		next := make([][]float64, len(rows))
+		for r, vs := range rows {
+			var err error
+			if next[r], err = s.Transform(vs); err != nil {
+				return fmt.Errorf("preprocessor %v: %v", i, err)
+			}
+		}
+		rows = next
+	}
+	return nil
+}
+
+// transform applies each step of the pipeline in order.
+//
+// Parameters:
+// - vs: The values to transform.
+//
+// Returns:
+// - The transformed values.
+// - An error if a step cannot be applied.
+func (p pipeline) transform(vs []float64) ([]float64, error) {
+	for i, s := range p {
+		var err error
+		if vs, err = s.Transform(vs); err != nil {
+			return nil, fmt.Errorf("preprocessor %v: %v", i, err)
+		}
+	}
+	return vs, nil
+}
+
+// inverse reverses each step of the pipeline in reverse order.
+//
+// Parameters:
+// - vs: The transformed values.
+//
+// Returns:
+// - The values before they were transformed.
+// - An error if a step cannot be reversed.
+func (p pipeline) inverse(vs []float64) ([]float64, error) {
+	for i := len(p) - 1; i >= 0; i-- {
+		var err error
+		if vs, err = p[i].Inverse(vs); err != nil {
+			return nil, fmt.Errorf("preprocessor %v: %v", i, err)
+		}
+	}
+	return vs, nil
+}
+
+// scaling maps selected columns with (v - Offset) / Scale. It holds the
+// fitted parameters shared by the scalers.
+type scaling struct {
+	// Columns holds the indices of the columns to scale. If nil, every
+	// column is scaled.
+	Columns []int `json:"c,omitempty"`
+
+	// Offset holds the fitted offset of each column.
+	Offset []float64 `json:"o,omitempty"`
+
+	// Scale holds the fitted scale of each column.
+	Scale []float64 `json:"s,omitempty"`
+}
+
+// Fitted returns true once the scaler has been fitted.
+func (s *scaling) Fitted() bool {
+	return s.Scale != nil
+}
+
+// fit fits the scaler by calculating the offset and scale of each selected
+// column from its values.
+//
+// Parameters:
+// - rows: The values of each row.
+// - f: A function returning the offset and scale for the values of a column.
+//
+// Returns:
+// - An error if there are no rows or a column is out of range.
+func (s *scaling) fit(rows [][]float64, f func(vs []float64) (float64, float64)) error {
+	if len(rows) == 0 {
+		return errors.New("no rows to fit")
+	}
+	width := len(rows[0])
+	offset := make([]float64, width)
+	scale := make([]float64, width)
+	for c := range scale {
+		scale[c] = 1
+	}
+	for _, c := range s.columns(width) {
+		if c < 0 || c >= width {
+			return fmt.Errorf("column %v out of range", c)
+		}
+		vs := make([]float64, len(rows))
+		for r, row := range rows {
+			vs[r] = row[c]
+		}
+		offset[c], scale[c] = f(vs)
+		// Leave constant columns unscaled rather than dividing by zero.
+		if scale[c] == 0 || math.IsNaN(scale[c]) {
+			scale[c] = 1
+		}
+	}
+	s.Offset, s.Scale = offset, scale
+	return nil
+}
+
+// columns returns the indices of the selected columns.
+//
+// Parameters:
+// - width: The number of values in each row.
+//
+// Returns:
+// - The indices of the columns.
+func (s *scaling) columns(width int) []int {
+	if s.Columns != nil {
+		return s.Columns
+	}
+	res := make([]int, width)
+	for i := range res {
+		res[i] = i
+	}
+	return res
+}
+
+// Transform scales the values of a row.
+//
+// Parameters:
+// - vs: The values of the row.
+//
+// Returns:
+// - The scaled values.
+// - An error if the scaler has not been fitted or the row is the wrong size.
+func (s *scaling) Transform(vs []float64) ([]float64, error) {
+	if len(vs) != len(s.Scale) {
+		return nil, fmt.Errorf("scaler fitted to %v values, %v given", len(s.Scale), len(vs))
+	}
+	res := make([]float64, len(vs))
+	for i, v := range vs {
+		res[i] = (v - s.Offset[i]) / s.Scale[i]
+	}
+	return res, nil
+}
+
+// Inverse reverses the scaling of the values of a row.
+//
+// Parameters:
+// - vs: The scaled values.
+//
+// Returns:
+// - The values before they were scaled.
+// - An error if the scaler has not been fitted or the row is the wrong size.
+func (s *scaling) Inverse(vs []float64) ([]float64, error) {
+	if len(vs) != len(s.Scale) {
+		return nil, fmt.Errorf("scaler fitted to %v values, %v given", len(s.Scale), len(vs))
+	}
+	res := make([]float64, len(vs))
+	for i, v := range vs {
+		res[i] = v*s.Scale[i] + s.Offset[i]
+	}
+	return res, nil
+}
+
+// MinMaxScaler scales columns linearly so that the smallest and largest
+// values of the fitted rows become Min and Max.
+type MinMaxScaler struct {
+	scaling
+
+	// Min is the value the smallest value is scaled to.
+	Min float64 `json:"mn"`
+
+	// Max is the value the largest value is scaled to.
+	Max float64 `json:"mx"`
+}
+
+// NewMinMaxScaler creates a scaler that maps columns to the range 0 to 1.
+//
+// Parameters:
+// - columns: The indices of the columns to scale, or none to scale every column.
+//
+// Returns:
+// - A pointer to the scaler.
+func NewMinMaxScaler(columns ...int) *MinMaxScaler {
+	return &MinMaxScaler{
+		scaling: scaling{Columns: columns},
+		Max:     1,
+	}
+}
+
+// Name returns the name used to identify the scaler in a saved network.
+func (s *MinMaxScaler) Name() string {
+	return "minmax"
+}
+
+// Fit finds the smallest and largest value of each column.
+//
+// Parameters:
+// - rows: The values of each row.
+//
+// Returns:
+// - An error if there are no rows, a column is out of range or Min equals Max.
+func (s *MinMaxScaler) Fit(rows [][]float64) error {
+	if s.Min == s.Max {
+		return errors.New("min and max of the range are equal")
+	}
+	return s.fit(rows, func(vs []float64) (float64, float64) {
+		lo, hi := vs[0], vs[0]
+		for _, v := range vs {
+			lo = math.Min(lo, v)
+			hi = math.Max(hi, v)
+		}
+		scale := (hi - lo) / (s.Max - s.Min)
+		return lo - s.Min*scale, scale
+	})
+}
+
+// StandardScaler standardizes columns to a mean of zero and a standard
+// deviation of one, also known as the z-score.
+type StandardScaler struct {
+	scaling
+}
+
+// NewStandardScaler creates a z-score scaler.
+//
+// Parameters:
+// - columns: The indices of the columns to scale, or none to scale every column.
+//
+// Returns:
+// - A pointer to the scaler.
+func NewStandardScaler(columns ...int) *StandardScaler {
+	return &StandardScaler{scaling{Columns: columns}}
+}
+
+// Name returns the name used to identify the scaler in a saved network.
+func (s *StandardScaler) Name() string {
+	return "standard"
+}
+
+// Fit finds the mean and standard deviation of each column.
+//
+// Parameters:
+// - rows: The values of each row.
+//
+// Returns:
+// - An error if there are no rows or a column is out of range.
+func (s *StandardScaler) Fit(rows [][]float64) error {
+	return s.fit(rows, func(vs []float64) (float64, float64) {
+		var mean, sq float64
+		for _, v := range vs {
+			mean += v
+		}
+		mean /= float64(len(vs))
+		for _, v := range vs {
+			sq += (v - mean) * (v - mean)
+		}
+		return mean, math.Sqrt(sq / float64(len(vs)))
+	})
+}
+
+// RobustScaler scales columns by subtracting the median and dividing by the
+// interquartile range, which limits the effect of outliers.
+type RobustScaler struct {
+	scaling
+}
+
+// NewRobustScaler creates a robust scaler.
+//
+// Parameters:
+// - columns: The indices of the columns to scale, or none to scale every column.
+//
+// Returns:
+// - A pointer to the scaler.
+func NewRobustScaler(columns ...int) *RobustScaler {
+	return &RobustScaler{scaling{Columns: columns}}
+}
+
+// Name returns the name used to identify the scaler in a saved network.
+func (s *RobustScaler) Name() string {
+	return "robust"
+}
+
+// Fit finds the median and interquartile range of each column.
+//
+// Parameters:
+// - rows: The values of each row.
+//
+// Returns:
+// - An error if there are no rows or a column is out of range.
+func (s *RobustScaler) Fit(rows [][]float64) error {
+	return s.fit(rows, func(vs []float64) (float64, float64) {
+		sort.Float64s(vs)
+		return quantile(vs, 0.5), quantile(vs, 0.75) - quantile(vs, 0.25)
+	})
+}
+
+// quantile returns a quantile of sorted values, interpolating linearly
+// between the nearest values.
+//
+// Parameters:
+// - sorted: The values, in ascending order.
+// - q: The quantile, between 0 and 1.
+//
+// Returns:
+// - The quantile, or NaN if there are no values.
+func quantile(sorted []float64, q float64) float64 {
+	if len(sorted) == 0 {
+		return math.NaN()
+	}
+	pos := q * float64(len(sorted)-1)
+	lo := int(math.Floor(pos))
+	hi := int(math.Ceil(pos))
+	return sorted[lo] + (sorted[hi]-sorted[lo])*(pos-float64(lo))
+}
+
+// LogTransform replaces the values of columns with their natural logarithm,
+// after adding Offset. It needs no fitting.
+type LogTransform struct {
+	// Columns holds the indices of the columns to transform. If nil, every
+	// column is transformed.
+	Columns []int `json:"c,omitempty"`
+
+	// Offset is added to each value before the logarithm is taken, so that
+	// zero values can be transformed with an offset of one.
+	Offset float64 `json:"o"`
+}
+
+// NewLogTransform creates a log transform.
+//
+// Parameters:
+// - offset: The value added before the logarithm is taken.
+// - columns: The indices of the columns to transform, or none to transform every column.
+//
+// Returns:
+// - A pointer to the transform.
+func NewLogTransform(offset float64, columns ...int) *LogTransform {
+	return &LogTransform{
+		Columns: columns,
+		Offset:  offset,
+	}
+}
+
+// Name returns the name used to identify the transform in a saved network.
+func (t *LogTransform) Name() string {
+	return "log"
+}
+
+// Fitted returns true, as the transform needs no fitting.
+func (t *LogTransform) Fitted() bool {
+	return true
+}
+
+// Fit does nothing, as the transform needs no fitting.
+//
+// Parameters:
+// - rows: The values of each row.
+//
+// Returns:
+// - nil.
+func (t *LogTransform) Fit(rows [][]float64) error {
+	return nil
+}
+
+// Transform takes the logarithm of the selected values of a row.
+//
+// Parameters:
+// - vs: The values of the row.
+//
+// Returns:
+// - The transformed values.
+// - An error if a value plus the offset is not positive.
+func (t *LogTransform) Transform(vs []float64) ([]float64, error) {
+	return t.apply(vs, func(v float64) (float64, error) {
+		if v+t.Offset <= 0 {
+			return 0, fmt.Errorf("cannot take the logarithm of %v", v+t.Offset)
+		}
+		return math.Log(v + t.Offset), nil
+	})
+}
+
+// Inverse reverses the logarithm of the selected values of a row.
+//
+// Parameters:
+// - vs: The transformed values.
+//
+// Returns:
+// - The values before they were transformed.
+// - An error if a column is out of range.
+func (t *LogTransform) Inverse(vs []float64) ([]float64, error) {
+	return t.apply(vs, func(v float64) (float64, error) {
+		return math.Exp(v) - t.Offset, nil
+	})
+}
+
+// apply applies a function to the selected values of a row.
+//
+// Parameters:
+// - vs: The values of the row.
+// - f: The function to apply.
+//
+// Returns:
+// - The values with the function applied to the selected columns.
+// - An error if a column is out of range or the function fails.
+func (t *LogTransform) apply(vs []float64, f func(v float64) (float64, error)) ([]float64, error) {
+	res := append([]float64{}, vs...)
+	columns := (&scaling{Columns: t.Columns}).columns(len(vs))
+	for _, c := range columns {
+		if c < 0 || c >= len(vs) {
+			return nil, fmt.Errorf("column %v out of range", c)
+		}
+		var err error
+		if res[c], err = f(vs[c]); err != nil {
+			return nil, err
+		}
+	}
+	return res, nil
+}
+
+// categories returns the distinct values of a column in ascending order.
+//
+// Parameters:
+// - rows: The values of each row.
+// - column: The index of the column.
+//
+// Returns:
+// - The distinct values.
+// - An error if there are no rows or the column is out of range.
+func categories(rows [][]float64, column int) ([]float64, error) {
+	if len(rows) == 0 {
+		return nil, errors.New("no rows to fit")
+	}
+	seen := make(map[float64]bool)
+	var res []float64
+	for _, row := range rows {
+		if column < 0 || column >= len(row) {
+			return nil, fmt.Errorf("column %v out of range", column)
+		}
+		if !seen[row[column]] {
+			seen[row[column]] = true
+			res = append(res, row[column])
+		}
+	}
+	sort.Float64s(res)
+	return res, nil
+}
+
+// categoryIndex returns the index of a value in a set of categories.
+//
+// Parameters:
+// - categories: The categories.
+// - v: The value.
+//
+// Returns:
+// - The index of the value.
+// - An error if the value is not one of the categories.
+func categoryIndex(categories []float64, v float64) (int, error) {
+	for i, c := range categories {
+		if c == v {
+			return i, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown category %v", v)
+}
+
+// OneHotEncoder replaces a column holding category values with a one hot
+// vector, with an element for each category, in place.
+type OneHotEncoder struct {
+	// Column is the index of the column to encode.
+	Column int `json:"c"`
+
+	// Categories holds the category values in the order of the elements of the
+	// one hot vector. If nil, they are fitted from the distinct values of the
+	// column in ascending order.
+	Categories []float64 `json:"v,omitempty"`
+}
+
+// NewOneHotEncoder creates a one hot encoder.
+//
+// Parameters:
+// - column: The index of the column to encode.
+//
+// Returns:
+// - A pointer to the encoder.
+func NewOneHotEncoder(column int) *OneHotEncoder {
+	return &OneHotEncoder{Column: column}
+}
+
+// Name returns the name used to identify the encoder in a saved network.
+func (e *OneHotEncoder) Name() string {
+	return "onehot"
+}
+
+// Fitted returns true once the categories are known.
+func (e *OneHotEncoder) Fitted() bool {
+	return e.Categories != nil
+}
+
+// Fit finds the distinct values of the column.
+//
+// Parameters:
+// - rows: The values of each row.
+//
+// Returns:
+// - An error if there are no rows or the column is out of range.
+func (e *OneHotEncoder) Fit(rows [][]float64) (err error) {
+	e.Categories, err = categories(rows, e.Column)
+	return err
+}
+
+// Transform replaces the column of a row with its one hot vector.
+//
+// Parameters:
+// - vs: The values of the row.
+//
+// Returns:
+// - The transformed values.
+// - An error if the column is out of range or holds an unknown category.
+func (e *OneHotEncoder) Transform(vs []float64) ([]float64, error) {
+	if e.Column < 0 || e.Column >= len(vs) {
+		return nil, fmt.Errorf("column %v out of range", e.Column)
+	}
+	idx, err := categoryIndex(e.Categories, vs[e.Column])
+	if err != nil {
+		return nil, err
+	}
+	res := make([]float64, 0, len(vs)+len(e.Categories)-1)
+	res = append(res, vs[:e.Column]...)
+	for i := range e.Categories {
+		if i == idx {
+			res = append(res, 1)
+		} else {
+			res = append(res, 0)
+		}
+	}
+	return append(res, vs[e.Column+1:]...), nil
+}
+
+// Inverse replaces the one hot vector of a row with the category of its
+// largest element.
+//
+// Parameters:
+// - vs: The transformed values.
+//
+// Returns:
+// - The values before they were transformed.
+// - An error if the encoder has not been fitted or the row is too short.
+func (e *OneHotEncoder) Inverse(vs []float64) ([]float64, error) {
+	if !e.Fitted() || len(e.Categories) == 0 {
+		return nil, errors.New("one hot encoder has not been fitted")
+	}
+	end := e.Column + len(e.Categories)
+	if e.Column < 0 || end > len(vs) {
+		return nil, fmt.Errorf("column %v out of range", e.Column)
+	}
+	res := make([]float64, 0, len(vs)-len(e.Categories)+1)
+	res = append(res, vs[:e.Column]...)
+	res = append(res, e.Categories[argmax(vs[e.Column:end])])
+	return append(res, vs[end:]...), nil
+}
+
+// LabelEncoder replaces the category values of a column with the index of
+// the category, so arbitrary codes become 0, 1, 2 and so on.
+type LabelEncoder struct {
+	// Column is the index of the column to encode.
+	Column int `json:"c"`
+
+	// Labels holds the category values in the order of their indices. If nil,
+	// they are fitted from the distinct values of the column in ascending order.
+	Labels []float64 `json:"v,omitempty"`
+}
+
+// NewLabelEncoder creates a label encoder.
+//
+// Parameters:
+// - column: The index of the column to encode.
+//
+// Returns:
+// - A pointer to the encoder.
+func NewLabelEncoder(column int) *LabelEncoder {
+	return &LabelEncoder{Column: column}
+}
+
+// Name returns the name used to identify the encoder in a saved network.
+func (e *LabelEncoder) Name() string {
+	return "label"
+}
+
+// Fitted returns true once the labels are known.
+func (e *LabelEncoder) Fitted() bool {
+	return e.Labels != nil
+}
+
+// Fit finds the distinct values of the column.
+//
+// Parameters:
+// - rows: The values of each row.
+//
+// Returns:
+// - An error if there are no rows or the column is out of range.
+func (e *LabelEncoder) Fit(rows [][]float64) (err error) {
+	e.Labels, err = categories(rows, e.Column)
+	return err
+}
+
+// Transform replaces the column of a row with the index of its label.
+//
+// Parameters:
+// - vs: The values of the row.
+//
+// Returns:
+// - The transformed values.
+// - An error if the column is out of range or holds an unknown label.
+func (e *LabelEncoder) Transform(vs []float64) ([]float64, error) {
+	if e.Column < 0 || e.Column >= len(vs) {
+		return nil, fmt.Errorf("column %v out of range", e.Column)
+	}
+	idx, err := categoryIndex(e.Labels, vs[e.Column])
+	if err != nil {
+		return nil, err
+	}
+	res := append([]float64{}, vs...)
+	res[e.Column] = float64(idx)
+	return res, nil
+}
+
+// Inverse replaces the index in the column of a row with its label, rounding
+// the index to the nearest label.
+//
+// Parameters:
+// - vs: The transformed values.
+//
+// Returns:
+// - The values before they were transformed.
+// - An error if the encoder has not been fitted or the column is out of range.
+func (e *LabelEncoder) Inverse(vs []float64) ([]float64, error) {
+	if !e.Fitted() || len(e.Labels) == 0 {
+		return nil, errors.New("label encoder has not been fitted")
+	}
+	if e.Column < 0 || e.Column >= len(vs) {
+		return nil, fmt.Errorf("column %v out of range", e.Column)
+	}
+	idx := int(math.Round(vs[e.Column]))
+	idx = min(max(idx, 0), len(e.Labels)-1)
+	res := append([]float64{}, vs...)
+	res[e.Column] = e.Labels[idx]
+	return res, nil
+}
@@ -0,0 +1,134 @@
+package jasper
+
+import (
+	"math"
+	"reflect"
+	"testing"
+)
+
+// tolerance is the largest difference accepted from an expected value.
+const tolerance = 1e-4
+
+// near reports whether a value is within tolerance of the expected value.
+func near(got, want float64) bool {
+	return math.Abs(got-want) <= tolerance
+}
+
+// preprocessingRows returns the rows the preprocessors are fitted to. The
+// last column holds categories.
+func preprocessingRows() [][]float64 {
+	return [][]float64{
+		{1, 10, 0.5, 2},
+		{2, 20, 1.5, 0},
+		{3, 40, 2.5, 1},
+		{4, 80, 3.5, 2},
+		{10, 160, 4.5, 1},
+	}
+}
+
+func TestPreprocessorJSON(t *testing.T) {
+	tests := []Preprocessor{
+		NewMinMaxScaler(),
+		NewStandardScaler(0, 2),
+		NewRobustScaler(1),
+		NewLogTransform(1, 1),
+		NewOneHotEncoder(3),
+		NewLabelEncoder(3),
+	}
+	for _, p := range tests {
+		t.Run(p.Name(), func(t *testing.T) {
+			if err := p.Fit(preprocessingRows()); err != nil {
+				t.Fatal(err)
+			}
+			body, err := marshalPreprocessors([]Preprocessor{p})
+			if err != nil {
+				t.Fatal(err)
+			}
+			ps, err := unmarshalPreprocessors(body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(ps) != 1 || !ps[0].Fitted() {
+				t.Fatalf("got %+v, want one fitted preprocessor", ps)
+			}
+			if !reflect.DeepEqual(ps[0], p) {
+				t.Errorf("got %+v, want %+v", ps[0], p)
+			}
+			for _, row := range preprocessingRows() {
+				want, err := p.Transform(row)
+				if err != nil {
+					t.Fatal(err)
+				}
+				got, err := ps[0].Transform(row)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !reflect.DeepEqual(got, want) {
+					t.Fatalf("transform %v = %v, want %v", row, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestPreprocessorInverse(t *testing.T) {
+	tests := []Preprocessor{
+		NewMinMaxScaler(),
+		NewStandardScaler(),
+		NewRobustScaler(),
+		NewLogTransform(1),
+		NewOneHotEncoder(3),
+		NewLabelEncoder(3),
+	}
+	for _, p := range tests {
+		t.Run(p.Name(), func(t *testing.T) {
+			if err := p.Fit(preprocessingRows()); err != nil {
+				t.Fatal(err)
+			}
+			for _, row := range preprocessingRows() {
+				vs, err := p.Transform(row)
+				if err != nil {
+					t.Fatal(err)
+				}
+				got, err := p.Inverse(vs)
+				if err != nil {
+					t.Fatal(err)
+				}
+				for k := range row {
+					if !near(got[k], row[k]) {
+						t.Fatalf("inverse of %v = %v", row, got)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestPreprocessorUnfitted(t *testing.T) {
+	tests := []Preprocessor{
+		NewMinMaxScaler(),
+		NewStandardScaler(),
+		NewRobustScaler(),
+		NewOneHotEncoder(0),
+		NewLabelEncoder(0),
+	}
+	for _, p := range tests {
+		t.Run(p.Name(), func(t *testing.T) {
+			if p.Fitted() {
+				t.Fatal("fitted before Fit")
+			}
+			if _, err := p.Transform([]float64{1}); err == nil {
+				t.Error("transform: expected an error")
+			}
+			if _, err := p.Inverse([]float64{1}); err == nil {
+				t.Error("inverse: expected an error")
+			}
+		})
+	}
+}
+
+func TestPreprocessorJSONUnknown(t *testing.T) {
+	if _, err := unmarshalPreprocessors([]byte(`[{"n":"pca","p":{}}]`)); err == nil {
+		t.Error("expected an error")
+	}
+}
@@ -0,0 +1,91 @@
+// random.go - Seedable random number generation used in the neural network.
+//
+// # Copyright 2024 Mark Oxley
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package jasper
+
+import (
+	"math/rand/v2"
+)
+
+// random is a random number generator created from a seed or a source.
+type random struct {
+	// seed is the seed of the generator, or zero if it was created from a source.
+	seed uint64
+
+	// pcg is the source of a seeded generator, kept so that its state can be
+	// saved. It is nil if the generator was created from a source.
+	pcg *rand.PCG
+
+	// rng is the generator.
+	rng *rand.Rand
+}
+
+// newRandom creates a random number generator.
+//
+// If a source is given it is used. Otherwise the generator is seeded with the
+// given seed, or with a randomly chosen seed if it is zero, so every
+// generator created without a source can be reproduced from its seed.
+//
+// Parameters:
+// - seed: The seed, or zero to choose one at random.
+// - src: The source to use, or nil to use the seed.
+//
+// Returns:
+// - A pointer to the generator.
+func newRandom(seed uint64, src rand.Source) *random {
+	if src != nil {
+		return &random{rng: rand.New(src)}
+	}
+	for seed == 0 {
+		seed = rand.Uint64()
+	}
+	pcg := rand.NewPCG(seed, seed)
+	return &random{
+		seed: seed,
+		pcg:  pcg,
+		rng:  rand.New(pcg),
+	}
+}
+
+// state returns the saved state of a seeded generator.
+//
+// Returns:
+// - The state, or nil if the generator was created from a source.
+// - An error if the state cannot be saved.
+func (r *random) state() ([]byte, error) {
+	if r.pcg == nil {
+		return nil, nil
+	}
+	return r.pcg.MarshalBinary()
+}
+
+// restoreRandom creates a generator from a saved seed and state.
+//
+// Parameters:
+// - seed: The seed of the generator.
+// - state: The state returned by state, or nil to start from the seed.
+//
+// Returns:
+// - A pointer to the generator.
+// - An error if the state cannot be restored.
+func restoreRandom(seed uint64, state []byte) (*random, error) {
+	r := newRandom(seed, nil)
+	if len(state) > 0 {
+		if err := r.pcg.UnmarshalBinary(state); err != nil {
+			return nil, err
+		}
+	}
+	return r, nil
+}
@@ -0,0 +1,116 @@
+package jasper
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+// seededNetwork creates a network using every random setting, seeded with
+// the given seed.
+func seededNetwork(t *testing.T, seed uint64) *Network {
+	t.Helper()
+	c := NewConfig([]uint32{2, 6, 1})
+	c.Seed = seed
+	c.Quiet = true
+	c.Dropout = []float64{0.2}
+	n, err := New(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return n
+}
+
+// seededTraining trains a network on rows shuffled by its own generator,
+// returning its history and the saved network.
+func seededTraining(t *testing.T, n *Network) ([]EpochRecord, string) {
+	t.Helper()
+	td := NewTrainingData(20, 0.75, 0)
+	td.Shuffle = true
+	td.BatchSize = 3
+	for i := range 20 {
+		td.AddRow([]float64{float64(i % 2), float64(i % 3)}, []float64{float64(i % 2)})
+	}
+	h, err := n.Train(td)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, err := json.Marshal(n)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return withoutDurations(h), string(body)
+}
+
+func TestSeedReproducesTraining(t *testing.T) {
+	a, b := seededNetwork(t, 42), seededNetwork(t, 42)
+	aJSON, _ := json.Marshal(a)
+	bJSON, _ := json.Marshal(b)
+	if string(aJSON) != string(bJSON) {
+		t.Fatal("networks with the same seed start with different weights")
+	}
+	aHistory, aJSON2 := seededTraining(t, a)
+	bHistory, bJSON2 := seededTraining(t, b)
+	if !reflect.DeepEqual(aHistory, bHistory) || aJSON2 != bJSON2 {
+		t.Error("networks with the same seed train differently")
+	}
+
+	// A different seed gives different weights.
+	cJSON, _ := json.Marshal(seededNetwork(t, 43))
+	if string(cJSON) == string(aJSON) {
+		t.Error("networks with different seeds start with the same weights")
+	}
+}
+
+func TestSeedChosenAtRandom(t *testing.T) {
+	n := seededNetwork(t, 0)
+	if n.Seed() == 0 {
+		t.Fatal("no seed was chosen")
+	}
+	want, _ := json.Marshal(n)
+	got, _ := json.Marshal(seededNetwork(t, n.Seed()))
+	if string(got) != string(want) {
+		t.Error("the chosen seed does not reproduce the network")
+	}
+}
+
+func TestSeedSavedWithNetwork(t *testing.T) {
+	// A loaded network continues from the state of the saved generator.
+	n := seededNetwork(t, 7)
+	seededTraining(t, n)
+	body, err := json.Marshal(n)
+	if err != nil {
+		t.Fatal(err)
+	}
+	loaded := &Network{}
+	if err := json.Unmarshal(body, loaded); err != nil {
+		t.Fatal(err)
+	}
+	loaded.SetDebug(false)
+	if loaded.Seed() != 7 {
+		t.Errorf("seed = %v, want 7", loaded.Seed())
+	}
+	wantHistory, want := seededTraining(t, n)
+	gotHistory, got := seededTraining(t, loaded)
+	if !reflect.DeepEqual(gotHistory, wantHistory) || got != want {
+		t.Error("the loaded network trains differently")
+	}
+}
+
+func TestRestoreRandom(t *testing.T) {
+	r := newRandom(5, nil)
+	r.rng.Uint64()
+	state, err := r.state()
+	if err != nil {
+		t.Fatal(err)
+	}
+	restored, err := restoreRandom(5, state)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for range 3 {
+		if got, want := restored.rng.Uint64(), r.rng.Uint64(); got != want {
+			t.Fatalf("restored generator gave %v, want %v", got, want)
+		}
+	}
+}
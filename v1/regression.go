@@ -0,0 +1,273 @@
+// regression.go - Regression metrics and residual analysis used to evaluate the neural network.
+//
+// # Copyright 2024 Mark Oxley
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package jasper
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"text/tabwriter"
+)
+
+// RegressionMetrics holds the regression metrics of a network's predictions.
+type RegressionMetrics struct {
+	// RMSE is the root of the mean squared residual.
+	RMSE float64
+
+	// MAE is the mean absolute residual.
+	MAE float64
+
+	// MAPE is the mean absolute residual as a proportion of the actual value.
+	// Rows with an actual value of zero are left out. It is NaN if every
+	// actual value is zero.
+	MAPE float64
+
+	// R2 is the coefficient of determination, the proportion of the variance
+	// of the actual values explained by the predictions. A perfect prediction
+	// scores 1, and predicting the mean of the actual values scores 0.
+	R2 float64
+
+	// ExplainedVariance is one minus the variance of the residuals as a
+	// proportion of the variance of the actual values. It differs from R2 when
+	// the predictions are biased.
+	ExplainedVariance float64
+
+	// MaxError is the largest absolute residual.
+	MaxError float64
+}
+
+// ResidualStats holds a summary of the residuals of an output, the actual
+// values less the predicted values.
+type ResidualStats struct {
+	// Mean is the mean residual, which is not zero if the predictions are biased.
+	Mean float64
+
+	// StdDev is the standard deviation of the residuals.
+	StdDev float64
+
+	// Min is the smallest residual.
+	Min float64
+
+	// P5 is the 5th percentile of the residuals.
+	P5 float64
+
+	// Q1 is the 25th percentile of the residuals.
+	Q1 float64
+
+	// Median is the median residual.
+	Median float64
+
+	// Q3 is the 75th percentile of the residuals.
+	Q3 float64
+
+	// P95 is the 95th percentile of the residuals.
+	P95 float64
+
+	// Max is the largest residual.
+	Max float64
+}
+
+// RegressionReport holds the regression metrics of a network's predictions
+// for a set of rows.
+type RegressionReport struct {
+	// Rows is the number of rows evaluated.
+	Rows int
+
+	// RegressionMetrics holds the metrics averaged over the outputs, apart
+	// from MaxError, which is the largest of any output, and MAPE, which is
+	// averaged over the outputs whose MAPE is not NaN.
+	RegressionMetrics
+
+	// Outputs holds the metrics of each output.
+	Outputs []RegressionMetrics
+
+	// Residuals holds a summary of the residuals of each output.
+	Residuals []ResidualStats
+}
+
+// EvaluateRegression calculates the regression metrics of a network's
+// predictions for a set of rows, and summarises their residuals.
+//
+// Each row's inputs are predicted with Predict and compared with its outputs,
+// so the metrics are in the units of the original outputs.
+//
+// Parameters:
+// - net: The network to evaluate.
+// - rows: The rows to predict.
+//
+// Returns:
+// - A pointer to the report of the metrics.
+// - An error if there are no rows or a row cannot be predicted.
+func EvaluateRegression(net *Network, rows []*DataRow) (*RegressionReport, error) {
+	if len(rows) == 0 {
+		return nil, errors.New("evaluation error: no rows")
+	}
+
+	// Predict each row, collecting the actual values and residuals of each output.
+	outputs := len(rows[0].Ouput)
+	if outputs == 0 {
+		return nil, errors.New("evaluation error: no outputs")
+	}
+	actual := make([][]float64, outputs)
+	residuals := make([][]float64, outputs)
+	for i, row := range rows {
+		if len(row.Ouput) != outputs {
+			return nil, fmt.Errorf("evaluation error: row %v: output is incorrect size", i)
+		}
+		p, err := net.Predict(row.Input)
+		if err != nil {
+			return nil, fmt.Errorf("evaluation error: row %v: %v", i, err)
+		}
+		if len(p) != outputs {
+			return nil, fmt.Errorf("evaluation error: row %v: output is incorrect size", i)
+		}
+		for o, v := range row.Ouput {
+			actual[o] = append(actual[o], v)
+			residuals[o] = append(residuals[o], v-p[o])
+		}
+	}
+
+	// Calculate the metrics of each output and average them.
+	r := &RegressionReport{
+		Rows:      len(rows),
+		Outputs:   make([]RegressionMetrics, outputs),
+		Residuals: make([]ResidualStats, outputs),
+	}
+	scale := 1 / float64(outputs)
+	var percentages float64
+	percentageCount := 0
+	for o := range outputs {
+		m, stats := regressionMetrics(actual[o], residuals[o])
+		r.Outputs[o] = m
+		r.Residuals[o] = stats
+		r.RMSE += m.RMSE * scale
+		r.MAE += m.MAE * scale
+		if !math.IsNaN(m.MAPE) {
+			percentages += m.MAPE
+			percentageCount++
+		}
+		r.R2 += m.R2 * scale
+		r.ExplainedVariance += m.ExplainedVariance * scale
+		r.MaxError = max(r.MaxError, m.MaxError)
+	}
+	r.MAPE = math.NaN()
+	if percentageCount > 0 {
+		r.MAPE = percentages / float64(percentageCount)
+	}
+	return r, nil
+}
+
+// regressionMetrics calculates the regression metrics and residual summary
+// of an output.
+//
+// Parameters:
+// - actual: The actual values of the output.
+// - residuals: The residuals of the output, the actual less the predicted values.
+//
+// Returns:
+// - The regression metrics.
+// - The summary of the residuals.
+func regressionMetrics(actual, residuals []float64) (RegressionMetrics, ResidualStats) {
+	count := float64(len(actual))
+	var m RegressionMetrics
+	var stats ResidualStats
+	var meanActual, squares, percentages float64
+	percentageCount := 0
+	for i, res := range residuals {
+		meanActual += actual[i] / count
+		stats.Mean += res / count
+		squares += res * res
+		m.MAE += math.Abs(res) / count
+		m.MaxError = max(m.MaxError, math.Abs(res))
+		if actual[i] != 0 {
+			percentages += math.Abs(res / actual[i])
+			percentageCount++
+		}
+	}
+	m.RMSE = math.Sqrt(squares / count)
+	m.MAPE = math.NaN()
+	if percentageCount > 0 {
+		m.MAPE = percentages / float64(percentageCount)
+	}
+
+	// Compare the variance of the residuals with the variance of the actual values.
+	var varActual, varResiduals float64
+	for i, res := range residuals {
+		varActual += (actual[i] - meanActual) * (actual[i] - meanActual) / count
+		varResiduals += (res - stats.Mean) * (res - stats.Mean) / count
+	}
+	m.R2 = explained(squares/count, varActual)
+	m.ExplainedVariance = explained(varResiduals, varActual)
+	stats.StdDev = math.Sqrt(varResiduals)
+
+	// Summarise the distribution of the residuals.
+	sorted := append([]float64{}, residuals...)
+	sort.Float64s(sorted)
+	stats.Min = sorted[0]
+	stats.P5 = quantile(sorted, 0.05)
+	stats.Q1 = quantile(sorted, 0.25)
+	stats.Median = quantile(sorted, 0.5)
+	stats.Q3 = quantile(sorted, 0.75)
+	stats.P95 = quantile(sorted, 0.95)
+	stats.Max = sorted[len(sorted)-1]
+	return m, stats
+}
+
+// explained calculates the proportion of a variance that is explained.
+//
+// Parameters:
+// - unexplained: The variance left unexplained.
+// - total: The total variance.
+//
+// Returns:
+// - One less the unexplained variance as a proportion of the total. If the
+// total is zero, 1 if the unexplained variance is also zero, otherwise 0.
+func explained(unexplained, total float64) float64 {
+	if total == 0 {
+		if unexplained == 0 {
+			return 1
+		}
+		return 0
+	}
+	return 1 - unexplained/total
+}
+
+// String formats the report as text tables of the metrics and residuals of
+// each output.
+func (r *RegressionReport) String() string {
+	var sb strings.Builder
+	w := tabwriter.NewWriter(&sb, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(w, "output\trmse\tmae\tmape\tr2\texplained var\tmax error\t")
+	row := func(name string, m RegressionMetrics) {
+		fmt.Fprintf(w, "%v\t%.4f\t%.4f\t%.4f\t%.4f\t%.4f\t%.4f\t\n", name, m.RMSE, m.MAE, m.MAPE, m.R2, m.ExplainedVariance, m.MaxError)
+	}
+	for o, m := range r.Outputs {
+		row(fmt.Sprint(o), m)
+	}
+	row("overall", r.RegressionMetrics)
+	w.Flush()
+
+	sb.WriteString("\nresiduals (actual - predicted)\n")
+	w = tabwriter.NewWriter(&sb, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(w, "output\tmean\tstd dev\tmin\tp5\tq1\tmedian\tq3\tp95\tmax\t")
+	for o, s := range r.Residuals {
+		fmt.Fprintf(w, "%v\t%.4f\t%.4f\t%.4f\t%.4f\t%.4f\t%.4f\t%.4f\t%.4f\t%.4f\t\n", o, s.Mean, s.StdDev, s.Min, s.P5, s.Q1, s.Median, s.Q3, s.P95, s.Max)
+	}
+	w.Flush()
+	return sb.String()
+}
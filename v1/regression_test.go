@@ -0,0 +1,77 @@
+package jasper
+
+import (
+	"math"
+	"testing"
+)
+
+func TestEvaluateRegression(t *testing.T) {
+	n := passThrough(t, 1)
+	rows := []*DataRow{
+		{Input: []float64{2.5}, Ouput: []float64{3}},
+		{Input: []float64{0}, Ouput: []float64{-0.5}},
+		{Input: []float64{2}, Ouput: []float64{2}},
+		{Input: []float64{8}, Ouput: []float64{7}},
+	}
+	r, err := EvaluateRegression(n, rows)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name      string
+		got, want float64
+	}{
+		{"rmse", r.RMSE, 0.6124},
+		{"mae", r.MAE, 0.5},
+		{"mape", r.MAPE, 0.3274},
+		{"r2", r.R2, 0.9486},
+		{"explained variance", r.ExplainedVariance, 0.9572},
+		{"max error", r.MaxError, 1},
+		{"residual mean", r.Residuals[0].Mean, -0.25},
+		{"residual median", r.Residuals[0].Median, -0.25},
+		{"residual min", r.Residuals[0].Min, -1},
+		{"residual max", r.Residuals[0].Max, 0.5},
+	}
+	for _, tt := range tests {
+		if !near(tt.got, tt.want) {
+			t.Errorf("%v = %v, want %v", tt.name, tt.got, tt.want)
+		}
+	}
+	if r.Rows != 4 {
+		t.Errorf("rows = %v, want 4", r.Rows)
+	}
+}
+
+func TestEvaluateRegressionOutputs(t *testing.T) {
+	n := passThrough(t, 2)
+	rows := []*DataRow{
+		{Input: []float64{1, 1}, Ouput: []float64{2, 0}},
+		{Input: []float64{3, -1}, Ouput: []float64{4, 0}},
+	}
+	r, err := EvaluateRegression(n, rows)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !math.IsNaN(r.Outputs[1].MAPE) {
+		t.Errorf("mape of zero outputs = %v, want NaN", r.Outputs[1].MAPE)
+	}
+
+	// The overall MAPE leaves out the output that has none.
+	if !near(r.MAPE, r.Outputs[0].MAPE) || !near(r.MAPE, 0.375) {
+		t.Errorf("mape = %v, want 0.375", r.MAPE)
+	}
+	if !near(r.MAE, 1) || !near(r.MaxError, 1) {
+		t.Errorf("mae = %v, max error = %v, want 1 and 1", r.MAE, r.MaxError)
+	}
+}
+
+func TestEvaluateRegressionErrors(t *testing.T) {
+	n := passThrough(t, 1)
+	if _, err := EvaluateRegression(n, nil); err == nil {
+		t.Error("no rows: expected an error")
+	}
+	rows := []*DataRow{{Input: []float64{1}, Ouput: []float64{1, 2}}}
+	if _, err := EvaluateRegression(n, rows); err == nil {
+		t.Error("wrong output size: expected an error")
+	}
+}
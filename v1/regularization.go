@@ -0,0 +1,204 @@
+// regularization.go - Weight penalties and constraints used in the neural network.
+//
+// # Copyright 2024 Mark Oxley
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package jasper
+
+import (
+	"errors"
+	"math"
+)
+
+// Regularization penalises large parameters to reduce overfitting.
+//
+// The L1 and L2 penalties are added to the reported error and their gradients
+// to the gradients of the parameters. The penalty of a parameter is L1 times
+// the sum of the absolute values plus L2 times the sum of the squared values.
+// Setting both gives an elastic net penalty.
+type Regularization struct {
+	// L1 is the strength of the L1 penalty, which pushes parameters towards
+	// zero and so encourages sparse weights.
+	L1 float64 `json:"l1,omitempty"`
+
+	// L2 is the strength of the L2 penalty, which shrinks parameters in
+	// proportion to their size. This is also known as weight decay.
+	L2 float64 `json:"l2,omitempty"`
+
+	// MaxNorm, if greater than zero, limits the length of the incoming weight
+	// vector of each neuron. Weights that exceed it are scaled back after
+	// each update. It is never applied to biases.
+	MaxNorm float64 `json:"mn,omitempty"`
+
+	// ExcludeBiases leaves the biases out of the L1 and L2 penalties.
+	ExcludeBiases bool `json:"eb,omitempty"`
+}
+
+// NewL1 creates an L1 penalty.
+//
+// Parameters:
+// - l1: The strength of the penalty.
+//
+// Returns:
+// - A pointer to the regularization.
+func NewL1(l1 float64) *Regularization {
+	return &Regularization{L1: l1}
+}
+
+// NewL2 creates an L2 penalty.
+//
+// Parameters:
+// - l2: The strength of the penalty.
+//
+// Returns:
+// - A pointer to the regularization.
+func NewL2(l2 float64) *Regularization {
+	return &Regularization{L2: l2}
+}
+
+// NewElasticNet creates an elastic net penalty, a mix of the L1 and L2
+// penalties.
+//
+// Parameters:
+// - strength: The combined strength of the penalties.
+// - ratio: The proportion of the strength given to the L1 penalty, between 0 and 1.
+//
+// Returns:
+// - A pointer to the regularization.
+func NewElasticNet(strength, ratio float64) *Regularization {
+	return &Regularization{L1: strength * ratio, L2: strength * (1 - ratio)}
+}
+
+// NewMaxNorm creates a max-norm constraint with no penalties.
+//
+// Parameters:
+// - limit: The largest length of the incoming weight vector of each neuron.
+//
+// Returns:
+// - A pointer to the regularization.
+func NewMaxNorm(limit float64) *Regularization {
+	return &Regularization{MaxNorm: limit}
+}
+
+// validate checks the settings of the regularization.
+//
+// Returns:
+// - An error if a setting is negative.
+func (r *Regularization) validate() error {
+	if r != nil && (r.L1 < 0 || r.L2 < 0 || r.MaxNorm < 0) {
+		return errors.New("regularization error: negative setting")
+	}
+	return nil
+}
+
+// penalised returns true if the L1 and L2 penalties apply to a parameter.
+//
+// Parameters:
+// - p: The parameter.
+//
+// Returns:
+// - True if the parameter is penalised.
+func (r *Regularization) penalised(p *Parameter) bool {
+	return r != nil && (r.L1 != 0 || r.L2 != 0) && !(p.Bias && r.ExcludeBiases)
+}
+
+// penalty calculates the L1 and L2 penalty of a parameter.
+//
+// Parameters:
+// - p: The parameter.
+//
+// Returns:
+// - The penalty.
+func (r *Regularization) penalty(p *Parameter) float64 {
+	if !r.penalised(p) {
+		return 0
+	}
+	var res float64
+	for _, v := range p.Value.values {
+		res += r.L1*math.Abs(v) + r.L2*v*v
+	}
+	return res
+}
+
+// addGradient adds the gradient of the L1 and L2 penalty to the gradient of a
+// parameter.
+//
+// Parameters:
+// - p: The parameter.
+func (r *Regularization) addGradient(p *Parameter) {
+	if !r.penalised(p) || p.Gradient == nil {
+		return
+	}
+	for i, v := range p.Value.values {
+		sign := 0.0
+		if v > 0 {
+			sign = 1
+		} else if v < 0 {
+			sign = -1
+		}
+		p.Gradient.values[i] += r.L1*sign + 2*r.L2*v
+	}
+}
+
+// constrain scales back the incoming weights of each neuron, the columns of
+// the parameter, whose length exceeds MaxNorm.
+//
+// Parameters:
+// - p: The parameter.
+func (r *Regularization) constrain(p *Parameter) {
+	if r == nil || r.MaxNorm <= 0 || p.Bias {
+		return
+	}
+	m := p.Value
+	for c := 0; c < int(m.cols); c++ {
+		var norm float64
+		for k := c; k < len(m.values); k += int(m.cols) {
+			norm += m.values[k] * m.values[k]
+		}
+		norm = math.Sqrt(norm)
+		if norm <= r.MaxNorm {
+			continue
+		}
+		scale := r.MaxNorm / norm
+		for k := c; k < len(m.values); k += int(m.cols) {
+			m.values[k] *= scale
+		}
+	}
+}
+
+// regularizationOf returns the regularization of a parameter, which is its
+// layer's regularization if it has one, otherwise the network's.
+//
+// Parameters:
+// - p: The parameter.
+//
+// Returns:
+// - A pointer to the regularization, or nil if there is none.
+func (n *Network) regularizationOf(p *Parameter) *Regularization {
+	if p.Regularization != nil {
+		return p.Regularization
+	}
+	return n.regularization
+}
+
+// penalty calculates the total L1 and L2 penalty of the network's parameters.
+//
+// Returns:
+// - The penalty.
+func (n *Network) penalty() float64 {
+	var res float64
+	for _, p := range n.model.Parameters() {
+		res += n.regularizationOf(p).penalty(p)
+	}
+	return res
+}
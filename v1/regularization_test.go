@@ -0,0 +1,140 @@
+package jasper
+
+import (
+	"math"
+	"testing"
+)
+
+// regularizedParameter returns a parameter holding two columns of values.
+func regularizedParameter(bias bool) *Parameter {
+	m := NewMatrix(2, 2)
+	copy(m.values, []float64{1, -2, 3, 0})
+	p := newParameter(m)
+	p.Bias = bias
+	return p
+}
+
+func TestRegularizationPenalty(t *testing.T) {
+	tests := []struct {
+		name     string
+		r        *Regularization
+		bias     bool
+		want     float64
+		gradient []float64
+	}{
+		{"none", nil, false, 0, []float64{0, 0, 0, 0}},
+		{"l1", NewL1(0.1), false, 0.6, []float64{0.1, -0.1, 0.1, 0}},
+		{"l2", NewL2(0.1), false, 1.4, []float64{0.2, -0.4, 0.6, 0}},
+		{"elastic net", NewElasticNet(0.2, 0.25), false, 0.05*6 + 0.15*14, []float64{0.35, -0.65, 0.95, 0}},
+		{"biases", NewL2(0.1), true, 1.4, []float64{0.2, -0.4, 0.6, 0}},
+		{"biases excluded", &Regularization{L2: 0.1, ExcludeBiases: true}, true, 0, []float64{0, 0, 0, 0}},
+		{"max norm", NewMaxNorm(1), false, 0, []float64{0, 0, 0, 0}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := regularizedParameter(tt.bias)
+			if got := tt.r.penalty(p); !near(got, tt.want) {
+				t.Errorf("penalty = %v, want %v", got, tt.want)
+			}
+
+			// The gradient of the penalty is added to the parameter's.
+			p.Gradient = NewMatrix(2, 2)
+			tt.r.addGradient(p)
+			for k := range tt.gradient {
+				if !near(p.Gradient.values[k], tt.gradient[k]) {
+					t.Fatalf("gradient %v, want %v", p.Gradient.values, tt.gradient)
+				}
+			}
+		})
+	}
+}
+
+func TestMaxNorm(t *testing.T) {
+	// The first column has a length of √10 and the second of 2.
+	p := regularizedParameter(false)
+	NewMaxNorm(2.5).constrain(p)
+	want := []float64{2.5 / math.Sqrt(10), -2, 7.5 / math.Sqrt(10), 0}
+	for k := range want {
+		if !near(p.Value.values[k], want[k]) {
+			t.Fatalf("values %v, want %v", p.Value.values, want)
+		}
+	}
+
+	// Biases are never constrained.
+	p = regularizedParameter(true)
+	NewMaxNorm(1).constrain(p)
+	if p.Value.values[2] != 3 {
+		t.Errorf("biases %v were constrained", p.Value.values)
+	}
+}
+
+// regularizedNetwork trains a network for a single iteration with the given
+// regularization and learning rate.
+func regularizedNetwork(t *testing.T, r *Regularization, rate float64) (*Network, *TrainingHistory) {
+	t.Helper()
+	c := NewConfig([]uint32{2, 8, 1})
+	c.Seed = 4
+	c.Quiet = true
+	c.Regularization = r
+	c.LearningRate = rate
+	n, err := New(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	td := &TrainingData{TrainingSource: NewSliceSource(xorRows()), ValidationSource: NewSliceSource(xorRows()), Iterations: 1}
+	h, err := n.Train(td)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return n, h
+}
+
+func TestRegularizationLoss(t *testing.T) {
+	// Without learning the penalty is simply added to the errors.
+	_, plain := regularizedNetwork(t, nil, 0)
+	n, h := regularizedNetwork(t, NewElasticNet(0.01, 0.5), 0)
+	penalty := n.penalty()
+	if penalty <= 0 {
+		t.Fatalf("penalty = %v, want more than zero", penalty)
+	}
+	got, want := h.Epochs[0], plain.Epochs[0]
+	if !near(got.TrainLoss, want.TrainLoss+penalty) || !near(got.ValidationLoss, want.ValidationLoss+penalty) {
+		t.Errorf("losses %v and %v, want %v and %v", got.TrainLoss, got.ValidationLoss, want.TrainLoss+penalty, want.ValidationLoss+penalty)
+	}
+
+	// A layer's own regularization replaces the network's.
+	c := NewConfig([]uint32{2})
+	c.Regularization = NewL2(1)
+	c.Model = NewSequential(NewDense(3, Tanh), NewDense(1, Sigmoid))
+	c.Model.layers[1].(*Dense).SetRegularization(NewL2(0))
+	n, err := New(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var first float64
+	for _, p := range n.model.layers[0].Parameters() {
+		first += NewL2(1).penalty(p)
+	}
+	if first == 0 || !near(n.penalty(), first) {
+		t.Errorf("penalty = %v, want %v from the first layer", n.penalty(), first)
+	}
+}
+
+func TestMaxNormTraining(t *testing.T) {
+	n, _ := regularizedNetwork(t, NewMaxNorm(0.1), 5)
+	for _, p := range n.model.Parameters() {
+		if p.Bias {
+			continue
+		}
+		m := p.Value
+		for c := range int(m.cols) {
+			var norm float64
+			for k := c; k < len(m.values); k += int(m.cols) {
+				norm += m.values[k] * m.values[k]
+			}
+			if math.Sqrt(norm) > 0.1+1e-9 {
+				t.Errorf("column %v has a length of %v, want at most 0.1", c, math.Sqrt(norm))
+			}
+		}
+	}
+}
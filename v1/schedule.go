@@ -0,0 +1,678 @@
+// schedule.go - Learning rate schedules used when training the neural network.
+//
+// # Copyright 2024 Mark Oxley
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package jasper
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+)
+
+// LearningRateSchedule is the interface implemented by the schedules that
+// vary the learning rate of the network over the training iterations.
+//
+// The built in schedules are StepDecay, ExponentialDecay, CosineAnnealing,
+// LinearWarmup, OneCycle and ReduceOnPlateau.
+type LearningRateSchedule interface {
+	// Rate returns the learning rate to use for an iteration.
+	//
+	// Parameters:
+	// - epoch: The zero based training iteration.
+	// - base: The learning rate from the network configuration.
+	//
+	// Returns:
+	// - The learning rate for the iteration.
+	Rate(epoch int, base float64) float64
+}
+
+// LossObserver is implemented by learning rate schedules that react to the
+// error calculated against the validation data after each training iteration.
+type LossObserver interface {
+	// Observe records the validation error of a completed training iteration.
+	//
+	// Parameters:
+	// - epoch: The zero based training iteration.
+	// - loss: The average error of the validation data.
+	Observe(epoch int, loss float64)
+}
+
+// observesLoss returns true if a schedule, or the schedule it uses after a
+// warm up, reacts to the validation error.
+//
+// Parameters:
+// - s: The schedule, which may be nil.
+//
+// Returns:
+// - True if the schedule observes the validation error.
+func observesLoss(s LearningRateSchedule) bool {
+	switch s := s.(type) {
+	case *LinearWarmup:
+		return observesLoss(s.Schedule)
+	case LossObserver:
+		return true
+	}
+	return false
+}
+
+// getSchedule returns an empty instance of the learning rate schedule with the
+// given name.
+//
+// Parameters:
+// - name: The name of the schedule.
+//
+// Returns:
+// - LearningRateSchedule: An instance of the schedule, or nil if the name is unknown.
+func getSchedule(name string) LearningRateSchedule {
+	switch name {
+	case "step":
+		return &StepDecay{}
+	case "exponential":
+		return &ExponentialDecay{}
+	case "cosine":
+		return &CosineAnnealing{}
+	case "warmup":
+		return &LinearWarmup{}
+	case "onecycle":
+		return &OneCycle{}
+	case "plateau":
+		return &ReduceOnPlateau{}
+	}
+	return nil
+}
+
+// scheduleName returns the name used to identify a built in learning rate
+// schedule in a saved network.
+//
+// Parameters:
+// - s: The schedule.
+//
+// Returns:
+// - The name of the schedule, or an empty string if it is not built in.
+func scheduleName(s LearningRateSchedule) string {
+	switch s.(type) {
+	case *StepDecay:
+		return "step"
+	case *ExponentialDecay:
+		return "exponential"
+	case *CosineAnnealing:
+		return "cosine"
+	case *LinearWarmup:
+		return "warmup"
+	case *OneCycle:
+		return "onecycle"
+	case *ReduceOnPlateau:
+		return "plateau"
+	}
+	return ""
+}
+
+// marshalSchedule marshals a learning rate schedule, including any state it
+// keeps, into JSON.
+//
+// Schedules that are not built in cannot be restored, so they are not saved.
+//
+// Parameters:
+// - s: The schedule to marshal.
+//
+// Returns:
+// - A JSON byte slice holding the name and settings of the schedule, or nil.
+// - An error if there is an error during the marshaling process.
+func marshalSchedule(s LearningRateSchedule) ([]byte, error) {
+	name := scheduleName(s)
+	if name == "" {
+		return nil, nil
+	}
+	params, err := json.Marshal(s)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(&struct {
+		Name   string          `json:"n"`
+		Params json.RawMessage `json:"p"`
+	}{
+		Name:   name,
+		Params: params,
+	})
+}
+
+// unmarshalSchedule unmarshals a learning rate schedule written by marshalSchedule.
+//
+// Parameters:
+// - body: The JSON byte slice to unmarshal.
+//
+// Returns:
+// - The schedule.
+// - An error if the schedule is unknown or cannot be unmarshaled.
+func unmarshalSchedule(body []byte) (LearningRateSchedule, error) {
+	data := struct {
+		Name   string          `json:"n"`
+		Params json.RawMessage `json:"p"`
+	}{}
+	if err := json.Unmarshal(body, &data); err != nil {
+		return nil, err
+	}
+	s := getSchedule(data.Name)
+	if s == nil {
+		return nil, fmt.Errorf("unknown learning rate schedule: %v", data.Name)
+	}
+	if err := json.Unmarshal(data.Params, s); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// copySchedule returns a copy of a learning rate schedule, so that the state
+// kept by a schedule such as ReduceOnPlateau is not shared between networks.
+// Schedules that are not built in cannot be copied and are shared, including
+// one used after a linear warm up, which is copied field by field.
+//
+// Parameters:
+// - s: The schedule to copy, or nil.
+//
+// Returns:
+// - The copy.
+// - An error if the schedule cannot be copied.
+func copySchedule(s LearningRateSchedule) (LearningRateSchedule, error) {
+	if w, ok := s.(*LinearWarmup); ok {
+		then, err := copySchedule(w.Schedule)
+		if err != nil {
+			return nil, err
+		}
+		return &LinearWarmup{Epochs: w.Epochs, Schedule: then}, nil
+	}
+	if s == nil || scheduleName(s) == "" {
+		return s, nil
+	}
+	body, err := marshalSchedule(s)
+	if err != nil {
+		return nil, err
+	}
+	return unmarshalSchedule(body)
+}
+
+// StepDecay multiplies the learning rate by Factor every StepSize iterations.
+type StepDecay struct {
+	// Factor is the multiplier applied at each step.
+	Factor float64 `json:"f"`
+
+	// StepSize is the number of iterations between steps.
+	StepSize int `json:"s"`
+}
+
+// NewStepDecay creates a step decay schedule.
+//
+// Parameters:
+// - factor: The multiplier applied at each step.
+// - stepSize: The number of iterations between steps.
+//
+// Returns:
+// - A pointer to the schedule.
+func NewStepDecay(factor float64, stepSize int) *StepDecay {
+	return &StepDecay{
+		Factor:   factor,
+		StepSize: stepSize,
+	}
+}
+
+// Rate returns the learning rate to use for an iteration.
+//
+// Parameters:
+// - epoch: The zero based training iteration.
+// - base: The learning rate from the network configuration.
+//
+// Returns:
+// - The learning rate for the iteration.
+func (s *StepDecay) Rate(epoch int, base float64) float64 {
+	if s.StepSize <= 0 {
+		return base
+	}
+	return base * math.Pow(s.Factor, float64(epoch/s.StepSize))
+}
+
+// String describes the schedule.
+func (s *StepDecay) String() string {
+	return fmt.Sprintf("step decay (factor %v every %v iterations)", s.Factor, s.StepSize)
+}
+
+// ExponentialDecay multiplies the learning rate by Decay every iteration.
+type ExponentialDecay struct {
+	// Decay is the multiplier applied at each iteration.
+	Decay float64 `json:"d"`
+}
+
+// NewExponentialDecay creates an exponential decay schedule.
+//
+// Parameters:
+// - decay: The multiplier applied at each iteration.
+//
+// Returns:
+// - A pointer to the schedule.
+func NewExponentialDecay(decay float64) *ExponentialDecay {
+	return &ExponentialDecay{
+		Decay: decay,
+	}
+}
+
+// Rate returns the learning rate to use for an iteration.
+//
+// Parameters:
+// - epoch: The zero based training iteration.
+// - base: The learning rate from the network configuration.
+//
+// Returns:
+// - The learning rate for the iteration.
+func (s *ExponentialDecay) Rate(epoch int, base float64) float64 {
+	return base * math.Pow(s.Decay, float64(epoch))
+}
+
+// String describes the schedule.
+func (s *ExponentialDecay) String() string {
+	return fmt.Sprintf("exponential decay (factor %v)", s.Decay)
+}
+
+// CosineAnnealing follows half a cosine wave from the base learning rate down
+// to MinRate, then restarts from the base learning rate.
+//
+// The first cycle lasts Period iterations and each following cycle is
+// Multiplier times longer than the one before.
+type CosineAnnealing struct {
+	// Period is the number of iterations in the first cycle.
+	Period int `json:"p"`
+
+	// Multiplier is the growth in length of each cycle. Values below one are
+	// treated as one.
+	Multiplier int `json:"m"`
+
+	// MinRate is the learning rate at the end of each cycle.
+	MinRate float64 `json:"r"`
+}
+
+// NewCosineAnnealing creates a cosine annealing schedule with warm restarts.
+//
+// Parameters:
+// - period: The number of iterations in the first cycle.
+// - multiplier: The growth in length of each cycle.
+// - minRate: The learning rate at the end of each cycle.
+//
+// Returns:
+// - A pointer to the schedule.
+func NewCosineAnnealing(period, multiplier int, minRate float64) *CosineAnnealing {
+	return &CosineAnnealing{
+		Period:     period,
+		Multiplier: multiplier,
+		MinRate:    minRate,
+	}
+}
+
+// Rate returns the learning rate to use for an iteration.
+//
+// Parameters:
+// - epoch: The zero based training iteration.
+// - base: The learning rate from the network configuration.
+//
+// Returns:
+// - The learning rate for the iteration.
+func (s *CosineAnnealing) Rate(epoch int, base float64) float64 {
+	if s.Period <= 0 {
+		return base
+	}
+
+	// Find the position within the current cycle.
+	period := s.Period
+	multiplier := max(s.Multiplier, 1)
+	for epoch >= period {
+		epoch -= period
+		period *= multiplier
+	}
+	return s.MinRate + (base-s.MinRate)*(1+math.Cos(math.Pi*float64(epoch)/float64(period)))/2
+}
+
+// String describes the schedule.
+func (s *CosineAnnealing) String() string {
+	return fmt.Sprintf("cosine annealing (period %v, multiplier %v, minimum %v)", s.Period, s.Multiplier, s.MinRate)
+}
+
+// LinearWarmup increases the learning rate linearly from zero to the base
+// learning rate over the first Epochs iterations, then hands over to Schedule.
+type LinearWarmup struct {
+	// Epochs is the number of warm up iterations.
+	Epochs int
+
+	// Schedule is the schedule used after the warm up. The iterations passed
+	// to it are counted from the end of the warm up. If nil, the base learning
+	// rate is used. A schedule that is not built in cannot be saved, so a
+	// network or checkpoint using it returns an error when saved.
+	Schedule LearningRateSchedule
+}
+
+// NewLinearWarmup creates a linear warm up schedule.
+//
+// Parameters:
+// - epochs: The number of warm up iterations.
+// - then: The schedule used after the warm up, or nil.
+//
+// Returns:
+// - A pointer to the schedule.
+func NewLinearWarmup(epochs int, then LearningRateSchedule) *LinearWarmup {
+	return &LinearWarmup{
+		Epochs:   epochs,
+		Schedule: then,
+	}
+}
+
+// Rate returns the learning rate to use for an iteration.
+//
+// Parameters:
+// - epoch: The zero based training iteration.
+// - base: The learning rate from the network configuration.
+//
+// Returns:
+// - The learning rate for the iteration.
+func (s *LinearWarmup) Rate(epoch int, base float64) float64 {
+	if epoch < s.Epochs {
+		return base * float64(epoch+1) / float64(s.Epochs)
+	}
+	if s.Schedule == nil {
+		return base
+	}
+	return s.Schedule.Rate(epoch-s.Epochs, base)
+}
+
+// Observe passes the validation error on to the schedule used after the warm up.
+//
+// Parameters:
+// - epoch: The zero based training iteration.
+// - loss: The average error of the validation data.
+func (s *LinearWarmup) Observe(epoch int, loss float64) {
+	if o, ok := s.Schedule.(LossObserver); ok && epoch >= s.Epochs {
+		o.Observe(epoch-s.Epochs, loss)
+	}
+}
+
+// MarshalJSON marshals the schedule, including the schedule used after the
+// warm up, into JSON.
+//
+// Returns:
+// - A JSON byte slice representing the schedule.
+// - An error if the schedule used after the warm up is not built in, or there
+// is an error during the marshaling process.
+func (s *LinearWarmup) MarshalJSON() ([]byte, error) {
+	if s.Schedule != nil && scheduleName(s.Schedule) == "" {
+		return nil, fmt.Errorf("cannot save learning rate schedule of type %T", s.Schedule)
+	}
+	then, err := marshalSchedule(s.Schedule)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(&struct {
+		Epochs   int             `json:"e"`
+		Schedule json.RawMessage `json:"s,omitempty"`
+	}{
+		Epochs:   s.Epochs,
+		Schedule: then,
+	})
+}
+
+// UnmarshalJSON unmarshals the schedule from JSON.
+//
+// Parameters:
+// - body: The JSON byte slice to unmarshal.
+//
+// Returns:
+// - An error if there is an error during the unmarshaling process.
+func (s *LinearWarmup) UnmarshalJSON(body []byte) (err error) {
+	data := struct {
+		Epochs   int             `json:"e"`
+		Schedule json.RawMessage `json:"s,omitempty"`
+	}{}
+	if err := json.Unmarshal(body, &data); err != nil {
+		return err
+	}
+	s.Epochs = data.Epochs
+	s.Schedule = nil
+	if len(data.Schedule) > 0 {
+		s.Schedule, err = unmarshalSchedule(data.Schedule)
+	}
+	return err
+}
+
+// String describes the schedule.
+func (s *LinearWarmup) String() string {
+	if s.Schedule == nil {
+		return fmt.Sprintf("linear warm up (%v iterations)", s.Epochs)
+	}
+	return fmt.Sprintf("linear warm up (%v iterations) then %v", s.Epochs, s.Schedule)
+}
+
+// OneCycle implements the one cycle policy. The learning rate rises from
+// MaxRate/DivFactor to MaxRate over the first WarmUp fraction of Epochs, then
+// follows a cosine curve down to MaxRate/(DivFactor*FinalDivFactor).
+type OneCycle struct {
+	// Epochs is the total number of iterations in the cycle.
+	Epochs int `json:"e"`
+
+	// MaxRate is the peak learning rate. If zero, the base learning rate is used.
+	MaxRate float64 `json:"m"`
+
+	// WarmUp is the fraction of the cycle spent increasing the learning rate.
+	WarmUp float64 `json:"w"`
+
+	// DivFactor sets the starting learning rate as MaxRate/DivFactor.
+	DivFactor float64 `json:"d"`
+
+	// FinalDivFactor sets the final learning rate as the starting learning
+	// rate divided by FinalDivFactor.
+	FinalDivFactor float64 `json:"f"`
+}
+
+// NewOneCycle creates a one cycle schedule peaking at the base learning rate,
+// with a WarmUp of 0.3, a DivFactor of 25 and a FinalDivFactor of 1e4.
+//
+// Parameters:
+// - epochs: The total number of iterations in the cycle.
+//
+// Returns:
+// - A pointer to the schedule.
+func NewOneCycle(epochs int) *OneCycle {
+	return &OneCycle{
+		Epochs:         epochs,
+		WarmUp:         0.3,
+		DivFactor:      25,
+		FinalDivFactor: 1e4,
+	}
+}
+
+// Rate returns the learning rate to use for an iteration.
+//
+// Parameters:
+// - epoch: The zero based training iteration.
+// - base: The learning rate from the network configuration.
+//
+// Returns:
+// - The learning rate for the iteration.
+func (s *OneCycle) Rate(epoch int, base float64) float64 {
+	peak := s.MaxRate
+	if peak == 0 {
+		peak = base
+	}
+	start := peak / math.Max(s.DivFactor, 1)
+	end := start / math.Max(s.FinalDivFactor, 1)
+	if s.Epochs <= 1 {
+		return peak
+	}
+
+	// Work out how far through the cycle the iteration is, and where the peak lies.
+	pos := math.Min(float64(epoch)/float64(s.Epochs-1), 1)
+	top := math.Min(math.Max(s.WarmUp, 0), 1)
+	if pos < top {
+		return start + (peak-start)*(1-math.Cos(math.Pi*pos/top))/2
+	}
+	if top == 1 {
+		return peak
+	}
+	return end + (peak-end)*(1+math.Cos(math.Pi*(pos-top)/(1-top)))/2
+}
+
+// String describes the schedule.
+func (s *OneCycle) String() string {
+	if s.MaxRate == 0 {
+		return fmt.Sprintf("one cycle (%v iterations, peak at the base rate)", s.Epochs)
+	}
+	return fmt.Sprintf("one cycle (%v iterations, peak %v)", s.Epochs, s.MaxRate)
+}
+
+// ReduceOnPlateau multiplies the learning rate by Factor when the testing
+// error has not improved by at least MinDelta for Patience iterations.
+type ReduceOnPlateau struct {
+	// Factor is the multiplier applied when the error stops improving.
+	Factor float64
+
+	// Patience is the number of iterations without improvement allowed before
+	// the learning rate is reduced.
+	Patience int
+
+	// MinDelta is the smallest decrease in error counted as an improvement.
+	MinDelta float64
+
+	// MinRate is the lowest learning rate the schedule will reduce to.
+	MinRate float64
+
+	// scale is the product of the reductions made so far.
+	scale float64
+
+	// best is the lowest error seen so far.
+	best float64
+
+	// wait is the number of iterations since the error last improved.
+	wait int
+
+	// started indicates if an error has been observed.
+	started bool
+}
+
+// NewReduceOnPlateau creates a reduce on plateau schedule.
+//
+// Parameters:
+// - factor: The multiplier applied when the error stops improving.
+// - patience: The number of iterations without improvement allowed.
+//
+// Returns:
+// - A pointer to the schedule.
+func NewReduceOnPlateau(factor float64, patience int) *ReduceOnPlateau {
+	return &ReduceOnPlateau{
+		Factor:   factor,
+		Patience: patience,
+	}
+}
+
+// Rate returns the learning rate to use for an iteration.
+//
+// Parameters:
+// - epoch: The zero based training iteration.
+// - base: The learning rate from the network configuration.
+//
+// Returns:
+// - The learning rate for the iteration.
+func (s *ReduceOnPlateau) Rate(epoch int, base float64) float64 {
+	if !s.started {
+		return base
+	}
+	return math.Max(base*s.scale, s.MinRate)
+}
+
+// Observe records the validation error of a completed training iteration,
+// reducing the learning rate if the error has stopped improving.
+//
+// Parameters:
+// - epoch: The zero based training iteration.
+// - loss: The average error of the validation data.
+func (s *ReduceOnPlateau) Observe(epoch int, loss float64) {
+	if !s.started {
+		s.started = true
+		s.scale = 1
+		s.best = loss
+		return
+	}
+	if loss < s.best-s.MinDelta {
+		s.best = loss
+		s.wait = 0
+		return
+	}
+	s.wait++
+	if s.wait >= s.Patience {
+		s.scale *= s.Factor
+		s.wait = 0
+	}
+}
+
+// reduceOnPlateauJSON is the saved form of a ReduceOnPlateau schedule,
+// including the state it has built up during training.
+type reduceOnPlateauJSON struct {
+	Factor   float64 `json:"f"`
+	Patience int     `json:"p"`
+	MinDelta float64 `json:"d"`
+	MinRate  float64 `json:"m"`
+	Scale    float64 `json:"sc"`
+	Best     float64 `json:"b"`
+	Wait     int     `json:"w"`
+	Started  bool    `json:"st"`
+}
+
+// MarshalJSON marshals the schedule and its state into JSON.
+//
+// Returns:
+// - A JSON byte slice representing the schedule.
+// - An error if there is an error during the marshaling process.
+func (s *ReduceOnPlateau) MarshalJSON() ([]byte, error) {
+	return json.Marshal(&reduceOnPlateauJSON{
+		Factor:   s.Factor,
+		Patience: s.Patience,
+		MinDelta: s.MinDelta,
+		MinRate:  s.MinRate,
+		Scale:    s.scale,
+		Best:     s.best,
+		Wait:     s.wait,
+		Started:  s.started,
+	})
+}
+
+// UnmarshalJSON unmarshals the schedule and its state from JSON.
+//
+// Parameters:
+// - body: The JSON byte slice to unmarshal.
+//
+// Returns:
+// - An error if there is an error during the unmarshaling process.
+func (s *ReduceOnPlateau) UnmarshalJSON(body []byte) error {
+	data := reduceOnPlateauJSON{}
+	if err := json.Unmarshal(body, &data); err != nil {
+		return err
+	}
+	s.Factor = data.Factor
+	s.Patience = data.Patience
+	s.MinDelta = data.MinDelta
+	s.MinRate = data.MinRate
+	s.scale = data.Scale
+	s.best = data.Best
+	s.wait = data.Wait
+	s.started = data.Started
+	return nil
+}
+
+// String describes the schedule.
+func (s *ReduceOnPlateau) String() string {
+	return fmt.Sprintf("reduce on plateau (factor %v, patience %v)", s.Factor, s.Patience)
+}
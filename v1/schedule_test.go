@@ -0,0 +1,119 @@
+package jasper
+
+import (
+	"reflect"
+	"testing"
+)
+
+// observedPlateau returns a reduce on plateau schedule that has observed
+// enough errors to have reduced the learning rate.
+func observedPlateau() *ReduceOnPlateau {
+	s := NewReduceOnPlateau(0.5, 1)
+	for epoch, loss := range []float64{1, 0.8, 0.9, 0.9, 0.95} {
+		s.Observe(epoch, loss)
+	}
+	return s
+}
+
+func TestScheduleJSON(t *testing.T) {
+	tests := []struct {
+		name     string
+		schedule LearningRateSchedule
+	}{
+		{"step", NewStepDecay(0.5, 10)},
+		{"exponential", NewExponentialDecay(0.05)},
+		{"cosine", NewCosineAnnealing(10, 2, 0.001)},
+		{"onecycle", NewOneCycle(50)},
+		{"plateau", observedPlateau()},
+		{"warmup", NewLinearWarmup(5, NewStepDecay(0.5, 10))},
+		{"warmup plateau", NewLinearWarmup(2, observedPlateau())},
+		{"warmup alone", NewLinearWarmup(5, nil)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body, err := marshalSchedule(tt.schedule)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := unmarshalSchedule(body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.schedule) {
+				t.Errorf("got %+v, want %+v", got, tt.schedule)
+			}
+			for epoch := range 20 {
+				if got.Rate(epoch, 0.1) != tt.schedule.Rate(epoch, 0.1) {
+					t.Fatalf("epoch %v: rate %v, want %v", epoch, got.Rate(epoch, 0.1), tt.schedule.Rate(epoch, 0.1))
+				}
+			}
+		})
+	}
+}
+
+func TestScheduleJSONUnknown(t *testing.T) {
+	if _, err := unmarshalSchedule([]byte(`{"n":"polynomial","p":{}}`)); err == nil {
+		t.Error("expected an error")
+	}
+}
+
+// halving is a schedule that is not built in, which halves the learning rate.
+type halving struct{}
+
+// Rate returns half the base learning rate.
+func (halving) Rate(epoch int, base float64) float64 {
+	return base / 2
+}
+
+func TestCopySchedule(t *testing.T) {
+	s := NewReduceOnPlateau(0.5, 0)
+	c, err := copySchedule(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.(LossObserver).Observe(0, 1)
+	c.(LossObserver).Observe(1, 2)
+	if s.Rate(2, 0.1) != 0.1 {
+		t.Errorf("rate of the original = %v, want 0.1", s.Rate(2, 0.1))
+	}
+	if c.Rate(2, 0.1) == 0.1 {
+		t.Error("the copy did not reduce its rate")
+	}
+
+	// A schedule that is not built in is kept after a warm up.
+	w := NewLinearWarmup(2, halving{})
+	c, err = copySchedule(w)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c == LearningRateSchedule(w) {
+		t.Error("the warm up was not copied")
+	}
+	if got := c.Rate(5, 0.1); got != 0.05 {
+		t.Errorf("rate after the warm up = %v, want 0.05", got)
+	}
+	conf := NewConfig([]uint32{1, 2, 1})
+	conf.Schedule = w
+	n, err := New(conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := n.Schedule().Rate(5, 0.1); got != 0.05 {
+		t.Errorf("network rate after the warm up = %v, want 0.05", got)
+	}
+}
+
+func TestScheduleJSONNotBuiltIn(t *testing.T) {
+	if _, err := marshalSchedule(NewLinearWarmup(2, halving{})); err == nil {
+		t.Error("warm up: expected an error")
+	}
+	conf := NewConfig([]uint32{1, 2, 1})
+	conf.Schedule = NewLinearWarmup(2, halving{})
+	n, err := New(conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := n.MarshalJSON(); err == nil {
+		t.Error("network: expected an error")
+	}
+}